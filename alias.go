@@ -0,0 +1,159 @@
+package roll
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// aliasScale is the resolution of the biased coin flip in an alias table
+const aliasScale = 1 << 30
+
+// aliasTable samples indices with given weights in constant time using Walker's alias method
+type aliasTable struct {
+	thresh []int // keep column i if a draw in [0, aliasScale) is below thresh[i]
+	alias  []int // otherwise use alias[i]
+}
+
+// newAliasTable builds an alias table for weights, which must be non-negative and sum to more
+// than 0. Vose's algorithm is used to build the table in linear time.
+func newAliasTable(weights []float64) *aliasTable {
+	var (
+		n            = len(weights)
+		t            = &aliasTable{thresh: make([]int, n), alias: make([]int, n)}
+		scaled       = make([]float64, n)
+		small, large []int
+		total        = 0.
+	)
+
+	for _, w := range weights {
+		total += w
+	}
+
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / total
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small, large = small[:len(small)-1], large[:len(large)-1]
+
+		t.thresh[s], t.alias[s] = int(scaled[s]*aliasScale), l
+
+		scaled[l] -= 1 - scaled[s]
+		if scaled[l] < 1 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+
+	// Anything left over has a probability of 1 within rounding error
+	for _, i := range append(small, large...) {
+		t.thresh[i], t.alias[i] = aliasScale, i
+	}
+
+	return t
+}
+
+func (t *aliasTable) sample(src Source) int {
+	i := intn(src, len(t.thresh))
+	if intn(src, aliasScale) < t.thresh[i] {
+		return i
+	}
+
+	return t.alias[i]
+}
+
+// Sampler draws values from a Distribution in constant time, however the distribution was
+// computed. It's typically much faster to sample the distribution of an expression such as
+// 20d6Kh10X6 than to roll it.
+type Sampler struct {
+	values []int
+	table  *aliasTable
+}
+
+// NewSampler returns a Sampler for d. An error is returned if d has no values with a positive
+// probability or has a probability that isn't finite.
+func NewSampler(d Distribution) (*Sampler, error) {
+	s := &Sampler{values: d.Values()}
+	if len(s.values) == 0 {
+		return nil, fmt.Errorf("cannot sample an empty distribution")
+	}
+
+	weights := make([]float64, len(s.values))
+	for i, v := range s.values {
+		weights[i] = d[v]
+	}
+	if err := checkWeights(weights); err != nil {
+		return nil, err
+	}
+	s.table = newAliasTable(weights)
+
+	return s, nil
+}
+
+// Sample returns a value drawn from the distribution using src. A nil src uses the default
+// source.
+func (s *Sampler) Sample(src Source) int {
+	return s.values[s.table.sample(src)]
+}
+
+// NewWeightedDie returns a Die whose faces come up in proportion to weights rather than
+// uniformly. It's rolled in constant time however many faces it has. weights must be the same
+// length as faces, finite, non-negative and sum to more than 0.
+func NewWeightedDie(faces Faces, weights []float64) (Die, error) {
+	if len(faces) == 0 || len(faces) != len(weights) {
+		return Die{}, fmt.Errorf("need one weight per face, got %d faces and %d weights", len(faces), len(weights))
+	}
+	if err := checkWeights(weights); err != nil {
+		return Die{}, err
+	}
+
+	return newDie(faces, weights), nil
+}
+
+// checkWeights returns an error unless weights can build an alias table: finite, non-negative
+// and summing to more than 0
+func checkWeights(weights []float64) error {
+	total := 0.
+	for _, w := range weights {
+		if math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("weights must be finite, got %v", w)
+		}
+		if w < 0 {
+			return fmt.Errorf("weights cannot be negative")
+		}
+		total += w
+	}
+	if math.IsInf(total, 0) {
+		return fmt.Errorf("weights are too large to sum")
+	}
+	if total <= 0 {
+		return fmt.Errorf("weights must sum to more than 0")
+	}
+
+	return nil
+}
+
+// DistributionDie returns a weighted Die with one face for every value of d, numbered and
+// labelled with the value, so that it can be used anywhere a Die can: Roll, Dice, Set and Tables.
+func DistributionDie(d Distribution) (Die, error) {
+	var (
+		values  = d.Values()
+		faces   = make(Faces, len(values))
+		weights = make([]float64, len(values))
+	)
+
+	for i, v := range values {
+		faces[i] = Face{N: v, Value: strconv.Itoa(v)}
+		weights[i] = d[v]
+	}
+
+	return NewWeightedDie(faces, weights)
+}
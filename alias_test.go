@@ -0,0 +1,84 @@
+package roll
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWeightedDieFrequencies(t *testing.T) {
+	const rolls = 200000
+
+	var (
+		faces   = Faces{{N: 1, Value: "a"}, {N: 2, Value: "b"}, {N: 3, Value: "c"}, {N: 4, Value: "d"}, {N: 5, Value: "e"}}
+		weights = []float64{1, 2, 3, 4, 0}
+		total   = 10.
+	)
+
+	d, err := NewWeightedDie(faces, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := rand.New(rand.NewSource(1))
+	counts := make(map[int]int)
+	for i := 0; i < rolls; i++ {
+		counts[d.RollWith(src).N]++
+	}
+
+	for i, f := range faces {
+		p := weights[i] / total
+		got := float64(counts[f.N]) / rolls
+		if math.Abs(got-p) > 5*math.Sqrt(p*(1-p)/rolls) {
+			t.Errorf("face %d came up %.4f of the time, want %.4f", f.N, got, p)
+		}
+	}
+	if counts[5] != 0 {
+		t.Errorf("face with weight 0 came up %d times", counts[5])
+	}
+}
+
+func TestSamplerFrequencies(t *testing.T) {
+	const rolls = 200000
+
+	want, err := MustParse("4d6Kh3").Distribution(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSampler(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := rand.New(rand.NewSource(1))
+	got := make(Distribution)
+	for i := 0; i < rolls; i++ {
+		got[s.Sample(src)]++
+	}
+
+	sameDistribution(t, "sampled 4d6Kh3", got.Normalize(), want, func(p float64) float64 {
+		return 5*math.Sqrt(p*(1-p)/rolls) + 1e-4
+	})
+}
+
+func TestWeightedDieInvalidWeights(t *testing.T) {
+	faces := Faces{{N: 1, Value: "1"}, {N: 2, Value: "2"}}
+
+	for _, weights := range [][]float64{
+		{1},
+		{1, -1},
+		{0, 0},
+		{1, math.NaN()},
+		{1, math.Inf(1)},
+		{math.Inf(-1), 1},
+		{math.MaxFloat64, math.MaxFloat64},
+	} {
+		if _, err := NewWeightedDie(faces, weights); err == nil {
+			t.Errorf("NewWeightedDie accepted weights %v", weights)
+		}
+	}
+
+	if _, err := NewSampler(Distribution{1: math.Inf(1), 2: 0.5}); err == nil {
+		t.Errorf("NewSampler accepted an infinite probability")
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Package anydice runs probability programs in the style of AnyDice. Programs are made of
+numbers, sequences and dice, variables, user functions, loops and output statements and are
+evaluated exactly, each output producing a roll.Distribution:
+
+	\ roll 4d6 and keep the highest three \
+	output [highest 3 of 4d6] named "ability score"
+
+	function: attack ROLL:n against AC:n {
+		if ROLL = 20 { result: 2 }
+		if ROLL + 5 >= AC { result: 1 }
+		result: 0
+	}
+
+	loop AC over {12..18} {
+		output [attack d20 against AC] named "hits against AC [AC]"
+	}
+
+A die passed to a :n parameter runs the function once for each of its outcomes and a pool
+passed to a :s parameter once for each sorted roll of its dice, the results being combined
+into a die. Builtin functions are absolute, contains, count, explode, highest, lowest,
+middle, maximum, reverse and sort. The settings "position order", "explode depth" and
+"maximum function depth" can be changed with set.
+*/
+package anydice
+
+import (
+	"github.com/nboughton/go-roll"
+)
+
+// Output is the distribution of an output statement and its name
+type Output struct {
+	Name string
+	Dist roll.Distribution
+}
+
+// Program is a parsed probability program
+type Program struct {
+	src   string
+	body  []stmt
+	funcs map[string]*function
+}
+
+// Parse parses the program src. Mistakes are reported as an *Error.
+func Parse(src string) (*Program, error) {
+	toks, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, toks: toks, funcs: make(map[string]*function)}
+	body, err := p.parseBlock(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{src: src, body: body, funcs: p.funcs}, nil
+}
+
+// Run runs the program and returns its outputs in the order they were made. Dice of more than
+// 100000 sides, sequences of more than 100000 elements and pools with too many dice or totals to
+// compute are reported as an *Error.
+func (p *Program) Run() ([]Output, error) {
+	in := &interp{
+		prog:         p,
+		globals:      &scope{vars: make(map[string]value)},
+		explodeDepth: 2,
+		maxDepth:     10,
+	}
+
+	if _, _, err := in.exec(p.body, in.globals); err != nil {
+		return nil, err
+	}
+
+	return in.outputs, nil
+}
+
+// Run parses and runs the program src
+func Run(src string) ([]Output, error) {
+	p, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Run()
+}
@@ -0,0 +1,161 @@
+package anydice
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestKnownOutputs checks programs against the results AnyDice itself reports for them
+func TestKnownOutputs(t *testing.T) {
+	for _, c := range []struct {
+		name, src string
+		// names of the outputs, in order, and the mean, min, max and some probabilities of the
+		// first
+		outputs  []string
+		mean     float64
+		min, max int
+		p        map[int]float64
+	}{
+		{
+			name:    "highest 3 of 4d6",
+			src:     `output [highest 3 of 4d6] named "ability score"`,
+			outputs: []string{"ability score"},
+			mean:    15869. / 1296, min: 3, max: 18,
+			p: map[int]float64{3: 1. / 1296, 18: 21. / 1296, 13: 172. / 1296},
+		},
+		{
+			name:    "explode d6",
+			src:     `output [explode d6]`,
+			outputs: []string{"output 1"},
+			mean:    3.5 * 43 / 36, min: 1, max: 18,
+			p: map[int]float64{6: 0, 12: 0, 7: 1. / 36, 13: 1. / 216, 18: 1. / 216},
+		},
+		{
+			name:    "explode depth",
+			src:     "set \"explode depth\" to 1\noutput [explode d6]",
+			outputs: []string{"output 1"},
+			mean:    3.5 * 7 / 6, min: 1, max: 12,
+			p: map[int]float64{6: 0, 12: 1. / 36},
+		},
+		{
+			name:    "explode depth 0",
+			src:     "set \"explode depth\" to 0\noutput [explode d6]",
+			outputs: []string{"output 1"},
+			mean:    3.5, min: 1, max: 6,
+			p: map[int]float64{6: 1. / 6},
+		},
+		{
+			name:    "die of a die",
+			src:     `output 3d(2d4)`,
+			outputs: []string{"output 1"},
+			mean:    15, min: 6, max: 24,
+			p: map[int]float64{6: 1. / 4096, 24: 1. / 4096},
+		},
+		{
+			name:    "custom die",
+			src:     `output d{1,2,2,3}`,
+			outputs: []string{"output 1"},
+			mean:    2, min: 1, max: 3,
+			p: map[int]float64{1: 0.25, 2: 0.5, 3: 0.25},
+		},
+		{
+			name:    "number parameter",
+			src:     "function: square X:n { result: X*X }\noutput [square d4]",
+			outputs: []string{"output 1"},
+			mean:    7.5, min: 1, max: 16,
+			p: map[int]float64{1: 0.25, 4: 0.25, 9: 0.25, 16: 0.25},
+		},
+		{
+			name:    "sequence parameter",
+			src:     "function: top S:s { result: 1@S }\noutput [top 3d6]",
+			outputs: []string{"output 1"},
+			mean:    6 - 225./216, min: 1, max: 6,
+			p: map[int]float64{1: 1. / 216, 6: 91. / 216},
+		},
+		{
+			name: "loop",
+			src: `loop AC over {12..14} {
+	output d20 >= AC named "hit [AC]"
+}`,
+			outputs: []string{"hit 12", "hit 13", "hit 14"},
+			mean:    0.45, min: 0, max: 1,
+			p: map[int]float64{1: 0.45},
+		},
+	} {
+		outs, err := Run(c.src)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if len(outs) != len(c.outputs) {
+			t.Errorf("%s: got %d outputs, want %d", c.name, len(outs), len(c.outputs))
+			continue
+		}
+		for i, o := range outs {
+			if o.Name != c.outputs[i] {
+				t.Errorf("%s: output %d is named %q, want %q", c.name, i, o.Name, c.outputs[i])
+			}
+		}
+
+		d := outs[0].Dist
+		if math.Abs(d.Mean()-c.mean) > 1e-9 {
+			t.Errorf("%s: mean %v, want %v", c.name, d.Mean(), c.mean)
+		}
+		if d.Min() != c.min || d.Max() != c.max {
+			t.Errorf("%s: range %d to %d, want %d to %d", c.name, d.Min(), d.Max(), c.min, c.max)
+		}
+		for v, p := range c.p {
+			if math.Abs(d[v]-p) > 1e-9 {
+				t.Errorf("%s: P(%d) = %v, want %v", c.name, v, d[v], p)
+			}
+		}
+	}
+}
+
+func TestLoopOutputs(t *testing.T) {
+	outs, err := Run(`loop AC over {12..14} { output d20 >= AC named "hit [AC]" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []float64{0.45, 0.4, 0.35} {
+		if got := outs[i].Dist[1]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: P(hit) = %v, want %v", outs[i].Name, got, want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, c := range []struct {
+		src       string
+		line, col int
+		msg       string
+	}{
+		{"output X", 1, 8, "X is not defined"},
+		{"output 1\noutput [frob d6]", 2, 8, "unknown function [frob #]"},
+		{"loop X over d6 { output X }", 1, 1, "cannot loop over a die, loop over a sequence such as {1..6}"},
+		{"if d6 = 1 { output 1 }", 1, 1, "if needs a number not a die, pass the die to a function with a :n parameter"},
+		{"output {1, d6}", 1, 8, "sequences cannot contain dice"},
+		{"output 1 +", 1, 11, "unexpected end of program, expected a number, variable, sequence, die or function call"},
+		{`set "explode depth" to -1`, 1, 1, "explode depth must be a number of 0 or more"},
+		{"output d20000000", 1, 8, "dice can have at most 100000 sides, not 20000000"},
+		{"output {1..1000000000}", 1, 8, "sequences can have at most 100000 elements"},
+		{"output {1..10000:10000}", 1, 8, "sequences can have at most 100000 elements"},
+		{"output 1000000d6", 1, 15, "pools can have at most 10000 dice, not 1000000"},
+		{"output 100d1000", 1, 11, "100 dice with 1000 faces have too many totals to compute"},
+		{"output (d{1, 2000})d6", 1, 20, "2000 dice with 6 faces have too many totals to compute"},
+		{"output [explode 1500d6]", 1, 8, "1500 dice with 16 faces have too many totals to compute"},
+	} {
+		_, err := Run(c.src)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%q: got %v, want an *Error", c.src, err)
+			continue
+		}
+		if e.Line != c.line || e.Col != c.col || e.Msg != c.msg {
+			t.Errorf("%q: got %d:%d %q, want %d:%d %q", c.src, e.Line, e.Col, e.Msg, c.line, c.col, c.msg)
+		}
+	}
+}
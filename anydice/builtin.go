@@ -0,0 +1,160 @@
+package anydice
+
+import (
+	"sort"
+
+	"github.com/nboughton/go-roll"
+)
+
+// builtin is a function provided by the language. Its arguments are converted and expanded in
+// the same way as those of user functions.
+type builtin struct {
+	params []param
+	fn     func(in *interp, args []value) (value, error)
+}
+
+// builtins by key
+var builtins = map[string]builtin{
+	"absolute #": {
+		params: []param{{"N", "n"}},
+		fn: func(in *interp, args []value) (value, error) {
+			if n := args[0].(int); n < 0 {
+				return -n, nil
+			}
+			return args[0], nil
+		},
+	},
+	"contains # in #": {
+		params: []param{{"N", "n"}, {"SEQUENCE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			for _, v := range args[1].(seq) {
+				if v == args[0].(int) {
+					return 1, nil
+				}
+			}
+			return 0, nil
+		},
+	},
+	"count # in #": {
+		params: []param{{"VALUES", "s"}, {"SEQUENCE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			c := 0
+			for _, v := range args[1].(seq) {
+				for _, w := range args[0].(seq) {
+					if v == w {
+						c++
+					}
+				}
+			}
+			return c, nil
+		},
+	},
+	"explode #": {
+		params: []param{{"DIE", "d"}},
+		fn: func(in *interp, args []value) (value, error) {
+			n, base := args[0].(*die).pool()
+			if len(base) == 0 {
+				return args[0], nil
+			}
+
+			// each die that rolls its maximum is rolled again and added, up to explodeDepth times
+			max, e := base.Max(), base
+			for i := 0; i < in.explodeDepth; i++ {
+				next := make(roll.Distribution)
+				for v, p := range base {
+					if v != max {
+						next[v] += p
+						continue
+					}
+					for w, q := range e {
+						next[v+w] += p * q
+					}
+				}
+				e = next
+			}
+			return pool(n, e)
+		},
+	},
+	"highest # of #": {
+		params: []param{{"N", "n"}, {"DICE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			s := sorted(args[1].(seq))
+			return at(positions(1, args[0].(int)), s), nil
+		},
+	},
+	"lowest # of #": {
+		params: []param{{"N", "n"}, {"DICE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			s := sorted(args[1].(seq))
+			return at(positions(len(s)-args[0].(int)+1, len(s)), s), nil
+		},
+	},
+	"middle # of #": {
+		params: []param{{"N", "n"}, {"DICE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			s := sorted(args[1].(seq))
+			from := (len(s)-args[0].(int))/2 + 1
+			return at(positions(from, from+args[0].(int)-1), s), nil
+		},
+	},
+	"highest of # and #": {
+		params: []param{{"A", "n"}, {"B", "n"}},
+		fn: func(in *interp, args []value) (value, error) {
+			if args[0].(int) > args[1].(int) {
+				return args[0], nil
+			}
+			return args[1], nil
+		},
+	},
+	"lowest of # and #": {
+		params: []param{{"A", "n"}, {"B", "n"}},
+		fn: func(in *interp, args []value) (value, error) {
+			if args[0].(int) < args[1].(int) {
+				return args[0], nil
+			}
+			return args[1], nil
+		},
+	},
+	"maximum of #": {
+		params: []param{{"DIE", "d"}},
+		fn: func(in *interp, args []value) (value, error) {
+			d := args[0].(*die)
+			if d.empty() {
+				return 0, nil
+			}
+			return d.dist.Max(), nil
+		},
+	},
+	"reverse #": {
+		params: []param{{"SEQUENCE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			s := args[0].(seq)
+			out := make(seq, len(s))
+			for i, v := range s {
+				out[len(s)-1-i] = v
+			}
+			return out, nil
+		},
+	},
+	"sort #": {
+		params: []param{{"SEQUENCE", "s"}},
+		fn: func(in *interp, args []value) (value, error) {
+			s := append(seq(nil), args[0].(seq)...)
+			if in.lowFirst {
+				sort.Ints(s)
+				return s, nil
+			}
+			return sorted(s), nil
+		},
+	},
+}
+
+// positions returns the sequence {from..to}
+func positions(from, to int) seq {
+	var out seq
+	for i := from; i <= to; i++ {
+		out = append(out, i)
+	}
+
+	return out
+}
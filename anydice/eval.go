@@ -0,0 +1,554 @@
+package anydice
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/nboughton/go-roll"
+)
+
+// interp runs a program, holding its settings and the outputs it has produced
+type interp struct {
+	prog    *Program
+	globals *scope
+	outputs []Output
+
+	lowFirst     bool // "position order" is "lowest first"
+	explodeDepth int
+	maxDepth     int
+	depth        int
+}
+
+// scope holds the variables of the program or of a function call, which can also see the
+// program's variables
+type scope struct {
+	vars   map[string]value
+	parent *scope
+}
+
+func (s *scope) get(name string) (value, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// errorf returns an *Error for the byte offset pos of the program
+func (in *interp) errorf(pos int, format string, args ...interface{}) error {
+	return errorAt(in.prog.src, pos, format, args...)
+}
+
+// exec runs stmts in sc. It returns the value of a result statement and whether one was reached.
+func (in *interp) exec(stmts []stmt, sc *scope) (value, bool, error) {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *assignStmt:
+			v, err := in.eval(s.x, sc)
+			if err != nil {
+				return nil, false, err
+			}
+			sc.vars[s.name] = v
+
+		case *outputStmt:
+			v, err := in.eval(s.x, sc)
+			if err != nil {
+				return nil, false, err
+			}
+			label := s.label
+			if label == "" {
+				label = "output " + strconv.Itoa(len(in.outputs)+1)
+			}
+			in.outputs = append(in.outputs, Output{Name: interpolate(label, sc), Dist: toDie(v).dist})
+
+		case *resultStmt:
+			v, err := in.eval(s.x, sc)
+			return v, err == nil, err
+
+		case *loopStmt:
+			over, err := in.eval(s.over, sc)
+			if err != nil {
+				return nil, false, err
+			}
+			if _, ok := over.(*die); ok {
+				return nil, false, in.errorf(s.pos, "cannot loop over a die, loop over a sequence such as {1..6}")
+			}
+
+			for _, v := range toSeq(over) {
+				sc.vars[s.name] = v
+				if r, done, err := in.exec(s.body, sc); done || err != nil {
+					return r, done, err
+				}
+			}
+
+		case *ifStmt:
+			c, err := in.eval(s.cond, sc)
+			if err != nil {
+				return nil, false, err
+			}
+			if _, ok := c.(*die); ok {
+				return nil, false, in.errorf(s.pos, "if needs a number not a die, pass the die to a function with a :n parameter")
+			}
+
+			body := s.els
+			if number(c) != 0 {
+				body = s.then
+			}
+			if r, done, err := in.exec(body, sc); done || err != nil {
+				return r, done, err
+			}
+
+		case *setStmt:
+			if err := in.set(s, sc); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	return nil, false, nil
+}
+
+// set changes one of the settings: "position order", "explode depth" or "maximum function depth"
+func (in *interp) set(s *setStmt, sc *scope) error {
+	n := 0
+	if s.value != nil {
+		v, err := in.eval(s.value, sc)
+		if err != nil {
+			return err
+		}
+		if _, ok := v.(*die); ok {
+			return in.errorf(s.pos, "setting %q needs a number or string", s.name)
+		}
+		n = number(v)
+	}
+
+	switch s.name {
+	case "position order":
+		switch s.str {
+		case "highest first":
+			in.lowFirst = false
+		case "lowest first":
+			in.lowFirst = true
+		default:
+			return in.errorf(s.pos, "position order must be \"highest first\" or \"lowest first\"")
+		}
+	case "explode depth":
+		if s.value == nil || n < 0 {
+			return in.errorf(s.pos, "explode depth must be a number of 0 or more")
+		}
+		in.explodeDepth = n
+	case "maximum function depth":
+		if s.value == nil || n < 1 {
+			return in.errorf(s.pos, "maximum function depth must be a number of 1 or more")
+		}
+		in.maxDepth = n
+	default:
+		return in.errorf(s.pos, "unknown setting %q", s.name)
+	}
+
+	return nil
+}
+
+var labelVar = regexp.MustCompile(`\[([A-Z_]+)\]`)
+
+// interpolate replaces [NAME] in label with the value of the variable, so that outputs in a loop
+// can be told apart
+func interpolate(label string, sc *scope) string {
+	return labelVar.ReplaceAllStringFunc(label, func(m string) string {
+		v, ok := sc.get(m[1 : len(m)-1])
+		if !ok {
+			return m
+		}
+		if _, ok := v.(*die); ok {
+			return "d"
+		}
+		return strconv.Itoa(number(v))
+	})
+}
+
+func (in *interp) eval(x expr, sc *scope) (value, error) {
+	switch x := x.(type) {
+	case numExpr:
+		return int(x), nil
+
+	case *nameExpr:
+		v, ok := sc.get(x.name)
+		if !ok {
+			return nil, in.errorf(x.pos, "%s is not defined", x.name)
+		}
+		return v, nil
+
+	case *seqExpr:
+		return in.evalSeq(x, sc)
+
+	case *unaryExpr:
+		v, err := in.eval(x.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		return unary(x.op, v), nil
+
+	case *binaryExpr:
+		l, err := in.eval(x.l, sc)
+		if err != nil {
+			return nil, err
+		}
+		r, err := in.eval(x.r, sc)
+		if err != nil {
+			return nil, err
+		}
+
+		if x.op == "@" {
+			return in.at(x, l, r)
+		}
+
+		v, err := binary(x.op, l, r)
+		if err != nil {
+			return nil, in.errorf(x.pos, "%v", err)
+		}
+		return v, nil
+
+	case *diceExpr:
+		return in.evalDice(x, sc)
+
+	case *callExpr:
+		return in.evalCall(x, sc)
+	}
+
+	return nil, fmt.Errorf("unknown expression %T", x)
+}
+
+func (in *interp) evalSeq(x *seqExpr, sc *scope) (value, error) {
+	out := seq{}
+
+	for _, item := range x.items {
+		var (
+			vals  seq
+			times = 1
+		)
+
+		from, err := in.eval(item.from, sc)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := from.(*die); ok {
+			return nil, in.errorf(x.pos, "sequences cannot contain dice")
+		}
+		vals = toSeq(from)
+
+		if item.to != nil {
+			to, err := in.eval(item.to, sc)
+			if err != nil {
+				return nil, err
+			}
+			if float64(number(to))-float64(number(from)) >= maxSeq {
+				return nil, in.errorf(x.pos, "sequences can have at most %d elements", maxSeq)
+			}
+			vals = nil
+			for v := number(from); v <= number(to); v++ {
+				vals = append(vals, v)
+			}
+		}
+
+		if item.repeat != nil {
+			r, err := in.eval(item.repeat, sc)
+			if err != nil {
+				return nil, err
+			}
+			times = number(r)
+		}
+		if float64(len(out))+float64(len(vals))*float64(times) > maxSeq {
+			return nil, in.errorf(x.pos, "sequences can have at most %d elements", maxSeq)
+		}
+
+		for i := 0; i < times; i++ {
+			out = append(out, vals...)
+		}
+	}
+
+	return out, nil
+}
+
+// unary applies -, ! or # to v
+func unary(op string, v value) value {
+	switch op {
+	case "-":
+		if d, ok := v.(*die); ok {
+			return &die{dist: d.dist.Map(func(n int) int { return -n })}
+		}
+		return -number(v)
+
+	case "!":
+		if d, ok := v.(*die); ok {
+			return &die{dist: d.dist.Map(func(n int) int { return truth(n == 0) })}
+		}
+		return truth(number(v) == 0)
+	}
+
+	// # is the number of dice in a pool, elements in a sequence or digits in a number
+	switch v := v.(type) {
+	case *die:
+		n, _ := v.pool()
+		return n
+	case seq:
+		return len(v)
+	}
+
+	return len(digits(v.(int)))
+}
+
+// digits returns the decimal digits of n, most significant first
+func digits(n int) seq {
+	if n < 0 {
+		n = -n
+	}
+
+	var out seq
+	for _, c := range strconv.Itoa(n) {
+		out = append(out, int(c-'0'))
+	}
+
+	return out
+}
+
+// at evaluates positions: pos@seq sums elements of the sequence in written order, pos@number
+// its digits and pos@die the dice of the pool sorted by the position order
+func (in *interp) at(x *binaryExpr, l, r value) (value, error) {
+	if _, ok := l.(*die); ok {
+		return nil, in.errorf(x.pos, "positions must be a number or sequence, not a die")
+	}
+	pos := toSeq(l)
+
+	switch r := r.(type) {
+	case int:
+		return at(pos, digits(r)), nil
+	case seq:
+		return at(pos, r), nil
+	}
+
+	out := make(roll.Distribution)
+	err := outcomes(r.(*die), in.lowFirst, func(s seq, p float64) error {
+		out[at(pos, s)] += p
+		return nil
+	})
+	if err != nil {
+		return nil, in.errorf(x.pos, "%v", err)
+	}
+
+	return &die{dist: out}, nil
+}
+
+func (in *interp) evalDice(x *diceExpr, sc *scope) (value, error) {
+	sides, err := in.eval(x.sides, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	var base roll.Distribution
+	switch s := sides.(type) {
+	case int:
+		if s < 1 {
+			return &die{dist: roll.Point(0)}, nil
+		}
+		if s > maxSides {
+			return nil, in.errorf(x.pos, "dice can have at most %d sides, not %d", maxSides, s)
+		}
+		faces := make([]int, s)
+		for i := range faces {
+			faces[i] = i + 1
+		}
+		base = uniform(faces).dist
+	case seq:
+		base = uniform(s).dist
+	case *die:
+		base = s.dist
+	}
+
+	if x.count == nil {
+		return &die{dist: base, n: 1, base: base}, nil
+	}
+
+	count, err := in.eval(x.count, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, ok := count.(*die); ok {
+		out := make(roll.Distribution)
+		for n, p := range c.dist {
+			d, err := pool(n, base)
+			if err != nil {
+				return nil, in.errorf(x.pos, "%v", err)
+			}
+			for v, q := range d.dist {
+				out[v] += p * q
+			}
+		}
+		return &die{dist: out}, nil
+	}
+
+	d, err := pool(number(count), base)
+	if err != nil {
+		return nil, in.errorf(x.pos, "%v", err)
+	}
+
+	return d, nil
+}
+
+// pool returns the die of n dice with distribution base, negated if n is negative. It returns
+// an error if the pool has more than maxDice dice or, having several, can roll more than
+// maxOutcomes totals.
+func pool(n int, base roll.Distribution) (*die, error) {
+	if n > maxDice || n < -maxDice {
+		return nil, fmt.Errorf("pools can have at most %d dice, not %d", maxDice, n)
+	}
+	if (n > 1 || n < -1) && len(base) > 0 {
+		k := float64(n)
+		if n < 0 {
+			k = -k
+		}
+		// the totals are no more than the range of the pool or its sorted rolls
+		totals := math.Min(k*(float64(base.Max())-float64(base.Min()))+1, multisets(int(k), len(base)))
+		if totals > maxOutcomes {
+			return nil, fmt.Errorf("%d dice with %d faces have too many totals to compute", int(k), len(base))
+		}
+	}
+
+	switch {
+	case n == 0:
+		return &die{dist: roll.Point(0)}, nil
+	case n < 0:
+		return &die{dist: base.Repeat(-n).Map(func(v int) int { return -v })}, nil
+	}
+
+	return &die{dist: base.Repeat(n), n: n, base: base}, nil
+}
+
+func (in *interp) evalCall(x *callExpr, sc *scope) (value, error) {
+	var args []value
+	for _, a := range x.args {
+		v, err := in.eval(a, sc)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	if f, ok := in.prog.funcs[x.key]; ok {
+		return in.call(x.pos, f.params, args, func(bound []value) (value, error) {
+			fs := &scope{vars: make(map[string]value), parent: in.globals}
+			for i, p := range f.params {
+				fs.vars[p.name] = bound[i]
+			}
+
+			in.depth++
+			defer func() { in.depth-- }()
+
+			v, _, err := in.exec(f.body, fs)
+			return v, err
+		})
+	}
+
+	if b, ok := builtins[x.key]; ok {
+		return in.call(x.pos, b.params, args, func(bound []value) (value, error) {
+			return b.fn(in, bound)
+		})
+	}
+
+	return nil, in.errorf(x.pos, "unknown function [%s]", x.key)
+}
+
+// call runs fn with args converted to the types of params. A die passed to a number parameter
+// runs fn for each of its outcomes, and to a sequence parameter for each sorted roll of its
+// dice; the results are combined into a die weighted by the probability of each. Results that
+// are the empty die, d{}, are left out so that functions can discard outcomes.
+func (in *interp) call(pos int, params []param, args []value, fn func([]value) (value, error)) (value, error) {
+	if in.depth >= in.maxDepth {
+		return &die{dist: roll.Distribution{}}, nil
+	}
+
+	var (
+		bound    = make([]value, len(args))
+		out      = make(roll.Distribution)
+		expanded bool
+		walk     func(i int, p float64) (value, error)
+	)
+	walk = func(i int, p float64) (value, error) {
+		if i == len(args) {
+			v, err := fn(append([]value(nil), bound...))
+			if err != nil || !expanded {
+				return v, err
+			}
+
+			for n, q := range toDie(v).dist {
+				out[n] += p * q
+			}
+			return nil, nil
+		}
+
+		arg := args[i]
+		d, isDie := arg.(*die)
+
+		switch {
+		case params[i].typ == "n" && isDie:
+			expanded = true
+			for _, n := range d.dist.Values() {
+				bound[i] = n
+				if _, err := walk(i+1, p*d.dist[n]); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+
+		case params[i].typ == "s" && isDie:
+			expanded = true
+			return nil, outcomes(d, in.lowFirst, func(s seq, q float64) error {
+				bound[i] = s
+				_, err := walk(i+1, p*q)
+				return err
+			})
+
+		case params[i].typ == "n":
+			bound[i] = number(arg)
+		case params[i].typ == "s":
+			bound[i] = toSeq(arg)
+		case params[i].typ == "d":
+			bound[i] = toDie(arg)
+		default:
+			bound[i] = arg
+		}
+
+		return walk(i+1, p)
+	}
+
+	v, err := walk(0, 1)
+	if err != nil {
+		if _, ok := err.(*Error); !ok {
+			err = in.errorf(pos, "%v", err)
+		}
+		return nil, err
+	}
+	if !expanded {
+		if v == nil {
+			return &die{dist: roll.Distribution{}}, nil
+		}
+		return v, nil
+	}
+
+	if out.Total() > 0 {
+		out = out.Normalize()
+	}
+	return &die{dist: out}, nil
+}
+
+// sorted returns a copy of s sorted highest first
+func sorted(s seq) seq {
+	out := append(seq(nil), s...)
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	return out
+}
@@ -0,0 +1,119 @@
+package anydice
+
+import (
+	"fmt"
+	"strings"
+)
+
+// token kinds produced by the scanner
+type tokenKind int
+
+const (
+	tokEOF  tokenKind = iota
+	tokNum            // 12
+	tokName           // X, DICE, a variable or parameter
+	tokWord           // highest, output, a keyword or part of a function name
+	tokStr            // "a label"
+	tokSym            // operators and punctuation
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// symbols in the order they're matched, longest first
+var symbols = []string{"..", "!=", "<=", ">=", "{", "}", "[", "]", "(", ")", ",", ":", "+", "-", "*", "/", "^", "=", "<", ">", "&", "|", "!", "#", "@"}
+
+// scan splits src into tokens, skipping whitespace and \comments\
+func scan(src string) ([]token, error) {
+	var toks []token
+
+	for pos := 0; pos < len(src); {
+		c := src[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+
+		case c == '\\':
+			end := strings.IndexByte(src[pos+1:], '\\')
+			if end < 0 {
+				return nil, errorAt(src, pos, "unterminated comment")
+			}
+			pos += end + 2
+
+		case c == '"':
+			end := strings.IndexByte(src[pos+1:], '"')
+			if end < 0 {
+				return nil, errorAt(src, pos, "unterminated string")
+			}
+			toks = append(toks, token{tokStr, src[pos+1 : pos+1+end], pos})
+			pos += end + 2
+
+		case c >= '0' && c <= '9':
+			toks = append(toks, token{tokNum, run(src, pos, isDigit), pos})
+			pos += len(toks[len(toks)-1].text)
+
+		case c >= 'A' && c <= 'Z' || c == '_':
+			toks = append(toks, token{tokName, run(src, pos, isUpper), pos})
+			pos += len(toks[len(toks)-1].text)
+
+		case c >= 'a' && c <= 'z':
+			toks = append(toks, token{tokWord, run(src, pos, isLower), pos})
+			pos += len(toks[len(toks)-1].text)
+
+		default:
+			sym := ""
+			for _, s := range symbols {
+				if strings.HasPrefix(src[pos:], s) {
+					sym = s
+					break
+				}
+			}
+			if sym == "" {
+				return nil, errorAt(src, pos, "unexpected %q", c)
+			}
+			toks = append(toks, token{tokSym, sym, pos})
+			pos += len(sym)
+		}
+	}
+
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isUpper(c byte) bool { return c >= 'A' && c <= 'Z' || c == '_' }
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
+
+// run returns the characters of src from pos for which fn is true
+func run(src string, pos int, fn func(byte) bool) string {
+	end := pos
+	for end < len(src) && fn(src[end]) {
+		end++
+	}
+
+	return src[pos:end]
+}
+
+// Error is a mistake in a program, positioned by line and column from 1
+type Error struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// errorAt returns an *Error for the byte offset pos of src
+func errorAt(src string, pos int, format string, args ...interface{}) error {
+	if pos > len(src) {
+		pos = len(src)
+	}
+
+	line := strings.Count(src[:pos], "\n") + 1
+	col := pos - strings.LastIndexByte(src[:pos], '\n')
+
+	return &Error{Line: line, Col: col, Msg: fmt.Sprintf(format, args...)}
+}
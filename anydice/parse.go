@@ -0,0 +1,562 @@
+package anydice
+
+import (
+	"strconv"
+	"strings"
+)
+
+// expr is an expression node, evaluated by eval
+type expr interface{}
+
+type (
+	numExpr  int
+	nameExpr struct {
+		name string
+		pos  int
+	}
+	seqExpr struct {
+		items []seqItem
+		pos   int
+	}
+	// seqItem is an element of a sequence literal: from, from..to, and either repeated with :n
+	seqItem struct {
+		from, to, repeat expr
+	}
+	unaryExpr struct {
+		op  string
+		x   expr
+		pos int
+	}
+	binaryExpr struct {
+		op   string
+		l, r expr
+		pos  int
+	}
+	// diceExpr is count d sides, count is nil for a single die
+	diceExpr struct {
+		count, sides expr
+		pos          int
+	}
+	callExpr struct {
+		key  string
+		args []expr
+		pos  int
+	}
+)
+
+// stmt is a statement node, executed by exec
+type stmt interface{}
+
+type (
+	assignStmt struct {
+		name string
+		x    expr
+	}
+	outputStmt struct {
+		x     expr
+		label string
+		pos   int
+	}
+	loopStmt struct {
+		name string
+		over expr
+		body []stmt
+		pos  int
+	}
+	ifStmt struct {
+		cond      expr
+		then, els []stmt
+		pos       int
+	}
+	resultStmt struct {
+		x expr
+	}
+	setStmt struct {
+		name  string
+		value expr   // a number
+		str   string // or a string
+		pos   int
+	}
+)
+
+// function is a user defined function. Its key is its name with # in place of each parameter,
+// i.e "highest # of #".
+type function struct {
+	key    string
+	params []param
+	body   []stmt
+}
+
+// param is a function parameter with its type: n for a number, s for a sequence, d for a die
+// or empty to take its argument as it is
+type param struct {
+	name, typ string
+}
+
+// parser is a recursive descent parser over the output of scan
+type parser struct {
+	src   string
+	toks  []token
+	pos   int
+	funcs map[string]*function
+}
+
+// peek returns the next token, which is tokEOF from the end of the program on
+func (p *parser) peek() token {
+	if p.pos >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+
+	return p.toks[p.pos]
+}
+
+// next consumes the next token. It can be put back with p.pos--, even at the end of the program.
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+
+	return t
+}
+
+// is reports whether the next token is the symbol or word s
+func (p *parser) is(s string) bool {
+	t := p.peek()
+	return (t.kind == tokSym || t.kind == tokWord) && t.text == s
+}
+
+// expect consumes the symbol or word s
+func (p *parser) expect(s string) error {
+	if !p.is(s) {
+		return p.unexpected("expected " + s)
+	}
+	p.next()
+
+	return nil
+}
+
+func (p *parser) unexpected(want string) error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return errorAt(p.src, t.pos, "unexpected end of program, %s", want)
+	}
+
+	return errorAt(p.src, t.pos, "unexpected %s, %s", t.text, want)
+}
+
+// parseBlock reads statements until a closing } or the end of the program
+func (p *parser) parseBlock(top bool) ([]stmt, error) {
+	var out []stmt
+
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF && top:
+			return out, nil
+		case t.kind == tokEOF:
+			return nil, p.unexpected("expected }")
+		case p.is("}") && !top:
+			p.next()
+			return out, nil
+		}
+
+		s, err := p.parseStmt(top)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			out = append(out, s)
+		}
+	}
+}
+
+func (p *parser) parseStmt(top bool) (stmt, error) {
+	t := p.next()
+
+	switch {
+	case t.kind == tokName:
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		return &assignStmt{name: t.text, x: x}, err
+
+	case t.kind != tokWord:
+		p.pos--
+		return nil, p.unexpected("expected a statement")
+
+	case t.text == "output":
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		s := &outputStmt{x: x, pos: t.pos}
+		if p.is("named") {
+			p.next()
+			l := p.next()
+			if l.kind != tokStr {
+				p.pos--
+				return nil, p.unexpected("expected a name in quotes")
+			}
+			s.label = l.text
+		}
+		return s, nil
+
+	case t.text == "result":
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		return &resultStmt{x: x}, err
+
+	case t.text == "loop":
+		name := p.next()
+		if name.kind != tokName {
+			p.pos--
+			return nil, p.unexpected("expected a variable to loop with")
+		}
+		if err := p.expect("over"); err != nil {
+			return nil, err
+		}
+		over, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		body, err := p.parseBlock(false)
+		return &loopStmt{name: name.text, over: over, body: body, pos: t.pos}, err
+
+	case t.text == "if":
+		return p.parseIf(t.pos)
+
+	case t.text == "set":
+		name := p.next()
+		if name.kind != tokStr {
+			p.pos--
+			return nil, p.unexpected("expected a setting in quotes")
+		}
+		if err := p.expect("to"); err != nil {
+			return nil, err
+		}
+		s := &setStmt{name: name.text, pos: t.pos}
+		if p.peek().kind == tokStr {
+			s.str = p.next().text
+			return s, nil
+		}
+		var err error
+		s.value, err = p.parseExpr()
+		return s, err
+
+	case t.text == "function" && top:
+		return nil, p.parseFunction()
+	}
+
+	p.pos--
+	return nil, p.unexpected("expected a statement")
+}
+
+func (p *parser) parseIf(pos int) (stmt, error) {
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	s := &ifStmt{cond: cond, pos: pos}
+	if s.then, err = p.parseBlock(false); err != nil {
+		return nil, err
+	}
+
+	if !p.is("else") {
+		return s, nil
+	}
+	p.next()
+
+	if p.is("if") {
+		t := p.next()
+		els, err := p.parseIf(t.pos)
+		s.els = []stmt{els}
+		return s, err
+	}
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	s.els, err = p.parseBlock(false)
+	return s, err
+}
+
+// parseFunction reads a function definition such as
+// function: highest N:n of DICE:s { ... }
+func (p *parser) parseFunction() error {
+	start := p.peek().pos
+	if err := p.expect(":"); err != nil {
+		return err
+	}
+
+	var (
+		f   = &function{}
+		key []string
+	)
+	for !p.is("{") {
+		t := p.next()
+		switch t.kind {
+		case tokWord:
+			key = append(key, t.text)
+
+		case tokName:
+			prm := param{name: t.text}
+			if p.is(":") {
+				p.next()
+				typ := p.next()
+				if typ.kind != tokWord || (typ.text != "n" && typ.text != "s" && typ.text != "d") {
+					p.pos--
+					return p.unexpected("expected a parameter type of n, s or d")
+				}
+				prm.typ = typ.text
+			}
+			f.params = append(f.params, prm)
+			key = append(key, "#")
+
+		default:
+			p.pos--
+			return p.unexpected("expected a function name or parameter")
+		}
+	}
+	p.next()
+
+	f.key = strings.Join(key, " ")
+	if len(f.params) == len(key) {
+		return errorAt(p.src, start, "function needs at least one word in its name")
+	}
+	if _, ok := p.funcs[f.key]; ok {
+		return errorAt(p.src, start, "function [%s] is already defined", f.key)
+	}
+	p.funcs[f.key] = f
+
+	var err error
+	f.body, err = p.parseBlock(false)
+	return err
+}
+
+// Operators by precedence, loosest first
+var binaryOps = [][]string{
+	{"|"},
+	{"&"},
+	{"=", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/"},
+	{"^"},
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseBinary(0)
+}
+
+func (p *parser) parseBinary(level int) (expr, error) {
+	if level == len(binaryOps) {
+		return p.parseUnary()
+	}
+
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokSym || !contains(binaryOps[level], t.text) {
+			return l, nil
+		}
+		p.next()
+
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: t.text, l: l, r: r, pos: t.pos}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if t := p.peek(); t.kind == tokSym && (t.text == "-" || t.text == "!" || t.text == "#") {
+		p.next()
+		x, err := p.parseUnary()
+		return &unaryExpr{op: t.text, x: x, pos: t.pos}, err
+	}
+
+	return p.parseAt()
+}
+
+// parseAt reads positions such as 1@3d6
+func (p *parser) parseAt() (expr, error) {
+	l, err := p.parseDice()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is("@") {
+		t := p.next()
+		r, err := p.parseDice()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "@", l: l, r: r, pos: t.pos}
+	}
+
+	return l, nil
+}
+
+// parseDice reads d6, 3d6 and 2d{1,2,3}
+func (p *parser) parseDice() (expr, error) {
+	var (
+		x   expr
+		err error
+	)
+
+	if p.is("d") {
+		t := p.next()
+		sides, err := p.parseSides()
+		if err != nil {
+			return nil, err
+		}
+		x = &diceExpr{sides: sides, pos: t.pos}
+	} else if x, err = p.parsePrimary(); err != nil {
+		return nil, err
+	}
+
+	for p.is("d") {
+		t := p.next()
+		sides, err := p.parseSides()
+		if err != nil {
+			return nil, err
+		}
+		x = &diceExpr{count: x, sides: sides, pos: t.pos}
+	}
+
+	return x, nil
+}
+
+// parseSides reads the sides of a die, which may themselves be a die as in d(d6)
+func (p *parser) parseSides() (expr, error) {
+	if p.is("d") {
+		return p.parseDice()
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+
+	switch {
+	case t.kind == tokNum:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, errorAt(p.src, t.pos, "%s is too large", t.text)
+		}
+		return numExpr(n), nil
+
+	case t.kind == tokName:
+		return &nameExpr{name: t.text, pos: t.pos}, nil
+
+	case t.kind == tokSym && t.text == "(":
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+
+	case t.kind == tokSym && t.text == "{":
+		return p.parseSeq(t.pos)
+
+	case t.kind == tokSym && t.text == "[":
+		return p.parseCall(t.pos)
+	}
+
+	p.pos--
+	return nil, p.unexpected("expected a number, variable, sequence, die or function call")
+}
+
+// parseSeq reads the rest of a sequence literal such as {1..3, 5:2}
+func (p *parser) parseSeq(pos int) (expr, error) {
+	s := &seqExpr{pos: pos}
+	if p.is("}") {
+		p.next()
+		return s, nil
+	}
+
+	for {
+		var (
+			item seqItem
+			err  error
+		)
+		if item.from, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if p.is("..") {
+			p.next()
+			if item.to, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is(":") {
+			p.next()
+			if item.repeat, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		s.items = append(s.items, item)
+
+		if p.is("}") {
+			p.next()
+			return s, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCall reads the rest of a function call such as [highest 2 of 4d6]. Words make up the
+// name of the function and anything else is an argument.
+func (p *parser) parseCall(pos int) (expr, error) {
+	var (
+		c   = &callExpr{pos: pos}
+		key []string
+	)
+
+	for !p.is("]") {
+		if t := p.peek(); t.kind == tokWord && t.text != "d" {
+			key = append(key, p.next().text)
+			continue
+		}
+		if p.peek().kind == tokEOF {
+			return nil, p.unexpected("expected ]")
+		}
+
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, x)
+		key = append(key, "#")
+	}
+	p.next()
+
+	c.key = strings.Join(key, " ")
+	return c, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
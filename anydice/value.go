@@ -0,0 +1,294 @@
+package anydice
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nboughton/go-roll"
+)
+
+// A value is a number (int), a sequence (seq) or a die (*die)
+type value interface{}
+
+// seq is a sequence of numbers such as {1, 2, 3}
+type seq []int
+
+// die is the distribution of a die or pool of dice. Pools made with NdX remember the number and
+// distribution of their dice so that positions, such as 1@3d6, and sequence parameters can see
+// the individual dice.
+type die struct {
+	dist roll.Distribution
+	n    int               // dice in the pool, 0 if the die isn't a pool
+	base roll.Distribution // distribution of each die in the pool
+}
+
+// pool returns the number of dice in d and the distribution of each
+func (d *die) pool() (int, roll.Distribution) {
+	if d.n > 0 {
+		return d.n, d.base
+	}
+
+	return 1, d.dist
+}
+
+// empty reports whether d has no outcomes, as d{} does
+func (d *die) empty() bool {
+	return len(d.dist) == 0
+}
+
+func (s seq) sum() int {
+	t := 0
+	for _, v := range s {
+		t += v
+	}
+
+	return t
+}
+
+// uniform returns a die rolling each of faces with equal probability. Repeated faces are
+// proportionally more likely.
+func uniform(faces []int) *die {
+	d := make(roll.Distribution)
+	for _, f := range faces {
+		d[f] += 1 / float64(len(faces))
+	}
+
+	return &die{dist: d}
+}
+
+// toDie converts v to a die, a number or sequence becoming a die that always rolls it (or its sum)
+func toDie(v value) *die {
+	switch v := v.(type) {
+	case *die:
+		return v
+	case seq:
+		return &die{dist: roll.Point(v.sum())}
+	case int:
+		return &die{dist: roll.Point(v)}
+	}
+
+	return &die{dist: roll.Distribution{}}
+}
+
+// toSeq converts v to a sequence, a number becoming a sequence of one
+func toSeq(v value) seq {
+	switch v := v.(type) {
+	case seq:
+		return v
+	case int:
+		return seq{v}
+	}
+
+	return nil
+}
+
+// describe returns the type of v for error messages
+func describe(v value) string {
+	switch v.(type) {
+	case int:
+		return "number"
+	case seq:
+		return "sequence"
+	}
+
+	return "die"
+}
+
+// arith applies a binary operator to two numbers
+func arith(op string, a, b int) (int, error) {
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	case "^":
+		if b < 0 {
+			return 0, nil
+		}
+		return int(math.Pow(float64(a), float64(b))), nil
+	case "=":
+		return truth(a == b), nil
+	case "!=":
+		return truth(a != b), nil
+	case "<":
+		return truth(a < b), nil
+	case "<=":
+		return truth(a <= b), nil
+	case ">":
+		return truth(a > b), nil
+	case ">=":
+		return truth(a >= b), nil
+	case "&":
+		return truth(a != 0 && b != 0), nil
+	case "|":
+		return truth(a != 0 || b != 0), nil
+	}
+
+	return 0, fmt.Errorf("unknown operator %s", op)
+}
+
+func truth(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// isComparison reports whether op compares its operands
+func isComparison(op string) bool {
+	return contains(binaryOps[2], op)
+}
+
+// binary applies op to l and r. Dice combine every outcome of each side, a sequence compared
+// with a number counts the elements for which the comparison holds, and otherwise sequences
+// are summed.
+func binary(op string, l, r value) (value, error) {
+	_, ld := l.(*die)
+	_, rd := r.(*die)
+	if ld || rd {
+		var err error
+		out := toDie(l).dist.Combine(toDie(r).dist, func(a, b int) int {
+			v, e := arith(op, a, b)
+			if e != nil {
+				err = e
+			}
+			return v
+		})
+		return &die{dist: out}, err
+	}
+
+	if s, ok := l.(seq); ok && isComparison(op) {
+		if n, ok := r.(int); ok {
+			return count(op, s, n, false)
+		}
+	}
+	if s, ok := r.(seq); ok && isComparison(op) {
+		if n, ok := l.(int); ok {
+			return count(op, s, n, true)
+		}
+	}
+
+	return arith(op, number(l), number(r))
+}
+
+// count returns the number of elements e of s for which e op n holds, or n op e if flip is set
+func count(op string, s seq, n int, flip bool) (int, error) {
+	c := 0
+	for _, e := range s {
+		a, b := e, n
+		if flip {
+			a, b = n, e
+		}
+		v, err := arith(op, a, b)
+		if err != nil {
+			return 0, err
+		}
+		c += v
+	}
+
+	return c, nil
+}
+
+// number returns v as a number, summing a sequence. It must not be a die.
+func number(v value) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case seq:
+		return v.sum()
+	}
+
+	return 0
+}
+
+// at returns the sum of the elements of s at the 1-based positions in pos. Positions outside s
+// count as 0.
+func at(pos seq, s seq) int {
+	t := 0
+	for _, p := range pos {
+		if p >= 1 && p <= len(s) {
+			t += s[p-1]
+		}
+	}
+
+	return t
+}
+
+// maxMultisets is the most sorted outcomes of a pool that will be enumerated
+const maxMultisets = 1000000
+
+// As roll.DefaultLimits do for dice strings, these bound the dice and sequences a program can
+// build so that programs from untrusted users can't exhaust memory or time
+const (
+	maxSides    = 100000 // sides of a die
+	maxDice     = 10000  // dice in a pool
+	maxSeq      = 100000 // elements of a sequence
+	maxOutcomes = 10000  // totals a pool of several dice can roll
+)
+
+// outcomes calls fn with every sorted sequence of rolls of the dice of d and its probability.
+// Sequences are sorted highest first unless lowFirst is set.
+func outcomes(d *die, lowFirst bool, fn func(s seq, p float64) error) error {
+	n, base := d.pool()
+	faces := base.Values()
+	if !lowFirst {
+		sort.Sort(sort.Reverse(sort.IntSlice(faces)))
+	}
+
+	if multisets(n, len(faces)) > maxMultisets {
+		return fmt.Errorf("%dd with %d faces has too many outcomes to enumerate", n, len(faces))
+	}
+
+	// lnFact[i] is log(i!), for the multinomial coefficient of each multiset
+	lnFact := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		lnFact[i] = lnFact[i-1] + math.Log(float64(i))
+	}
+
+	var (
+		rolls = make(seq, 0, n)
+		walk  func(i, left int, lnP float64) error
+	)
+	walk = func(i, left int, lnP float64) error {
+		if left == 0 {
+			return fn(append(seq(nil), rolls...), math.Exp(lnFact[n]+lnP))
+		}
+		if i == len(faces) {
+			return nil
+		}
+
+		lp := math.Log(base[faces[i]])
+		for k := left; k >= 0; k-- {
+			for j := 0; j < k; j++ {
+				rolls = append(rolls, faces[i])
+			}
+			if err := walk(i+1, left-k, lnP+float64(k)*lp-lnFact[k]); err != nil {
+				return err
+			}
+			rolls = rolls[:len(rolls)-k]
+		}
+
+		return nil
+	}
+
+	return walk(0, n, 0)
+}
+
+// multisets returns the number of multisets of n items drawn from k kinds
+func multisets(n, k int) float64 {
+	if k == 0 {
+		return 0
+	}
+
+	lg := func(x float64) float64 { v, _ := math.Lgamma(x); return v }
+	return math.Exp(lg(float64(n+k)) - lg(float64(n+1)) - lg(float64(k)))
+}
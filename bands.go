@@ -0,0 +1,113 @@
+package roll
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Band labels an inclusive range of values, such as the 7-9 "weak hit" of a Powered by the
+// Apocalypse move
+type Band struct {
+	Label    string
+	Min, Max int
+}
+
+// Bands is a set of labelled ranges used to describe the degree of success of an Outcome
+type Bands []Band
+
+// Standard band sets, also available in dice strings by name, i.e 2d6+1 [pbta]
+var (
+	// PbtA is the 6-/7-9/10+ split of Powered by the Apocalypse moves
+	PbtA = Bands{
+		{"miss", math.MinInt32, 6},
+		{"weak hit", 7, 9},
+		{"strong hit", 10, math.MaxInt32},
+	}
+	// PF2 is the Pathfinder Second Edition degrees of success, applied to the margin by which
+	// a check beats its DC, i.e 1d20+7 >= 18 [pf2]
+	PF2 = Bands{
+		{"critical failure", math.MinInt32, -10},
+		{"failure", -9, -1},
+		{"success", 0, 9},
+		{"critical success", 10, math.MaxInt32},
+	}
+
+	namedBands = map[string]Bands{
+		"pbta": PbtA,
+		"pf2":  PF2,
+	}
+)
+
+// Label returns the label of the first band that contains n, or an empty string if none do
+func (b Bands) Label(n int) string {
+	for _, band := range b {
+		if n >= band.Min && n <= band.Max {
+			return band.Label
+		}
+	}
+
+	return ""
+}
+
+func (b Bands) String() string {
+	var out []string
+
+	for _, band := range b {
+		var lo, hi string
+		if band.Min != math.MinInt32 {
+			lo = strconv.Itoa(band.Min)
+		}
+		if band.Max != math.MaxInt32 {
+			hi = strconv.Itoa(band.Max)
+		}
+		out = append(out, fmt.Sprintf("%s:%s..%s", band.Label, lo, hi))
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// ParseBands reads either the name of a standard band set (pbta, pf2) or a comma separated list
+// of label:range pairs where a range is lo..hi, ..hi, lo.. or a single number. For example
+// "miss:..6, weak hit:7..9, strong hit:10..".
+func ParseBands(s string) (Bands, error) {
+	s = strings.TrimSpace(s)
+	if b, ok := namedBands[strings.ToLower(s)]; ok {
+		return b, nil
+	}
+
+	var b Bands
+	for _, item := range strings.Split(s, ",") {
+		i := strings.LastIndex(item, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("band %q: expected label:range", strings.TrimSpace(item))
+		}
+
+		band := Band{Label: strings.TrimSpace(item[:i]), Min: math.MinInt32, Max: math.MaxInt32}
+		if band.Label == "" {
+			return nil, fmt.Errorf("band %q: missing label", strings.TrimSpace(item))
+		}
+
+		var err error
+		rng := strings.TrimSpace(item[i+1:])
+		if dots := strings.Index(rng, ".."); dots >= 0 {
+			if lo := strings.TrimSpace(rng[:dots]); lo != "" {
+				band.Min, err = strconv.Atoi(lo)
+			}
+			if hi := strings.TrimSpace(rng[dots+2:]); hi != "" && err == nil {
+				band.Max, err = strconv.Atoi(hi)
+			}
+		} else {
+			band.Min, err = strconv.Atoi(rng)
+			band.Max = band.Min
+		}
+		if err != nil || band.Min > band.Max {
+			return nil, fmt.Errorf("band %s: invalid range %q", band.Label, rng)
+		}
+
+		b = append(b, band)
+	}
+
+	return b, nil
+}
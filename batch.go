@@ -0,0 +1,282 @@
+package roll
+
+import "sort"
+
+// RollInto rolls n Die into buf, reusing its capacity, and returns the filled slice. It's
+// intended for simulations that roll the same dice many times and want to avoid allocating a
+// new Result for every roll.
+func RollInto(buf Faces, n int, d Die) Faces {
+	buf = buf[:0]
+
+	for i := 0; i < n; i++ {
+		buf = append(buf, d.Roll())
+	}
+
+	return buf
+}
+
+// RollN evaluates the expression n times and calls fn with each total. Totals are the same as
+// the Total of the Outcome returned by Roll but no Results are recorded, which allows buffers to
+// be reused between rolls so that each roll allocates little or nothing. Variables evaluate as 0,
+// use EvalN to bind them. As with Roll, no dice are rolled if the expression's Cost exceeds its
+// Limits.
+func (e *Expr) RollN(n int, fn func(total int)) {
+	var (
+		ev   = &evaluator{source: e.source, limits: e.limits, batch: true}
+		cost = e.limits.Check(e.Cost(nil))
+	)
+
+	for i := 0; i < n; i++ {
+		ev.dice, ev.err = 0, cost
+		fn(e.total(ev))
+	}
+}
+
+// EvalN is RollN with variables bound from vars. A *MissingVariableError is returned, without
+// rolling any dice, if the expression references a variable that isn't in vars. Each roll is
+// checked against the expression's Limits and EvalN stops with a *LimitError at the first roll
+// that exceeds them, or with the error of a Manual Source at the first roll it fails.
+func (e *Expr) EvalN(n int, vars Vars, fn func(total int)) error {
+	for _, name := range e.Vars() {
+		if _, ok := vars[name]; !ok {
+			return &MissingVariableError{Name: name}
+		}
+	}
+
+	if err := e.limits.Check(e.Cost(vars)); err != nil {
+		return err
+	}
+
+	ev := &evaluator{vars: vars, source: e.source, limits: e.limits, batch: true}
+	for i := 0; i < n; i++ {
+		ev.dice = 0
+
+		t := e.total(ev)
+		if ev.err == nil {
+			ev.err = sourceErr(e.source)
+		}
+		if ev.err != nil {
+			return ev.err
+		}
+		fn(t)
+	}
+
+	return nil
+}
+
+// total evaluates the expression's Total without building an Outcome
+func (e *Expr) total(ev *evaluator) int {
+	if c, ok := unwrap(e.root).(*cmpNode); ok {
+		l, _, _ := c.compare(ev)
+		return l
+	}
+
+	return e.root.eval(ev)
+}
+
+// total rolls the term into the evaluator's buffer, applies its modifiers and returns the sum.
+// It mirrors roll but works on face numbers only.
+func (d *diceNode) total(ev *evaluator) int {
+	n := d.count.eval(ev)
+
+	var sides int
+	if d.die.faces == nil {
+		sides = d.sides.eval(ev)
+	}
+
+	if !ev.valid(d, n, sides) || !ev.start(n, sides) {
+		n = 0
+	}
+
+	ev.buf = ev.buf[:0]
+	for i := 0; i < n; i++ {
+		ev.buf = append(ev.buf, d.rollInt(ev.source, sides))
+	}
+
+	rolled := n
+	for _, m := range d.mods {
+		rolled += m.applyInts(ev, d, sides)
+	}
+	ev.rolled(rolled)
+
+	t := 0
+	for _, v := range ev.buf {
+		t += v
+	}
+
+	return t
+}
+
+// rollInt rolls a single die of the term. sides is only used when the term's sides aren't a
+// literal, in which case the die is numbered 1 to sides.
+func (d *diceNode) rollInt(src Source, sides int) int {
+	if d.die.faces != nil {
+		return d.die.RollWith(src).N
+	}
+
+	return intn(src, sides) + 1
+}
+
+// applyInts is apply for the face numbers in ev.buf, modifying the buffer in place. It returns
+// the number of dice rolled by explosions and rerolls.
+func (m modifier) applyInts(ev *evaluator, d *diceNode, sides int) int {
+	switch m.kind {
+	case modKeep, modDrop:
+		if m.n < 1 || m.n > len(ev.buf) {
+			return 0
+		}
+		ev.buf = selectInts(ev, m.n, m.hl, m.kind == modKeep)
+
+	case modKeepN, modDropN:
+		out := ev.buf[:0]
+		for _, v := range ev.buf {
+			if matches(v, m.match) == (m.kind == modKeepN) {
+				out = append(out, v)
+			}
+		}
+		ev.buf = out
+
+	case modExplode:
+		// gen tracks how many explosions deep each die is when the depth is limited
+		depth := ev.limits.MaxExplodeDepth
+		if depth > 0 {
+			ev.gen = ev.gen[:0]
+			for range ev.buf {
+				ev.gen = append(ev.gen, 0)
+			}
+		}
+
+		added := 0
+		for i := 0; i < len(ev.buf); i++ {
+			if !matches(ev.buf[i], m.match) {
+				continue
+			}
+
+			if depth > 0 {
+				if ev.gen[i] >= depth {
+					ev.limit("MaxExplodeDepth", depth)
+					continue
+				}
+				ev.gen = append(ev.gen, ev.gen[i]+1)
+			}
+			if max := ev.limits.MaxDice; max > 0 && len(ev.buf) >= max {
+				ev.limit("MaxDice", max)
+				break
+			}
+
+			ev.buf = append(ev.buf, d.rollInt(ev.source, sides))
+			added++
+		}
+
+		return added
+
+	case modReroll, modRerollOnce:
+		once, depth := m.kind == modRerollOnce, ev.limits.MaxExplodeDepth
+		if once {
+			depth = 1
+		} else if !d.escapes(m.match, sides) {
+			return 0
+		}
+
+		added := 0
+		for i := range ev.buf {
+			for n := 0; matches(ev.buf[i], m.match); n++ {
+				if depth > 0 && n >= depth {
+					if !once {
+						ev.limit("MaxExplodeDepth", depth)
+					}
+					break
+				}
+				ev.buf[i] = d.rollInt(ev.source, sides)
+				added++
+			}
+		}
+
+		return added
+	}
+
+	return 0
+}
+
+// escapes reports whether a die of the term can roll a number that isn't in match. sides is
+// used as for rollInt.
+func (d *diceNode) escapes(match []int, sides int) bool {
+	if d.die.faces != nil {
+		return d.die.escapes(match)
+	}
+
+	for n := 1; n <= sides; n++ {
+		if !matches(n, match) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectInts keeps (or drops) the n values of ev.buf selected by hl, preserving order and
+// breaking ties by position in the same way as pick, without allocating once ev.tmp has grown.
+func selectInts(ev *evaluator, n int, hl MatchType, keep bool) []int {
+	var (
+		buf  = ev.buf
+		l    = len(buf)
+		a, b int // selected range of positions, by roll order or by sorted value
+	)
+
+	switch hl {
+	case HIGH:
+		a, b = l-n, l
+	case LOW, FIRST:
+		a, b = 0, n
+	case LAST:
+		a, b = l-n, l
+	case MIDDLE:
+		a = (l - n + 1) / 2
+		b = a + n
+	}
+
+	out := buf[:0]
+	if hl == FIRST || hl == LAST {
+		for i, v := range buf {
+			if (i >= a && i < b) == keep {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+
+	ev.tmp = append(ev.tmp[:0], buf...)
+	sort.Ints(ev.tmp)
+
+	// Every value strictly between the bounds is selected. Values equal to a bound are selected
+	// by their rank among equal values, in roll order, as a stable sort would.
+	lo, hi := ev.tmp[a], ev.tmp[b-1]
+	loFirst := sort.SearchInts(ev.tmp, lo)
+	hiFirst := sort.SearchInts(ev.tmp, hi)
+
+	loSeen, hiSeen := 0, 0
+	for _, v := range buf {
+		var sel bool
+		switch {
+		case v > lo && v < hi:
+			sel = true
+		case v == lo || v == hi:
+			// rank of this value among equal values in sorted order
+			var rank int
+			if v == lo {
+				rank = loFirst + loSeen
+				loSeen++
+			} else {
+				rank = hiFirst + hiSeen
+				hiSeen++
+			}
+			sel = rank >= a && rank < b
+		}
+
+		if sel == keep {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
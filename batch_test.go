@@ -0,0 +1,81 @@
+package roll
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var batchExprs = []struct {
+	name, dice string
+}{
+	{"Plain", "3d6+2"},
+	{"KeepDrop", "4d6Kh3+2d20Dl1"},
+	{"Explode", "10d6X6"},
+}
+
+// TestBatchAllocs checks that a batch only allocates as its buffers grow, not for every roll
+func TestBatchAllocs(t *testing.T) {
+	const rolls = 1000
+
+	for _, c := range batchExprs {
+		e := MustParse(c.dice).WithSource(rand.New(rand.NewSource(1)))
+
+		if a := testing.AllocsPerRun(10, func() { e.RollN(rolls, func(int) {}) }); a > 20 {
+			t.Errorf("%s: RollN of %d rolls made %.0f allocations", c.dice, rolls, a)
+		}
+		if a := testing.AllocsPerRun(10, func() { e.EvalN(rolls, nil, func(int) {}) }); a > 20 {
+			t.Errorf("%s: EvalN of %d rolls made %.0f allocations", c.dice, rolls, a)
+		}
+	}
+
+	buf := make(Faces, 0, 10)
+	if a := testing.AllocsPerRun(100, func() { buf = RollInto(buf, 10, D6) }); a > 0 {
+		t.Errorf("RollInto made %.0f allocations", a)
+	}
+}
+
+func BenchmarkRollN(b *testing.B) {
+	for _, c := range batchExprs {
+		b.Run(c.name, func(b *testing.B) {
+			e := MustParse(c.dice).WithSource(rand.New(rand.NewSource(1)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			e.RollN(b.N, func(int) {})
+		})
+	}
+}
+
+func BenchmarkEvalN(b *testing.B) {
+	for _, c := range batchExprs {
+		b.Run(c.name, func(b *testing.B) {
+			e := MustParse(c.dice).WithSource(rand.New(rand.NewSource(1)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			if err := e.EvalN(b.N, nil, func(int) {}); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// BenchmarkRoll is the same expressions rolled one Outcome at a time, for comparison
+func BenchmarkRoll(b *testing.B) {
+	for _, c := range batchExprs {
+		b.Run(c.name, func(b *testing.B) {
+			e := MustParse(c.dice).WithSource(rand.New(rand.NewSource(1)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.Roll()
+			}
+		})
+	}
+}
+
+func BenchmarkRollInto(b *testing.B) {
+	buf := make(Faces, 0, 10)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = RollInto(buf, 10, D6)
+	}
+}
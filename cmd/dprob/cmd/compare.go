@@ -0,0 +1,209 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/nboughton/go-roll/cmd/internal/simflag"
+	"github.com/nboughton/go-roll/sim"
+	"github.com/spf13/cobra"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compare the distributions of dice strings",
+	Long: `Compare the distribution of the first dice string with each of the others, reporting whether
+they're equal, how far apart they are and the probability of every value of each:
+
+	dprob compare -d 2d6 -d 1d6+1d6
+	dprob compare -d 1d20 -d 2d20Kh1 -d 3d6+1`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			dice, _   = cmd.Flags().GetStringArray("dice")
+			labels, _ = cmd.Flags().GetStringArray("label")
+			opts      = simflag.Options(cmd)
+		)
+
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if len(dice) < 2 {
+			return fmt.Errorf("give at least two dice strings to compare")
+		}
+
+		var (
+			dists = make([]roll.Distribution, len(dice))
+			exact = make([]bool, len(dice))
+		)
+		for i, s := range dice {
+			e, err := roll.Parse(s)
+			if err != nil {
+				return err
+			}
+			if dists[i], exact[i], err = sim.Distribution(context.Background(), e, opts); err != nil {
+				return err
+			}
+		}
+
+		var out []comparisonOutput
+		for i := 1; i < len(dice); i++ {
+			out = append(out, comparisonOutput{
+				A:          compareLabel(dice, labels, 0),
+				B:          compareLabel(dice, labels, i),
+				Exact:      exact[0] && exact[i],
+				Comparison: roll.Compare(dists[0], dists[i]),
+			})
+		}
+
+		switch format {
+		case output.JSON:
+			return output.WriteJSON(os.Stdout, out)
+		case output.CSV:
+			var rows [][]string
+			for _, c := range out {
+				for _, v := range c.Comparison.Values {
+					rows = append(rows, []string{
+						c.A,
+						c.B,
+						output.Itoa(v.Value),
+						output.Ftoa(v.A),
+						output.Ftoa(v.B),
+						output.Ftoa(v.Diff),
+					})
+				}
+			}
+			return output.WriteCSV(os.Stdout, []string{"a", "b", "value", "p_a", "p_b", "diff"}, rows)
+		default:
+			for i, c := range out {
+				if i > 0 {
+					fmt.Println()
+				}
+				describeComparison(c)
+			}
+		}
+
+		return nil
+	},
+}
+
+// comparisonOutput is the schema used for json output, one per dice string compared with the
+// first
+type comparisonOutput struct {
+	A          string          `json:"a"`
+	B          string          `json:"b"`
+	Exact      bool            `json:"exact"`
+	Comparison roll.Comparison `json:"comparison"`
+}
+
+func compareLabel(dice, labels []string, i int) string {
+	if len(labels) > i {
+		return labels[i]
+	}
+
+	return dice[i]
+}
+
+// describeComparison prints a comparison as a verdict, a table of statistics and a table of the
+// probability of every value
+func describeComparison(o comparisonOutput) {
+	c := o.Comparison
+
+	verdict := "different"
+	switch {
+	case c.Equal:
+		verdict = "equal"
+	case c.SameShape:
+		verdict = "the same shape over different values"
+	}
+	if !o.Exact {
+		verdict += " (estimated)"
+	}
+	fmt.Printf("%s vs %s: %s\n", o.A, o.B, verdict)
+	fmt.Printf("total variation %.2f%%, KL divergence %s, reverse %s\n\n", c.TotalVariation*100, bits(c.KL), bits(c.KLReverse))
+
+	fmt.Fprintf(tw, "\t%s\t%s\tdiff\n", o.A, o.B)
+	for _, s := range []struct {
+		name string
+		roll.StatDiff
+	}{{"mean", c.Mean}, {"variance", c.Variance}, {"stddev", c.StdDev}} {
+		fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%+.3f\n", s.name, s.A, s.B, unsigned(s.Diff, 3))
+	}
+	for _, p := range c.Percentiles {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\n", percentileName(p.Q), p.A, p.B, p.Diff)
+	}
+	tw.Flush()
+	fmt.Println()
+
+	fmt.Fprintf(tw, "value\t%s\t%s\tdiff\n", o.A, o.B)
+	for _, v := range c.Values {
+		fmt.Fprintf(tw, "%d\t%.2f%%\t%.2f%%\t%+.2f%%\n", v.Value, v.A*100, v.B*100, unsigned(v.Diff*100, 2))
+	}
+	tw.Flush()
+}
+
+// unsigned returns 0 for an f that rounds to zero at prec decimal places, so that rounding error
+// prints as +0.000 rather than -0.000
+func unsigned(f float64, prec int) float64 {
+	if math.Abs(f) < 0.5*math.Pow(10, -float64(prec)) {
+		return 0
+	}
+
+	return f
+}
+
+func percentileName(q float64) string {
+	switch q {
+	case 0:
+		return "min"
+	case 0.5:
+		return "median"
+	case 1:
+		return "max"
+	}
+
+	return "p" + strconv.FormatFloat(q*100, 'f', -1, 64)
+}
+
+func bits(f float64) string {
+	if math.IsInf(f, 0) {
+		return "infinite"
+	}
+
+	return fmt.Sprintf("%.4f bits", f)
+}
+
+func init() {
+	compareCmd.Flags().StringArrayP("dice", "d", []string{"2d6", "1d6+1d6"}, "Dice strings to compare, the first with each of the others")
+	compareCmd.Flags().StringArrayP("label", "l", []string{}, "Labels for results, these are applied to their respective dice strings")
+	simflag.AddFlags(compareCmd)
+	output.AddFlag(compareCmd)
+	rootCmd.AddCommand(compareCmd)
+}
@@ -0,0 +1,146 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var designCmd = &cobra.Command{
+	Use:   "design",
+	Short: "Find dice strings that match a target mean, variance, range or distribution",
+	Long: `Find dice strings that match a target mean, variance, range or distribution by trying every
+pool of dice, keep and modifier within limits and ranking them by how far they miss:
+
+	dprob design --mean 10 --variance 3 --sizes 6,8
+	dprob design --like 1d20 --sizes 6 --keep --mod-min -5 --mod-max 5`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			mean, _     = cmd.Flags().GetFloat64("mean")
+			variance, _ = cmd.Flags().GetFloat64("variance")
+			min, _      = cmd.Flags().GetInt("min")
+			max, _      = cmd.Flags().GetInt("max")
+			like, _     = cmd.Flags().GetString("like")
+			sizes, _    = cmd.Flags().GetIntSlice("sizes")
+			maxDice, _  = cmd.Flags().GetInt("max-dice")
+			maxTypes, _ = cmd.Flags().GetInt("max-types")
+			keep, _     = cmd.Flags().GetBool("keep")
+			modMin, _   = cmd.Flags().GetInt("mod-min")
+			modMax, _   = cmd.Flags().GetInt("mod-max")
+			top, _      = cmd.Flags().GetInt("top")
+		)
+
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		var goals []roll.DesignGoal
+		if cmd.Flags().Changed("mean") {
+			goals = append(goals, roll.DesignMean(mean))
+		}
+		if cmd.Flags().Changed("variance") {
+			goals = append(goals, roll.DesignVariance(variance))
+		}
+		if cmd.Flags().Changed("min") || cmd.Flags().Changed("max") {
+			if !cmd.Flags().Changed("min") || !cmd.Flags().Changed("max") {
+				return fmt.Errorf("give both --min and --max for a range")
+			}
+			goals = append(goals, roll.DesignRange(min, max))
+		}
+		if like != "" {
+			e, err := roll.Parse(like)
+			if err != nil {
+				return err
+			}
+			d, err := e.Distribution(nil)
+			if err != nil {
+				return err
+			}
+			goals = append(goals, roll.DesignDistribution(d))
+		}
+		if len(goals) == 0 {
+			return fmt.Errorf("give at least one of --mean, --variance, --min and --max or --like")
+		}
+
+		found, err := roll.Designer(roll.Design{
+			Dice:     sizes,
+			MaxDice:  maxDice,
+			MaxTypes: maxTypes,
+			Keep:     keep,
+			MinMod:   modMin,
+			MaxMod:   modMax,
+			Top:      top,
+		}, goals...)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case output.JSON:
+			return output.WriteJSON(os.Stdout, found)
+		case output.CSV:
+			var rows [][]string
+			for _, c := range found {
+				rows = append(rows, []string{
+					c.Expr,
+					output.Ftoa(c.Mean),
+					output.Ftoa(c.StdDev),
+					output.Itoa(c.Min),
+					output.Itoa(c.Max),
+					output.Ftoa(c.Miss),
+				})
+			}
+			return output.WriteCSV(os.Stdout, []string{"expr", "mean", "stddev", "min", "max", "miss"}, rows)
+		default:
+			fmt.Fprintln(tw, "dice\tmean\tvariance\tstddev\trange\tmiss")
+			for _, c := range found {
+				fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.2f\t%d..%d\t%.3f\n", c.Expr, c.Mean, c.StdDev*c.StdDev, c.StdDev, c.Min, c.Max, c.Miss)
+			}
+			tw.Flush()
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	designCmd.Flags().Float64("mean", 0, "Target mean")
+	designCmd.Flags().Float64("variance", 0, "Target variance, 0 for as little spread as possible")
+	designCmd.Flags().Int("min", 0, "Target lowest total, given with --max")
+	designCmd.Flags().Int("max", 0, "Target highest total, given with --min")
+	designCmd.Flags().String("like", "", "Dice string whose distribution to match, i.e 1d20")
+	designCmd.Flags().IntSlice("sizes", roll.DesignDice, "Die sizes to use")
+	designCmd.Flags().Int("max-dice", 6, "Most dice to roll")
+	designCmd.Flags().Int("max-types", 2, "Most die sizes to mix")
+	designCmd.Flags().Bool("keep", false, "Also try keeping the highest or lowest of a pool, i.e 4d6Kh3")
+	designCmd.Flags().Int("mod-min", 0, "Lowest flat modifier to try")
+	designCmd.Flags().Int("mod-max", 0, "Highest flat modifier to try")
+	designCmd.Flags().Int("top", 10, "Number of matches to show")
+	output.AddFlag(designCmd)
+	rootCmd.AddCommand(designCmd)
+}
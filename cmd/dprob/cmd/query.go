@@ -0,0 +1,151 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/nboughton/go-roll/sim"
+)
+
+// queryFlag is a repeatable --query flag, parsed as each is given so that mistakes are reported
+// before any dice are rolled
+type queryFlag struct {
+	queries []*roll.Query
+}
+
+func (f *queryFlag) String() string {
+	var out []string
+	for _, q := range f.queries {
+		out = append(out, strconv.Quote(q.String()))
+	}
+
+	return "[" + strings.Join(out, ",") + "]"
+}
+
+func (f *queryFlag) Set(s string) error {
+	q, err := roll.ParseQuery(s)
+	if err != nil {
+		return err
+	}
+	f.queries = append(f.queries, q)
+
+	return nil
+}
+
+func (f *queryFlag) Type() string { return "query" }
+
+// answerOutput is the schema used for json and csv output of --query, one per dice string and
+// query
+type answerOutput struct {
+	Label string `json:"label"`
+	Dice  string `json:"dice"`
+	roll.Answer
+
+	mean bool
+}
+
+var answerHeader = []string{"label", "dice", "query", "p", "stderr", "exact", "win", "tie", "lose", "given", "rolls"}
+
+// answerQueries answers every query about every dice string, exactly where the distributions
+// can be computed and by simulation otherwise
+func answerQueries(dice, labels []string, queries []*roll.Query, opts sim.Options, format output.Format) error {
+	var answers []answerOutput
+	for i, s := range dice {
+		e, err := roll.Parse(s)
+		if err != nil {
+			return err
+		}
+
+		l := s
+		if len(labels) > i {
+			l = labels[i]
+		}
+
+		for _, q := range queries {
+			a, err := sim.Answer(context.Background(), e, q, opts)
+			if err != nil {
+				return err
+			}
+			answers = append(answers, answerOutput{Label: l, Dice: s, Answer: a, mean: q.IsMean()})
+		}
+	}
+
+	switch format {
+	case output.JSON:
+		return output.WriteJSON(os.Stdout, answers)
+	case output.CSV:
+		var rows [][]string
+		for _, a := range answers {
+			rows = append(rows, []string{
+				a.Label,
+				a.Dice,
+				a.Query,
+				output.Ftoa(a.P),
+				output.Ftoa(a.StdErr),
+				strconv.FormatBool(a.Exact),
+				output.Ftoa(a.Win),
+				output.Ftoa(a.Tie),
+				output.Ftoa(a.Lose),
+				output.Ftoa(a.Given),
+				output.Itoa(a.Rolls),
+			})
+		}
+		return output.WriteCSV(os.Stdout, answerHeader, rows)
+	default:
+		for _, a := range answers {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", a.Label, a.Query, describeAnswer(a))
+		}
+		tw.Flush()
+	}
+
+	return nil
+}
+
+// describeAnswer formats an answer for text output, with a 95% error bar if it was simulated
+func describeAnswer(a answerOutput) string {
+	scale, unit := 100., "%"
+	if a.mean {
+		scale, unit = 1, ""
+	}
+
+	s := fmt.Sprintf("%.2f%s", a.P*scale, unit)
+	if a.Exact {
+		s += " (exact)"
+	} else {
+		s += fmt.Sprintf(" ±%.2f%s", a.StdErr*z95*scale, unit)
+	}
+
+	if a.Win+a.Tie+a.Lose > 0 {
+		s += fmt.Sprintf("\twin %.2f%%, tie %.2f%%, lose %.2f%%", a.Win*100, a.Tie*100, a.Lose*100)
+	}
+	if a.Given < 1 {
+		s += fmt.Sprintf("\tcondition met %.2f%% of the time", a.Given*100)
+	}
+
+	return s
+}
@@ -21,61 +21,164 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"text/tabwriter"
 
 	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/nboughton/go-roll/cmd/internal/simflag"
+	"github.com/nboughton/go-roll/sim"
 	"github.com/spf13/cobra"
 )
 
 var tw = tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
 
+// z95 is the z-score of a 95% confidence interval
+const z95 = 1.96
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "dprob",
-	Short: "Calculate the probability of rolling a desired set of numbers from a dice string",
-	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:           "dprob",
+	Short:         "Calculate the probability of rolling a desired set of numbers from a dice string",
+	Long:          ``,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			dice, _   = cmd.Flags().GetStringArray("dice")
 			labels, _ = cmd.Flags().GetStringArray("label")
 			want, _   = cmd.Flags().GetIntSlice("want")
-			rolls, _  = cmd.Flags().GetInt("rolls")
+			queries   = cmd.Flag("query").Value.(*queryFlag).queries
+			opts      = simflag.Options(cmd)
 		)
 
-		for i, s := range dice {
-			var results roll.Results
-			for j := 0; j < rolls; j++ {
-				result, err := roll.FromString(s)
-				if err != nil {
-					fmt.Println(err)
-					return
-				}
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			return err
+		}
 
-				results = append(results, result)
+		if len(queries) > 0 {
+			return answerQueries(dice, labels, queries, opts, format)
+		}
+
+		var probs []probOutput
+		for i, s := range dice {
+			e, err := roll.Parse(s)
+			if err != nil {
+				return err
 			}
 
-			p := 0
-			for _, r := range results {
-				for _, w := range want {
-					if r.Sum() == w {
-						p++
-					}
-				}
+			h, err := sim.Run(context.Background(), e, opts)
+			if err != nil {
+				return err
 			}
 
 			l := s
 			if len(labels) > i {
 				l = labels[i]
 			}
-			fmt.Fprintf(tw, "%s\t==\t%v\t%.2f%%\n", l, want, float64(p)/float64(rolls)*100)
+
+			probs = append(probs, newProbOutput(l, s, want, h))
+		}
+
+		switch format {
+		case output.JSON:
+			return output.WriteJSON(os.Stdout, probs)
+		case output.CSV:
+			return output.WriteCSV(os.Stdout, csvHeader, csvRows(probs))
+		default:
+			for _, p := range probs {
+				fmt.Fprintf(tw, "%s\t==\t%v\t%.2f%%\t±%.2f%%\n", p.Label, p.Want, p.Probability*100, p.StdErr*z95*100)
+			}
+			tw.Flush()
 		}
 
-		tw.Flush()
+		return nil
 	},
 }
 
+// probOutput is the schema used for json and csv output. Probabilities are expressed as
+// fractions between 0 and 1, with their standard error and 95% confidence interval.
+type probOutput struct {
+	Label        string        `json:"label"`
+	Dice         string        `json:"dice"`
+	Rolls        int           `json:"rolls"`
+	Want         []int         `json:"want"`
+	Probability  float64       `json:"probability"`
+	StdErr       float64       `json:"stderr"`
+	Distribution []valueOutput `json:"distribution"`
+}
+
+// valueOutput is the observed frequency of a single total
+type valueOutput struct {
+	Value       int     `json:"value"`
+	Count       int     `json:"count"`
+	Probability float64 `json:"probability"`
+	StdErr      float64 `json:"stderr"`
+	CILow       float64 `json:"ci_low"`
+	CIHigh      float64 `json:"ci_high"`
+}
+
+var csvHeader = []string{"label", "dice", "rolls", "want_probability", "want_stderr", "value", "count", "probability", "stderr", "ci_low", "ci_high"}
+
+func newProbOutput(label, dice string, want []int, h *sim.Histogram) probOutput {
+	out := probOutput{
+		Label: label,
+		Dice:  dice,
+		Rolls: h.Rolls,
+		Want:  want,
+		Probability: h.PFunc(func(v int) bool {
+			for _, w := range want {
+				if v == w {
+					return true
+				}
+			}
+			return false
+		}),
+	}
+	out.StdErr = sim.StdErr(out.Probability, h.Rolls)
+
+	for _, v := range h.Values() {
+		lo, hi := h.CI(v)
+		out.Distribution = append(out.Distribution, valueOutput{
+			Value:       v,
+			Count:       h.Counts[v],
+			Probability: h.P(v),
+			StdErr:      h.StdErr(v),
+			CILow:       lo,
+			CIHigh:      hi,
+		})
+	}
+
+	return out
+}
+
+func csvRows(probs []probOutput) [][]string {
+	var rows [][]string
+
+	for _, p := range probs {
+		for _, v := range p.Distribution {
+			rows = append(rows, []string{
+				p.Label,
+				p.Dice,
+				output.Itoa(p.Rolls),
+				output.Ftoa(p.Probability),
+				output.Ftoa(p.StdErr),
+				output.Itoa(v.Value),
+				output.Itoa(v.Count),
+				output.Ftoa(v.Probability),
+				output.Ftoa(v.StdErr),
+				output.Ftoa(v.CILow),
+				output.Ftoa(v.CIHigh),
+			})
+		}
+	}
+
+	return rows
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -88,6 +191,8 @@ func Execute() {
 func init() {
 	rootCmd.Flags().StringArrayP("dice", "d", []string{"1d10", "2d10Kl1"}, "Dice strings to test")
 	rootCmd.Flags().StringArrayP("label", "l", []string{}, "Labels for results, these are applied to their respective dice strings")
-	rootCmd.Flags().IntP("rolls", "r", 100000, "Number of times to roll each dice set")
+	simflag.AddFlags(rootCmd)
 	rootCmd.Flags().IntSliceP("want", "w", []int{9, 10}, "Numbers to test for")
+	rootCmd.Flags().VarP(&queryFlag{}, "query", "q", "Queries to answer instead of --want, i.e \">= 15\", \"between 7 and 9\", \"mean given at least one die shows 6\" or \"vs 1d20+3\". May be repeated")
+	output.AddFlag(rootCmd)
 }
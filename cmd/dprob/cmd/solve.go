@@ -0,0 +1,195 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/nboughton/go-roll/cmd/internal/simflag"
+	"github.com/nboughton/go-roll/sim"
+	"github.com/spf13/cobra"
+)
+
+// solveGoals maps the --goal flag to a roll.SolveGoal
+var solveGoals = map[string]roll.SolveGoal{
+	"closest": roll.Closest,
+	"atleast": roll.AtLeast,
+	"atmost":  roll.AtMost,
+}
+
+var solveCmd = &cobra.Command{
+	Use:   "solve",
+	Short: "Find the value of a variable that gives a target probability",
+	Long: `Find the value of a variable that gives a target probability, such as a DC, a modifier, a
+number of dice or a die size, by answering a query for every value in a range:
+
+	dprob solve -d 1d20+7 -q '>= $dc' --target 65%
+	dprob solve -d '$n d10' -q 'at least 3 dice show 8+' --target 80% --goal atleast`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			dice, _   = cmd.Flags().GetString("dice")
+			query, _  = cmd.Flags().GetString("query")
+			name, _   = cmd.Flags().GetString("for")
+			min, _    = cmd.Flags().GetInt("min")
+			max, _    = cmd.Flags().GetInt("max")
+			target, _ = cmd.Flags().GetString("target")
+			goal, _   = cmd.Flags().GetString("goal")
+			vars, _   = cmd.Flags().GetStringToInt("var")
+			opts      = simflag.Options(cmd)
+		)
+
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		e, err := roll.Parse(dice)
+		if err != nil {
+			return err
+		}
+		q, err := roll.ParseQuery(query)
+		if err != nil {
+			return err
+		}
+
+		s := roll.Search{Var: strings.TrimPrefix(name, "$"), Min: min, Max: max, Vars: roll.Vars(vars)}
+		if s.Goal, err = parseGoal(goal); err != nil {
+			return err
+		}
+		if s.Target, err = parseTarget(target, q.IsMean()); err != nil {
+			return err
+		}
+		if s.Var == "" {
+			if s.Var, err = unboundVar(e, q, s.Vars); err != nil {
+				return err
+			}
+		}
+
+		sol, err := sim.Solve(context.Background(), e, q, s, opts)
+		if err != nil && len(sol.Tried) == 0 {
+			return err
+		}
+
+		switch format {
+		case output.JSON:
+			return output.WriteJSON(os.Stdout, sol)
+		case output.CSV:
+			var rows [][]string
+			for _, t := range sol.Tried {
+				rows = append(rows, []string{
+					output.Itoa(t.Value),
+					output.Ftoa(t.Answer.P),
+					output.Ftoa(t.Answer.StdErr),
+					strconv.FormatBool(t.Answer.Exact),
+					strconv.FormatBool(err == nil && t.Value == sol.Value),
+				})
+			}
+			return output.WriteCSV(os.Stdout, []string{s.Var, "p", "stderr", "exact", "best"}, rows)
+		default:
+			for _, t := range sol.Tried {
+				mark := ""
+				if err == nil && t.Value == sol.Value {
+					mark = "<-"
+				}
+				fmt.Fprintf(tw, "$%s = %d\t%s\t%s\n", s.Var, t.Value, describeAnswer(answerOutput{Answer: t.Answer, mean: q.IsMean()}), mark)
+			}
+			tw.Flush()
+
+			if err != nil {
+				return err
+			}
+			fmt.Printf("$%s = %d: %s\n", s.Var, sol.Value, describeAnswer(answerOutput{Answer: sol.Answer, mean: q.IsMean()}))
+		}
+
+		return nil
+	},
+}
+
+func parseGoal(s string) (roll.SolveGoal, error) {
+	g, ok := solveGoals[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown goal %q: must be one of closest, atleast or atmost", s)
+	}
+
+	return g, nil
+}
+
+// parseTarget reads a probability as a percentage (65%) or fraction (0.65), a number over 1
+// being taken as a percentage unless the query asks for a mean
+func parseTarget(s string, mean bool) (float64, error) {
+	pct := strings.HasSuffix(s, "%")
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("can't read target %q, i.e 65%% or 0.65", s)
+	}
+	if pct || !mean && f > 1 {
+		f /= 100
+	}
+
+	return f, nil
+}
+
+// unboundVar returns the only variable of e and q that isn't in vars
+func unboundVar(e *roll.Expr, q *roll.Query, vars roll.Vars) (string, error) {
+	var free []string
+	for _, name := range append(e.Vars(), q.Vars()...) {
+		if _, ok := vars[name]; !ok && !contains(free, name) {
+			free = append(free, name)
+		}
+	}
+
+	if len(free) != 1 {
+		return "", fmt.Errorf("give the variable to solve for with --for, the dice and query have %d unbound variables", len(free))
+	}
+
+	return free[0], nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	solveCmd.Flags().StringP("dice", "d", "1d20+7", "Dice string, which may reference the variable to solve for as $name")
+	solveCmd.Flags().StringP("query", "q", ">= $dc", "Query to answer for each value, which may reference the variable to solve for")
+	solveCmd.Flags().String("for", "", "Variable to solve for, the only unbound variable if not given")
+	solveCmd.Flags().Int("min", 1, "Lowest value to try")
+	solveCmd.Flags().Int("max", 40, "Highest value to try")
+	solveCmd.Flags().StringP("target", "t", "50%", "Probability to look for, i.e 65% or 0.65, or the total for a mean query")
+	solveCmd.Flags().StringP("goal", "g", "closest", "closest, atleast for the lowest probability at or above the target or atmost for the highest at or below it")
+	solveCmd.Flags().StringToInt("var", map[string]int{}, "Values for other variables, i.e --var prof=2,str=3")
+	simflag.AddFlags(solveCmd)
+	output.AddFlag(solveCmd)
+	rootCmd.AddCommand(solveCmd)
+}
@@ -0,0 +1,243 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/anydice"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+var tw = tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+
+// barWidth is the length of the bar drawn for the most likely value of a table
+const barWidth = 50
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "dprog [program file]",
+	Short: "Run an AnyDice style probability program and print the distribution of each output",
+	Long: `Run an AnyDice style probability program, read from a file or given with --exec, and print
+the exact distribution of each of its outputs as a table, optionally plotting them as a png.
+
+	dprog -e 'output [highest 3 of 4d6] named "ability score"'`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			src, _   = cmd.Flags().GetString("exec")
+			mode, _  = cmd.Flags().GetString("mode")
+			graph, _ = cmd.Flags().GetString("graph")
+		)
+
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		switch {
+		case len(args) == 1 && src != "":
+			fmt.Println("give either a program file or --exec, not both")
+			return
+		case len(args) == 1:
+			b, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			src = string(b)
+		case src == "":
+			fmt.Println("give a program file or --exec")
+			return
+		}
+
+		cumulative, ok := modes[mode]
+		if !ok {
+			fmt.Printf("unknown mode %q: must be one of normal, atleast or atmost\n", mode)
+			return
+		}
+
+		outs, err := anydice.Run(src)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		var tables []tableOutput
+		for _, o := range outs {
+			tables = append(tables, newTableOutput(o, cumulative))
+		}
+
+		if graph != "" {
+			if err := plotTables(graph, mode, tables); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+
+		switch format {
+		case output.JSON:
+			err = output.WriteJSON(os.Stdout, tables)
+		case output.CSV:
+			err = output.WriteCSV(os.Stdout, csvHeader, csvRows(tables))
+		default:
+			printTables(tables)
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	},
+}
+
+// modes maps the --mode flag to the probability shown for each value: of rolling it exactly,
+// at least it or at most it
+var modes = map[string]func(d roll.Distribution, v int) float64{
+	"normal":  roll.Distribution.P,
+	"atleast": roll.Distribution.AtLeast,
+	"atmost":  roll.Distribution.AtMost,
+}
+
+// tableOutput is the schema used for json and csv output, one per output statement.
+// Probabilities are expressed as fractions between 0 and 1.
+type tableOutput struct {
+	Name   string        `json:"name"`
+	Mean   float64       `json:"mean"`
+	StdDev float64       `json:"stddev"`
+	Min    int           `json:"min"`
+	Max    int           `json:"max"`
+	Values []valueOutput `json:"values"`
+}
+
+// valueOutput is the probability of a single value
+type valueOutput struct {
+	Value       int     `json:"value"`
+	Probability float64 `json:"probability"`
+}
+
+var csvHeader = []string{"name", "mean", "stddev", "value", "probability"}
+
+func newTableOutput(o anydice.Output, p func(roll.Distribution, int) float64) tableOutput {
+	out := tableOutput{Name: o.Name}
+	if len(o.Dist) == 0 {
+		return out
+	}
+
+	out.Mean, out.StdDev, out.Min, out.Max = o.Dist.Mean(), o.Dist.StdDev(), o.Dist.Min(), o.Dist.Max()
+	for _, v := range o.Dist.Values() {
+		out.Values = append(out.Values, valueOutput{Value: v, Probability: p(o.Dist, v)})
+	}
+
+	return out
+}
+
+func csvRows(tables []tableOutput) [][]string {
+	var rows [][]string
+
+	for _, t := range tables {
+		for _, v := range t.Values {
+			rows = append(rows, []string{t.Name, output.Ftoa(t.Mean), output.Ftoa(t.StdDev), output.Itoa(v.Value), output.Ftoa(v.Probability)})
+		}
+	}
+
+	return rows
+}
+
+// printTables prints each table with a bar for every value scaled to the most likely
+func printTables(tables []tableOutput) {
+	for i, t := range tables {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s (mean %.2f, sd %.2f, min %d, max %d)\n", t.Name, t.Mean, t.StdDev, t.Min, t.Max)
+
+		top := 0.
+		for _, v := range t.Values {
+			if v.Probability > top {
+				top = v.Probability
+			}
+		}
+		for _, v := range t.Values {
+			bar := ""
+			if top > 0 {
+				bar = strings.Repeat("#", int(v.Probability/top*barWidth+0.5))
+			}
+			fmt.Fprintf(tw, "%d\t%.2f%%\t%s\n", v.Value, v.Probability*100, bar)
+		}
+	}
+	tw.Flush()
+}
+
+// plotTables saves a line for each table to the png file name
+func plotTables(name, mode string, tables []tableOutput) error {
+	pl, err := plot.New()
+	if err != nil {
+		return err
+	}
+	pl.X.Label.Text = "Value"
+	pl.Y.Label.Text = "Probability (%)"
+	if mode != "normal" {
+		pl.Y.Label.Text = fmt.Sprintf("Probability %s (%%)", mode)
+	}
+
+	var lines []interface{}
+	for _, t := range tables {
+		xy := make(plotter.XYs, len(t.Values))
+		for i, v := range t.Values {
+			xy[i].X, xy[i].Y = float64(v.Value), v.Probability*100
+		}
+		lines = append(lines, t.Name, xy)
+	}
+
+	pl.Add(plotter.NewGrid())
+	if err := plotutil.AddLinePoints(pl, lines...); err != nil {
+		return err
+	}
+	pl.Legend.Top = true
+
+	return pl.Save(20*vg.Centimeter, 15*vg.Centimeter, name)
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.Flags().StringP("exec", "e", "", "Program to run instead of reading one from a file")
+	rootCmd.Flags().StringP("mode", "m", "normal", "Probability to show for each value: normal, atleast or atmost")
+	rootCmd.Flags().StringP("graph", "g", "", "Plot the outputs to this png file")
+	output.AddFlag(rootCmd)
+}
@@ -0,0 +1,74 @@
+// Package output provides the --output option shared by the go-roll commands so that
+// their results can be written as plain text, JSON or CSV.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// Format identifies an output encoding
+type Format string
+
+// Formats supported by the --output flag
+const (
+	Text Format = "text"
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+// AddFlag registers the --output flag on cmd
+func AddFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", string(Text), "Output format: text, json or csv")
+}
+
+// FromFlags returns the Format selected by the --output flag of cmd
+func FromFlags(cmd *cobra.Command) (Format, error) {
+	s, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return Text, err
+	}
+
+	switch f := Format(s); f {
+	case Text, JSON, CSV:
+		return f, nil
+	default:
+		return Text, fmt.Errorf("unknown output format %q: must be one of text, json or csv", s)
+	}
+}
+
+// WriteJSON writes v to w as indented JSON
+func WriteJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// WriteCSV writes a header row followed by rows to w
+func WriteCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// Itoa is a convenience for building CSV rows
+func Itoa(n int) string {
+	return strconv.Itoa(n)
+}
+
+// Ftoa formats a probability or percentage for CSV rows
+func Ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,25 @@
+// Package simflag provides the simulation flags shared by dprob and pgraph
+package simflag
+
+import (
+	"github.com/nboughton/go-roll/sim"
+	"github.com/spf13/cobra"
+)
+
+// AddFlags registers --rolls, --workers and --precision on cmd
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("rolls", "r", sim.DefaultRolls, "Number of times to roll each dice set, or the most rolls to make when --precision is set")
+	cmd.Flags().IntP("workers", "W", 0, "Number of goroutines rolling dice, defaults to the number of CPUs")
+	cmd.Flags().Float64P("precision", "p", 0, "Stop rolling once the standard error of every probability is at or below this, i.e 0.001")
+}
+
+// Options returns the sim.Options selected by the flags of cmd
+func Options(cmd *cobra.Command) sim.Options {
+	var (
+		rolls, _     = cmd.Flags().GetInt("rolls")
+		workers, _   = cmd.Flags().GetInt("workers")
+		precision, _ = cmd.Flags().GetFloat64("precision")
+	)
+
+	return sim.Options{Rolls: rolls, Workers: workers, Precision: precision}
+}
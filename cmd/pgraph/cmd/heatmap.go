@@ -0,0 +1,138 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// jointGrid is the joint probability of two components as a percentage, laid out for a heat
+// map with a column for every value of x and a row for every value of y
+type jointGrid struct {
+	j          *roll.Joint
+	xMin, yMin int
+	cols, rows int
+}
+
+func newJointGrid(j *roll.Joint) jointGrid {
+	g := jointGrid{j: j}
+
+	for i, o := range j.Outcomes() {
+		x, y := o.Values[0], o.Values[1]
+		if i == 0 {
+			g.xMin, g.yMin, g.cols, g.rows = x, y, 1, 1
+			continue
+		}
+		if x < g.xMin {
+			g.cols += g.xMin - x
+			g.xMin = x
+		}
+		if y < g.yMin {
+			g.rows += g.yMin - y
+			g.yMin = y
+		}
+		if x >= g.xMin+g.cols {
+			g.cols = x - g.xMin + 1
+		}
+		if y >= g.yMin+g.rows {
+			g.rows = y - g.yMin + 1
+		}
+	}
+
+	return g
+}
+
+func (g jointGrid) Dims() (int, int)   { return g.cols, g.rows }
+func (g jointGrid) Z(c, r int) float64 { return g.j.P(g.xMin+c, g.yMin+r) * 100 }
+func (g jointGrid) X(c int) float64    { return float64(g.xMin + c) }
+func (g jointGrid) Y(r int) float64    { return float64(g.yMin + r) }
+
+// cellOutput is the schema used for json and csv output of a heat map, one per outcome.
+// Probabilities are expressed as fractions between 0 and 1.
+type cellOutput struct {
+	X           int     `json:"x"`
+	Y           int     `json:"y"`
+	Probability float64 `json:"probability"`
+}
+
+var cellHeader = []string{"x", "y", "probability"}
+
+// heatmap saves the joint distribution of the components x and y of the mechanic spec as a heat
+// map to title.png and returns its cells
+func heatmap(spec, x, y, title string) ([]cellOutput, error) {
+	j, err := roll.ParseJoint(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	names := j.Names()
+	if x == "" {
+		x = names[0]
+	}
+	if y == "" {
+		for _, n := range names {
+			if n != x {
+				y = n
+				break
+			}
+		}
+	}
+	if j, err = j.Project(x, y); err != nil {
+		return nil, err
+	}
+
+	pl, err := plot.New()
+	if err != nil {
+		return nil, err
+	}
+	pl.Title.Text = fmt.Sprintf("%s (%%)", title)
+	pl.X.Label.Text = x
+	pl.Y.Label.Text = y
+
+	pl.Add(plotter.NewHeatMap(newJointGrid(j), moreland.Kindlmann().Palette(255)))
+	if err := pl.Save(20*vg.Centimeter, 15*vg.Centimeter, fmt.Sprintf("%s.png", title)); err != nil {
+		return nil, err
+	}
+
+	var cells []cellOutput
+	for _, o := range j.Outcomes() {
+		cells = append(cells, cellOutput{X: o.Values[0], Y: o.Values[1], Probability: j.P(o.Values...)})
+	}
+
+	return cells, nil
+}
+
+func cellRows(cells []cellOutput) [][]string {
+	var rows [][]string
+
+	for _, c := range cells {
+		rows = append(rows, []string{output.Itoa(c.X), output.Itoa(c.Y), output.Ftoa(c.Probability)})
+	}
+
+	return rows
+}
@@ -21,12 +21,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/nboughton/go-roll/cmd/internal/simflag"
+	"github.com/nboughton/go-roll/sim"
 	"github.com/spf13/cobra"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
@@ -43,12 +48,42 @@ var RootCmd = &cobra.Command{
 		var (
 			dice, _   = cmd.Flags().GetStringArray("dice")
 			labels, _ = cmd.Flags().GetStringArray("label")
-			rolls, _  = cmd.Flags().GetInt("rolls")
+			opts      = simflag.Options(cmd)
 			title, _  = cmd.Flags().GetString("title")
+			joint, _  = cmd.Flags().GetString("joint")
 		)
 
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		t := time.Now()
 
+		// Render a heat map of two components of a mechanic instead of lines
+		if joint != "" {
+			x, _ := cmd.Flags().GetString("x")
+			y, _ := cmd.Flags().GetString("y")
+
+			cells, err := heatmap(joint, x, y, title)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			switch format {
+			case output.JSON:
+				err = output.WriteJSON(os.Stdout, cells)
+			case output.CSV:
+				err = output.WriteCSV(os.Stdout, cellHeader, cellRows(cells))
+			default:
+				fmt.Println("run time: ", time.Now().Sub(t).Round(time.Millisecond))
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
 		// New plot
 		pl, err := plot.New()
 		if err != nil {
@@ -62,29 +97,33 @@ var RootCmd = &cobra.Command{
 		pl.Y.Tick.Marker = customTicks{}
 
 		// Roll some dice and aggregate data
-		var argsLine []interface{}
+		var (
+			argsLine []interface{}
+			series   []seriesOutput
+		)
 		for i, s := range dice {
-			fmt.Println("rolling ", s)
-
-			var (
-				results roll.Results
-				label   = dice[i]
-			)
+			if format == output.Text {
+				fmt.Println("rolling ", s)
+			}
 
+			label := dice[i]
 			if len(labels) > i {
 				label = labels[i]
 			}
 
-			for i := 0; i < rolls; i++ {
-				result, err := roll.FromString(s)
-				if err != nil {
-					log.Fatal(err)
-				}
+			e, err := roll.Parse(s)
+			if err != nil {
+				log.Fatal(err)
+			}
 
-				results = append(results, result)
+			h, err := sim.Run(context.Background(), e, opts)
+			if err != nil {
+				log.Fatal(err)
 			}
 
-			argsLine = append(argsLine, label, lineData(results, results.Min(), results.Max()))
+			xy := lineData(h)
+			argsLine = append(argsLine, label, xy)
+			series = append(series, newSeriesOutput(label, s, h))
 		}
 
 		pl.Add(plotter.NewGrid())
@@ -97,24 +136,75 @@ var RootCmd = &cobra.Command{
 		if err := pl.Save(20*vg.Centimeter, 15*vg.Centimeter, fmt.Sprintf("%s.png", title)); err != nil {
 			log.Fatal(err)
 		}
-		fmt.Println("run time: ", time.Now().Sub(t).Round(time.Millisecond))
+
+		switch format {
+		case output.JSON:
+			err = output.WriteJSON(os.Stdout, series)
+		case output.CSV:
+			err = output.WriteCSV(os.Stdout, csvHeader, csvRows(series))
+		default:
+			fmt.Println("run time: ", time.Now().Sub(t).Round(time.Millisecond))
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
 	},
 }
 
-func lineData(results roll.Results, min, max int) plotter.XYs {
-	var (
-		xLen = max - min + 1
-		xy   = make(plotter.XYs, xLen)
-	)
+// seriesOutput is the schema used for json and csv output, one per plotted dice string.
+// Probabilities are expressed as fractions between 0 and 1 with their standard error, as they
+// are by dprob.
+type seriesOutput struct {
+	Label  string        `json:"label"`
+	Dice   string        `json:"dice"`
+	Rolls  int           `json:"rolls"`
+	Points []pointOutput `json:"points"`
+}
+
+// pointOutput is a single plotted value
+type pointOutput struct {
+	Value       int     `json:"value"`
+	Probability float64 `json:"probability"`
+	StdErr      float64 `json:"stderr"`
+}
+
+var csvHeader = []string{"label", "dice", "rolls", "value", "probability", "stderr"}
+
+func newSeriesOutput(label, dice string, h *sim.Histogram) seriesOutput {
+	out := seriesOutput{Label: label, Dice: dice, Rolls: h.Rolls}
+
+	for _, v := range h.Values() {
+		out.Points = append(out.Points, pointOutput{Value: v, Probability: h.P(v), StdErr: h.StdErr(v)})
+	}
+
+	return out
+}
+
+func csvRows(series []seriesOutput) [][]string {
+	var rows [][]string
+
+	for _, s := range series {
+		for _, p := range s.Points {
+			rows = append(rows, []string{s.Label, s.Dice, output.Itoa(s.Rolls), output.Itoa(p.Value), output.Ftoa(p.Probability), output.Ftoa(p.StdErr)})
+		}
+	}
+
+	return rows
+}
 
-	for _, res := range results {
-		n := res.Sum() - min
-		xy[n].Y++
+// lineData returns the probability of every value between the lowest and highest rolled as
+// a percentage
+func lineData(h *sim.Histogram) plotter.XYs {
+	values := h.Values()
+	if len(values) == 0 {
+		return nil
 	}
 
+	min, max := values[0], values[len(values)-1]
+	xy := make(plotter.XYs, max-min+1)
 	for i := range xy {
 		xy[i].X = float64(i + min)
-		xy[i].Y = xy[i].Y / float64(len(results)) * 100
+		xy[i].Y = h.P(i+min) * 100
 	}
 
 	return xy
@@ -165,6 +255,10 @@ func Execute() {
 func init() {
 	RootCmd.Flags().StringArrayP("dice", "d", []string{"2d6", "3d6", "4d6", "3d6Kh2", "4d6Kh2"}, "Dice strings to plot")
 	RootCmd.Flags().StringArrayP("label", "l", []string{}, "Labels for plots, these are applied to their respective dice strings")
-	RootCmd.Flags().IntP("rolls", "r", 100000, "Number of times to roll each dice set")
+	simflag.AddFlags(RootCmd)
 	RootCmd.Flags().StringP("title", "t", "graph", "Title of graph")
+	RootCmd.Flags().StringP("joint", "j", "", "Render a heat map of a mechanic with more than one component instead: "+strings.Join(roll.JointMechanics(), "; "))
+	RootCmd.Flags().String("x", "", "Component of --joint on the x axis, its first if not given")
+	RootCmd.Flags().String("y", "", "Component of --joint on the y axis, its second if not given")
+	output.AddFlag(RootCmd)
 }
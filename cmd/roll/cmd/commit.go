@@ -0,0 +1,54 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nboughton/go-roll"
+	"github.com/spf13/cobra"
+)
+
+// commitCmd generates the server seed of fair rolls and the commitment to publish before rolling
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Generate a server seed for fair rolls and the commitment to publish before rolling",
+	Long: `Commit generates a secret server seed and prints it with its commitment. Publish the
+commitment to the players before any roll is made, then roll with --fair --server-seed and the
+players' --client-seed, giving each roll the next --nonce. Reveal the server seed once the rolls
+are done so that the players can check them with roll verify.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := roll.NewFairRoller("")
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Server seed:\t%s\nCommitment:\t%s\n", f.ServerSeed(), f.Commitment())
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(commitCmd)
+}
@@ -0,0 +1,64 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nboughton/go-roll"
+	"github.com/spf13/cobra"
+)
+
+// convertCmd prints a dice string in another dialect's notation
+var convertCmd = &cobra.Command{
+	Use:   "convert [dice string]",
+	Short: "Print a dice string in the notation of another dice roller",
+	Long: `Convert reads a dice string in the --dialect notation and prints it in the --to notation, i.e
+roll convert --dialect roll20 --to avrae "4d6kh3+d20ro<2" prints 4d6kh3+1d20ro<3.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := parseExpr(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		name, _ := cmd.Flags().GetString("to")
+		d, ok := roll.LookupDialect(name)
+		if !ok {
+			return fmt.Errorf("unknown dialect %q: must be one of %s", name, strings.Join(roll.DialectNames(), ", "))
+		}
+
+		s, err := d.Format(e)
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+
+		return nil
+	},
+}
+
+func init() {
+	convertCmd.Flags().String("to", "native", "Notation to print: native, roll20, foundry or avrae")
+	rootCmd.AddCommand(convertCmd)
+}
@@ -0,0 +1,298 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nboughton/go-roll"
+	"github.com/nboughton/go-roll/cmd/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "roll [dice string]",
+	Short: "Roll a dice string or expression (4d6Kh3, 1d20+5, @attack(str=3)) and print the result",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	// Errors from bad dice strings are reported by Execute without the usage text
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := output.FromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		e, err := parseExpr(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		vars, _ := cmd.Flags().GetStringToInt("var")
+
+		var (
+			o     roll.Outcome
+			proof *proofOutput
+		)
+		switch fair, _ := cmd.Flags().GetBool("fair"); {
+		case fair:
+			f, err := fairRoller(cmd)
+			if err != nil {
+				return err
+			}
+
+			var p roll.Proof
+			if o, p, err = f.Eval(e, vars); err != nil {
+				return err
+			}
+			proof = &proofOutput{Commitment: p.Commitment, ClientSeed: p.ClientSeed, Nonce: p.Nonce}
+
+		default:
+			if crypto, _ := cmd.Flags().GetBool("crypto"); crypto {
+				e = e.WithSource(roll.CryptoSource{})
+			}
+			// Prompts go to stderr so that json and csv output stay clean
+			if m, _ := cmd.Flags().GetBool("manual"); m {
+				e = e.WithSource(roll.NewPrompt(os.Stdin, os.Stderr))
+			}
+
+			if o, err = e.Eval(vars); err != nil {
+				return err
+			}
+		}
+
+		out := rollOutput{Expression: args[0], Total: o.Total, Label: o.Label, Proof: proof}
+		if o.Check != nil {
+			out.Check = &checkOutput{Target: o.Check.Target, Pass: o.Check.Pass, Margin: o.Check.Margin}
+		}
+		for _, r := range o.Results {
+			out.Terms = append(out.Terms, newTermOutput(r))
+		}
+
+		switch format {
+		case output.JSON:
+			return output.WriteJSON(os.Stdout, out)
+		case output.CSV:
+			return output.WriteCSV(os.Stdout, csvHeader, out.rows())
+		default:
+			var rolls []string
+			for _, r := range o.Results {
+				rolls = append(rolls, r.String())
+			}
+			fmt.Printf("Total:\t%d\nRolls:\t%s\n", o.Total, strings.Join(rolls, " | "))
+			if o.Check != nil {
+				result := "fail"
+				if o.Check.Pass {
+					result = "pass"
+				}
+				fmt.Printf("Check:\t%s (target %d, margin %d)\n", result, o.Check.Target, o.Check.Margin)
+			}
+			if o.Label != "" {
+				fmt.Printf("Result:\t%s\n", o.Label)
+			}
+			if proof != nil {
+				fmt.Printf("Commitment:\t%s\nClient seed:\t%s\nNonce:\t%d\n", proof.Commitment, proof.ClientSeed, proof.Nonce)
+			}
+		}
+
+		return nil
+	},
+}
+
+// rollOutput is the schema used for json and csv output. Check and Label are only present
+// for expressions that compare against a target or label their result, Proof for fair rolls.
+type rollOutput struct {
+	Expression string       `json:"expression"`
+	Total      int          `json:"total"`
+	Check      *checkOutput `json:"check,omitempty"`
+	Label      string       `json:"label,omitempty"`
+	Proof      *proofOutput `json:"proof,omitempty"`
+	Terms      []termOutput `json:"terms"`
+}
+
+// proofOutput is the public record of a fair roll that roll verify checks
+type proofOutput struct {
+	Commitment string `json:"commitment"`
+	ClientSeed string `json:"client_seed"`
+	Nonce      uint64 `json:"nonce"`
+}
+
+// checkOutput is the result of comparing the total against a target
+type checkOutput struct {
+	Target int  `json:"target"`
+	Pass   bool `json:"pass"`
+	Margin int  `json:"margin"`
+}
+
+// termOutput describes the dice rolled for a single dice term of an expression. Dice are listed
+// in the order they were rolled, kept or not.
+type termOutput struct {
+	Sides int         `json:"sides"`
+	Total int         `json:"total"`
+	Dice  []dieOutput `json:"dice"`
+}
+
+// dieOutput is a single rolled die and whether it counted towards the total
+type dieOutput struct {
+	N     int    `json:"n"`
+	Value string `json:"value"`
+	Kept  bool   `json:"kept"`
+}
+
+var csvHeader = []string{"expression", "total", "term", "sides", "n", "value", "kept", "pass", "margin", "label", "commitment", "client_seed", "nonce"}
+
+func newTermOutput(r roll.Result) termOutput {
+	t := termOutput{Sides: r.Die().Sides(), Total: r.Sum()}
+
+	for _, f := range r.Rolled() {
+		t.Dice = append(t.Dice, dieOutput{N: f.N, Value: f.Value, Kept: f.Kept})
+	}
+
+	return t
+}
+
+func (o rollOutput) rows() [][]string {
+	var (
+		rows                             [][]string
+		pass, margin, commitment, client string
+		nonce                            string
+	)
+
+	if o.Check != nil {
+		pass, margin = fmt.Sprint(o.Check.Pass), output.Itoa(o.Check.Margin)
+	}
+	if o.Proof != nil {
+		commitment, client, nonce = o.Proof.Commitment, o.Proof.ClientSeed, fmt.Sprint(o.Proof.Nonce)
+	}
+
+	for i, t := range o.Terms {
+		for _, d := range t.Dice {
+			rows = append(rows, []string{
+				o.Expression,
+				output.Itoa(o.Total),
+				output.Itoa(i),
+				output.Itoa(t.Sides),
+				output.Itoa(d.N),
+				d.Value,
+				fmt.Sprint(d.Kept),
+				pass,
+				margin,
+				o.Label,
+				commitment,
+				client,
+				nonce,
+			})
+		}
+	}
+
+	return rows
+}
+
+// parseExpr parses s in the notation named by the --dialect flag, expanding macros from the file
+// named by the --macros flag. Dialects that write variables with @, such as Roll20's @{str},
+// can't also reference macros.
+func parseExpr(cmd *cobra.Command, s string) (*roll.Expr, error) {
+	name, _ := cmd.Flags().GetString("dialect")
+	d, ok := roll.LookupDialect(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown dialect %q: must be one of %s", name, strings.Join(roll.DialectNames(), ", "))
+	}
+
+	path, _ := cmd.Flags().GetString("macros")
+	if v := d.Variable("name"); strings.HasPrefix(v, "@") {
+		if path != "" {
+			return nil, fmt.Errorf("--macros can't be used with %s notation, which writes variables as %s", d.Name, v)
+		}
+		return d.Parse(s)
+	}
+
+	macros := roll.NewMacros()
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if err := macros.Load(f); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	if d == roll.Native {
+		return macros.Parse(s)
+	}
+
+	x, err := macros.Expand(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Parse(x)
+}
+
+// fairRoller returns a FairRoller for the --server-seed, --client-seed and --nonce flags. The
+// server seed must have been committed to beforehand, see roll commit.
+func fairRoller(cmd *cobra.Command) (*roll.FairRoller, error) {
+	var (
+		server, _ = cmd.Flags().GetString("server-seed")
+		client, _ = cmd.Flags().GetString("client-seed")
+		nonce, _  = cmd.Flags().GetUint64("nonce")
+	)
+
+	if server == "" {
+		return nil, fmt.Errorf("a fair roll needs the --server-seed committed to with roll commit")
+	}
+
+	return roll.NewFairRollerWithSeed(server, client, nonce)
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		var pe *roll.ParseError
+		if errors.As(err, &pe) {
+			fmt.Fprintln(os.Stderr, pe.Render())
+		} else {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringP("macros", "m", "", "File of macro definitions that can be referenced as @name in the dice string")
+	rootCmd.PersistentFlags().StringToInt("var", map[string]int{}, "Values for variables referenced as $name in the dice string, i.e --var dex=3,level=5")
+	rootCmd.PersistentFlags().String("dialect", "native", "Notation of the dice string: native, roll20, foundry or avrae")
+	rootCmd.PersistentFlags().String("server-seed", "", "Hex encoded server seed of a fair roll, generated by roll commit")
+	rootCmd.PersistentFlags().String("client-seed", "", "Client seed of a fair roll, i.e the players' seeds joined with commas")
+	rootCmd.PersistentFlags().Uint64("nonce", 0, "Nonce of a fair roll")
+	rootCmd.Flags().Bool("fair", false, "Roll with a provably fair commit-reveal source and print the proof")
+	rootCmd.Flags().Bool("crypto", false, "Roll with crypto/rand")
+	rootCmd.Flags().Bool("manual", false, "Prompt for the face of every die so that physical dice can be rolled")
+	output.AddFlag(rootCmd)
+}
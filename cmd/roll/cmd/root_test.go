@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nboughton/go-roll"
+	"github.com/spf13/pflag"
+)
+
+// run executes the roll command with args and returns what it printed to stdout. Flags are
+// reset to their defaults first, as cobra keeps their values between executions.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	reset := func(fs *pflag.FlagSet) {
+		fs.VisitAll(func(f *pflag.Flag) {
+			switch v := f.Value.(type) {
+			case pflag.SliceValue:
+				v.Replace(nil)
+			default:
+				if f.Value.Type() == "stringToInt" {
+					f.Value.Set("")
+				} else {
+					f.Value.Set(f.DefValue)
+				}
+			}
+			f.Changed = false
+		})
+	}
+	reset(rootCmd.PersistentFlags())
+	reset(rootCmd.Flags())
+	for _, c := range rootCmd.Commands() {
+		reset(c.Flags())
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+
+	return string(out), err
+}
+
+// runJSON runs the roll command with json output and decodes it
+func runJSON(t *testing.T, args ...string) rollOutput {
+	t.Helper()
+
+	out, err := run(t, append(args, "-o", "json")...)
+	if err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+
+	var o rollOutput
+	if err := json.Unmarshal([]byte(out), &o); err != nil {
+		t.Fatalf("%s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+
+	return o
+}
+
+func TestDialectVariables(t *testing.T) {
+	for _, args := range [][]string{
+		{"--dialect", "foundry", "--var", "str=100", "1d6+@str"},
+		{"--dialect", "roll20", "--var", "str=100", "1d6+@{str}"},
+	} {
+		o := runJSON(t, args...)
+		if o.Total < 101 || o.Total > 106 {
+			t.Errorf("%s: total %d, want 101 to 106", strings.Join(args, " "), o.Total)
+		}
+	}
+
+	if _, err := run(t, "--dialect", "roll20", "--macros", os.DevNull, "1d6"); err == nil {
+		t.Errorf("macros with a dialect that writes variables with @ should be an error")
+	}
+}
+
+func TestDialectMacros(t *testing.T) {
+	f, err := ioutil.TempFile("", "macros")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("big = 100\n")
+	f.Close()
+
+	for _, d := range []string{"native", "avrae"} {
+		o := runJSON(t, "--dialect", d, "--macros", f.Name(), "1d6+@big")
+		if o.Total < 101 || o.Total > 106 {
+			t.Errorf("%s: total %d, want 101 to 106", d, o.Total)
+		}
+	}
+}
+
+func TestRollOrder(t *testing.T) {
+	seed := strings.Repeat("00", 32)
+	o := runJSON(t, "--fair", "--server-seed", seed, "--client-seed", "alice", "--nonce", "5", "6d6Kh3")
+
+	// The fair roll is replayed to find the faces in the order they were rolled
+	src, err := roll.Proof{Commitment: o.Proof.Commitment, ClientSeed: "alice", Nonce: 5}.Source(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := roll.RollWith(src, 6, roll.D6).Keep(3, roll.HIGH)
+
+	var kept int
+	for i, f := range want.Rolled() {
+		d := o.Terms[0].Dice[i]
+		if d.N != f.N || d.Kept != f.Kept {
+			t.Errorf("die %d: got %d kept %v, want %d kept %v", i, d.N, d.Kept, f.N, f.Kept)
+		}
+		if d.Kept {
+			kept += d.N
+		}
+	}
+	if len(o.Terms[0].Dice) != 6 || kept != o.Total || o.Total != want.Sum() {
+		t.Errorf("got %+v totalling %d, want %v totalling %d", o.Terms[0].Dice, o.Total, want.Rolled(), want.Sum())
+	}
+}
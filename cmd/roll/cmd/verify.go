@@ -0,0 +1,103 @@
+// Copyright © 2019 Nick Boughton <nicholasboughton@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nboughton/go-roll"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd recomputes a fair roll from its proof once the server seed has been revealed
+var verifyCmd = &cobra.Command{
+	Use:   "verify [dice string]",
+	Short: "Verify a fair roll against its commitment and print the dice it should have rolled",
+	Long: `Verify checks that the revealed --server-seed hashes to --commitment, recomputes the roll
+of the dice string from the seeds and --nonce and checks it against the claimed --total. If
+--faces is given, the faces of every term that counted towards the total, in the order roll
+printed them, must match too:
+
+	roll verify --commitment 9f86... --server-seed 3a7b... --client-seed alice --nonce 0 --total 14 --faces 6,5,3 4d6Kh3`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			server, _     = cmd.Flags().GetString("server-seed")
+			client, _     = cmd.Flags().GetString("client-seed")
+			nonce, _      = cmd.Flags().GetUint64("nonce")
+			commitment, _ = cmd.Flags().GetString("commitment")
+			vars, _       = cmd.Flags().GetStringToInt("var")
+		)
+
+		if server == "" {
+			return fmt.Errorf("the revealed --server-seed is required")
+		}
+
+		e, err := parseExpr(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		p := roll.Proof{Commitment: commitment, ClientSeed: client, Nonce: nonce}
+		src, err := p.Source(server)
+		if err != nil {
+			return err
+		}
+
+		o, err := e.WithSource(src).Eval(vars)
+		if err != nil {
+			return err
+		}
+
+		var rolls []string
+		for _, r := range o.Results {
+			rolls = append(rolls, r.String())
+		}
+		fmt.Printf("Total:\t%d\nRolls:\t%s\n", o.Total, strings.Join(rolls, " | "))
+
+		if total, _ := cmd.Flags().GetInt("total"); total != o.Total {
+			return fmt.Errorf("verification failed: total %d, proof gives %d", total, o.Total)
+		}
+		if cmd.Flags().Changed("faces") {
+			var want []int
+			for _, r := range o.Results {
+				want = append(want, r.Ints()...)
+			}
+			if faces, _ := cmd.Flags().GetIntSlice("faces"); fmt.Sprint(faces) != fmt.Sprint(want) {
+				return fmt.Errorf("verification failed: faces %v, proof gives %v", faces, want)
+			}
+		}
+		fmt.Println("Verified")
+
+		return nil
+	},
+}
+
+func init() {
+	verifyCmd.Flags().String("commitment", "", "Commitment published before the roll")
+	verifyCmd.Flags().Int("total", 0, "Total claimed for the roll")
+	verifyCmd.Flags().IntSlice("faces", nil, "Faces claimed for the roll, i.e 6,5,3")
+	verifyCmd.MarkFlagRequired("commitment")
+	verifyCmd.MarkFlagRequired("total")
+	rootCmd.AddCommand(verifyCmd)
+}
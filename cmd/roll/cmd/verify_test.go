@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFairRollVerify(t *testing.T) {
+	if _, err := run(t, "--fair", "1d6"); err == nil {
+		t.Errorf("a fair roll without a committed server seed should be an error")
+	}
+
+	out, err := run(t, "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`Server seed:\t([0-9a-f]+)\nCommitment:\t([0-9a-f]+)\n`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("unexpected commit output %q", out)
+	}
+	seed, commitment := m[1], m[2]
+
+	o := runJSON(t, "--fair", "--server-seed", seed, "--client-seed", "alice,bob", "--nonce", "3", "4d6Kh3")
+	if o.Proof == nil || o.Proof.Commitment != commitment || o.Proof.ClientSeed != "alice,bob" || o.Proof.Nonce != 3 {
+		t.Fatalf("got proof %+v, want commitment %s", o.Proof, commitment)
+	}
+
+	var kept []string
+	for _, d := range o.Terms[0].Dice {
+		if d.Kept {
+			kept = append(kept, fmt.Sprint(d.N))
+		}
+	}
+	faces := strings.Join(kept, ",")
+
+	verify := func(args ...string) (string, error) {
+		return run(t, append([]string{"verify", "--commitment", commitment, "--client-seed", "alice,bob", "--nonce", "3"}, args...)...)
+	}
+	total := fmt.Sprint(o.Total)
+
+	if out, err := verify("--server-seed", seed, "--total", total, "--faces", faces, "4d6Kh3"); err != nil || !strings.Contains(out, "Verified") {
+		t.Errorf("verify: got %q, %v", out, err)
+	}
+	for name, args := range map[string][]string{
+		"no total":     {"--server-seed", seed, "4d6Kh3"},
+		"wrong total":  {"--server-seed", seed, "--total", fmt.Sprint(o.Total + 1), "4d6Kh3"},
+		"wrong faces":  {"--server-seed", seed, "--total", total, "--faces", "1,1,1", "4d6Kh3"},
+		"other seed":   {"--server-seed", strings.Repeat("00", 32), "--total", total, "4d6Kh3"},
+		"other dice":   {"--server-seed", seed, "--total", total, "--faces", faces, "4d6Kl3"},
+		"missing seed": {"--total", total, "4d6Kh3"},
+	} {
+		if out, err := verify(args...); err == nil {
+			t.Errorf("%s: verified with %q", name, out)
+		}
+	}
+}
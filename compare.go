@@ -0,0 +1,169 @@
+package roll
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// compareEpsilon is the largest difference in probability Compare treats as equal, allowing for
+// rounding error in distributions computed different ways
+const compareEpsilon = 1e-9
+
+// ComparePercentiles are the percentiles reported by Compare, from the minimum to the maximum
+var ComparePercentiles = []float64{0, 0.05, 0.25, 0.5, 0.75, 0.95, 1}
+
+// Comparison is how two distributions a and b differ, see Compare. Differences are b - a.
+type Comparison struct {
+	// Equal is true when every value has the same probability in a and b
+	Equal bool `json:"equal"`
+	// SameShape is true when a and b are equal once their values are relabelled in order, such
+	// as 2d6 and 2d6+1 or a D66 and a d36
+	SameShape bool `json:"same_shape"`
+	// TotalVariation is the largest difference in the probability of any event, half the sum of
+	// the differences of every value. It is 0 for equal distributions and 1 for disjoint ones.
+	TotalVariation float64 `json:"total_variation"`
+	// KL is the Kullback-Leibler divergence of b from a in bits, and KLReverse that of a from b.
+	// Each is +Inf when the first distribution has values the second can't roll.
+	KL        float64 `json:"kl"`
+	KLReverse float64 `json:"kl_reverse"`
+
+	Mean        StatDiff         `json:"mean"`
+	Variance    StatDiff         `json:"variance"`
+	StdDev      StatDiff         `json:"stddev"`
+	Percentiles []PercentileDiff `json:"percentiles"`
+	// Values has a row for every value either distribution can roll, in order
+	Values []ValueDiff `json:"values"`
+}
+
+// StatDiff is a statistic of both distributions of a Comparison
+type StatDiff struct {
+	A    float64 `json:"a"`
+	B    float64 `json:"b"`
+	Diff float64 `json:"diff"`
+}
+
+// PercentileDiff is the percentile Q of both distributions of a Comparison
+type PercentileDiff struct {
+	Q    float64 `json:"q"`
+	A    int     `json:"a"`
+	B    int     `json:"b"`
+	Diff int     `json:"diff"`
+}
+
+// ValueDiff is the probability of a value in both distributions of a Comparison
+type ValueDiff struct {
+	Value int     `json:"value"`
+	A     float64 `json:"a"`
+	B     float64 `json:"b"`
+	Diff  float64 `json:"diff"`
+}
+
+// Compare returns how the distributions a and b differ. Both are normalized first, so estimates
+// from the sim package may be compared with exact distributions, though they will rarely be
+// exactly Equal.
+func Compare(a, b Distribution) Comparison {
+	a, b = a.Normalize(), b.Normalize()
+
+	out := Comparison{
+		Mean:     statDiff(a.Mean(), b.Mean()),
+		Variance: statDiff(a.Variance(), b.Variance()),
+		StdDev:   statDiff(a.StdDev(), b.StdDev()),
+	}
+
+	for _, q := range ComparePercentiles {
+		pa, pb := a.Percentile(q), b.Percentile(q)
+		out.Percentiles = append(out.Percentiles, PercentileDiff{Q: q, A: pa, B: pb, Diff: pb - pa})
+	}
+
+	all := make(Distribution, len(a)+len(b))
+	for v := range a {
+		all[v] = 1
+	}
+	for v := range b {
+		all[v] = 1
+	}
+
+	out.Equal = true
+	for _, v := range all.Values() {
+		pa, pb := a[v], b[v]
+		out.Values = append(out.Values, ValueDiff{Value: v, A: pa, B: pb, Diff: pb - pa})
+
+		if math.Abs(pb-pa) > compareEpsilon {
+			out.Equal = false
+		}
+		out.TotalVariation += math.Abs(pb-pa) / 2
+	}
+
+	out.KL, out.KLReverse = kl(a, b), kl(b, a)
+	out.SameShape = sameShape(a, b)
+
+	return out
+}
+
+func statDiff(a, b float64) StatDiff {
+	return StatDiff{A: a, B: b, Diff: b - a}
+}
+
+// kl returns the Kullback-Leibler divergence of q from p in bits
+func kl(p, q Distribution) float64 {
+	t := 0.
+
+	for v, pv := range p {
+		if pv <= compareEpsilon {
+			continue
+		}
+		if q[v] <= 0 {
+			return math.Inf(1)
+		}
+		t += pv * math.Log2(pv/q[v])
+	}
+
+	// Rounding error can leave a tiny negative divergence between equal distributions
+	return math.Max(t, 0)
+}
+
+// sameShape reports whether a and b have the same probabilities in order of value
+func sameShape(a, b Distribution) bool {
+	var pa, pb []float64
+	for _, v := range a.Values() {
+		if a[v] > compareEpsilon {
+			pa = append(pa, a[v])
+		}
+	}
+	for _, v := range b.Values() {
+		if b[v] > compareEpsilon {
+			pb = append(pb, b[v])
+		}
+	}
+
+	if len(pa) != len(pb) {
+		return false
+	}
+	for i := range pa {
+		if math.Abs(pa[i]-pb[i]) > compareEpsilon {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MarshalJSON encodes a Comparison with infinite divergences as null, which JSON can't
+// otherwise represent
+func (c Comparison) MarshalJSON() ([]byte, error) {
+	type comparison Comparison
+	v := struct {
+		comparison
+		KL        *float64 `json:"kl"`
+		KLReverse *float64 `json:"kl_reverse"`
+	}{comparison: comparison(c)}
+
+	if !math.IsInf(c.KL, 0) {
+		v.KL = &c.KL
+	}
+	if !math.IsInf(c.KLReverse, 0) {
+		v.KLReverse = &c.KLReverse
+	}
+
+	return json.Marshal(v)
+}
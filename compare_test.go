@@ -0,0 +1,119 @@
+package roll
+
+import (
+	"math"
+	"testing"
+)
+
+func dist(t *testing.T, s string) Distribution {
+	t.Helper()
+
+	d, err := MustParse(s).Distribution(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestCompare(t *testing.T) {
+	c := Compare(dist(t, "2d6"), dist(t, "1d12"))
+
+	if c.Equal || c.SameShape {
+		t.Errorf("2d6 vs 1d12: got equal %v, same shape %v", c.Equal, c.SameShape)
+	}
+	for _, s := range []struct {
+		name      string
+		got, want StatDiff
+	}{
+		{"mean", c.Mean, StatDiff{7, 6.5, -0.5}},
+		{"variance", c.Variance, StatDiff{35. / 6, 143. / 12, 143./12 - 35./6}},
+	} {
+		if !near(s.got.A, s.want.A) || !near(s.got.B, s.want.B) || !near(s.got.Diff, s.want.Diff) {
+			t.Errorf("%s: got %+v, want %+v", s.name, s.got, s.want)
+		}
+	}
+
+	// |b - a| summed over values is 18/36
+	if !near(c.TotalVariation, 0.25) {
+		t.Errorf("total variation %v, want 0.25", c.TotalVariation)
+	}
+	// 1d12 covers every total of 2d6 but 2d6 can't roll a 1
+	if math.IsInf(c.KL, 0) || c.KL <= 0 || !math.IsInf(c.KLReverse, 1) {
+		t.Errorf("got KL %v and reverse %v, want finite and +Inf", c.KL, c.KLReverse)
+	}
+
+	wantA := []int{2, 3, 5, 7, 9, 11, 12}
+	wantB := []int{1, 1, 3, 6, 9, 12, 12}
+	for i, p := range c.Percentiles {
+		if p.Q != ComparePercentiles[i] || p.A != wantA[i] || p.B != wantB[i] || p.Diff != wantB[i]-wantA[i] {
+			t.Errorf("percentile %v: got %+v, want %d and %d", ComparePercentiles[i], p, wantA[i], wantB[i])
+		}
+	}
+
+	if len(c.Values) != 12 {
+		t.Fatalf("got %d values, want 1 to 12", len(c.Values))
+	}
+	for i, v := range c.Values {
+		a := float64(6-abs(v.Value-7)) / 36
+		if v.Value == 1 {
+			a = 0
+		}
+		if v.Value != i+1 || !near(v.A, a) || !near(v.B, 1./12) || !near(v.Diff, 1./12-a) {
+			t.Errorf("value %d: got %+v, want %d with %v and %v", i+1, v, i+1, a, 1./12)
+		}
+	}
+}
+
+func TestCompareTies(t *testing.T) {
+	for _, c := range []struct {
+		a, b             Distribution
+		equal, sameShape bool
+		tv               float64
+	}{
+		{dist(t, "2d6"), dist(t, "1d6+1d6"), true, true, 0},
+		{dist(t, "2d6"), dist(t, "2d6+1"), false, true, 6. / 36},
+		{dist(t, "1d6"), dist(t, "1d6+6"), false, true, 1},
+		// estimates aren't normalized
+		{dist(t, "1d4"), Distribution{1: 25, 2: 25, 3: 25, 4: 25}, true, true, 0},
+		{dist(t, "1d4"), Distribution{1: 26, 2: 25, 3: 25, 4: 24}, false, false, 0.01},
+	} {
+		got := Compare(c.a, c.b)
+		if got.Equal != c.equal || got.SameShape != c.sameShape || !near(got.TotalVariation, c.tv) {
+			t.Errorf("%v vs %v: got equal %v, same shape %v and total variation %v, want %v, %v and %v",
+				c.a, c.b, got.Equal, got.SameShape, got.TotalVariation, c.equal, c.sameShape, c.tv)
+		}
+		if c.equal && (!near(got.KL, 0) || !near(got.KLReverse, 0)) {
+			t.Errorf("%v vs %v: got KL %v and reverse %v for equal distributions", c.a, c.b, got.KL, got.KLReverse)
+		}
+	}
+}
+
+func TestContest(t *testing.T) {
+	// 2d6 beats 1d12 when 1d12 is under it, (E[2d6]-1)/12 of the time, and ties 1/12
+	for _, c := range []struct {
+		query          string
+		p              float64
+		win, tie, lose float64
+	}{
+		{"vs 1d12", 0.5, 0.5, 1. / 12, 5. / 12},
+		{"> 1d12", 0.5, 0.5, 1. / 12, 5. / 12},
+		{">= 1d12", 7. / 12, 0.5, 1. / 12, 5. / 12},
+		{"< 1d12", 5. / 12, 0.5, 1. / 12, 5. / 12},
+		{"= 1d12", 1. / 12, 0.5, 1. / 12, 5. / 12},
+	} {
+		q, err := ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := q.Exact(MustParse("2d6"), nil)
+		if err != nil {
+			t.Errorf("2d6 %s: %v", c.query, err)
+			continue
+		}
+		if !near(a.P, c.p) || !near(a.Win, c.win) || !near(a.Tie, c.tie) || !near(a.Lose, c.lose) {
+			t.Errorf("2d6 %s: got P %v, win %v, tie %v, lose %v, want %v, %v, %v, %v", c.query, a.P, a.Win, a.Tie, a.Lose, c.p, c.win, c.tie, c.lose)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package roll
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DesignDice are the die sizes Designs use by default, the standard dice of common.go that are
+// rolled as a total
+var DesignDice = []int{4, 6, 8, 10, 12, 20}
+
+// DesignGoal measures how far a candidate distribution misses what a designer asked for, in the
+// units of its totals so that goals can be summed
+type DesignGoal func(d Distribution) float64
+
+// DesignMean asks for a mean of m
+func DesignMean(m float64) DesignGoal {
+	return func(d Distribution) float64 { return math.Abs(d.Mean() - m) }
+}
+
+// DesignVariance asks for a variance of v, measured by the difference in standard deviation. A
+// variance of 0 asks for as little spread as possible.
+func DesignVariance(v float64) DesignGoal {
+	sd := math.Sqrt(v)
+	return func(d Distribution) float64 { return math.Abs(d.StdDev() - sd) }
+}
+
+// DesignRange asks for totals between min and max inclusive
+func DesignRange(min, max int) DesignGoal {
+	return func(d Distribution) float64 {
+		return math.Abs(float64(d.Min()-min)) + math.Abs(float64(d.Max()-max))
+	}
+}
+
+// DesignDistribution asks for a distribution like target, measured by the earth mover's
+// distance: how far, on average, probability has to move to turn one into the other
+func DesignDistribution(target Distribution) DesignGoal {
+	target = target.Normalize()
+	return func(d Distribution) float64 { return earthMover(d, target) }
+}
+
+// earthMover returns the area between the cumulative distributions of a and b
+func earthMover(a, b Distribution) float64 {
+	all := make(Distribution, len(a)+len(b))
+	for v := range a {
+		all[v] = 1
+	}
+	for v := range b {
+		all[v] = 1
+	}
+
+	var (
+		values = all.Values()
+		ca, cb float64
+		t      float64
+	)
+	for i, v := range values {
+		ca += a[v]
+		cb += b[v]
+		if i+1 < len(values) {
+			t += math.Abs(ca-cb) * float64(values[i+1]-v)
+		}
+	}
+
+	return t
+}
+
+// Design describes the expressions Designer tries. A zero Design tries up to 6 dice of up to 2
+// sizes from DesignDice with no keeps or modifiers.
+type Design struct {
+	// Dice are the die sizes used, DesignDice if empty
+	Dice []int
+	// MaxDice is the most dice rolled by a candidate, 6 if 0
+	MaxDice int
+	// MaxTypes is the most die sizes mixed in a candidate, 2 if 0. Keeps only apply to pools of
+	// a single size.
+	MaxTypes int
+	// Keep also tries keeping the highest or lowest of a pool, such as 4d6Kh3 or 2d20Kl1
+	Keep bool
+	// MinMod and MaxMod are the range of flat modifiers added to every candidate
+	MinMod, MaxMod int
+	// Top is the number of candidates returned, 10 if 0
+	Top int
+}
+
+func (s Design) withDefaults() Design {
+	if len(s.Dice) == 0 {
+		s.Dice = DesignDice
+	}
+	if s.MaxDice <= 0 {
+		s.MaxDice = 6
+	}
+	if s.MaxTypes <= 0 {
+		s.MaxTypes = 2
+	}
+	if s.Top <= 0 {
+		s.Top = 10
+	}
+
+	return s
+}
+
+// Candidate is an expression found by Designer and how far it misses its goals
+type Candidate struct {
+	Expr   string       `json:"expr"`
+	Dist   Distribution `json:"-"`
+	Mean   float64      `json:"mean"`
+	StdDev float64      `json:"stddev"`
+	Min    int          `json:"min"`
+	Max    int          `json:"max"`
+	Miss   float64      `json:"miss"`
+
+	dice int
+}
+
+// Designer returns the s.Top expressions described by s whose exact distributions miss goals by
+// the least, summing the misses of every goal. Ties are broken by the fewest dice. Candidates
+// whose distributions can't be computed exactly are skipped. An error is returned if there are
+// no goals, s allows no dice or a die has fewer than 2 sides.
+func Designer(s Design, goals ...DesignGoal) ([]Candidate, error) {
+	s = s.withDefaults()
+
+	if len(goals) == 0 {
+		return nil, fmt.Errorf("no goals to design for")
+	}
+	if s.MaxMod < s.MinMod {
+		return nil, fmt.Errorf("can't add modifiers between %d and %d", s.MinMod, s.MaxMod)
+	}
+	for _, n := range s.Dice {
+		if n < 2 {
+			return nil, fmt.Errorf("can't design with a d%d, dice need at least 2 sides", n)
+		}
+	}
+
+	var out []Candidate
+	for _, p := range designPools(s) {
+		e, err := Parse(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		base, err := e.Distribution(nil)
+		if err == ErrInexact {
+			// Pools too large to enumerate, such as 8d20Kh1, are left out
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p.expr, err)
+		}
+
+		for m := s.MinMod; m <= s.MaxMod; m++ {
+			d, expr := base, p.expr
+			if m != 0 {
+				d = base.Map(func(v int) int { return v + m })
+				expr = fmt.Sprintf("%s%+d", p.expr, m)
+			}
+
+			miss := 0.
+			for _, g := range goals {
+				miss += g(d)
+			}
+			out = append(out, Candidate{Expr: expr, Dist: d, Miss: miss, dice: p.dice})
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no expressions to try")
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Miss != out[j].Miss {
+			return out[i].Miss < out[j].Miss
+		}
+		return out[i].dice < out[j].dice
+	})
+	if len(out) > s.Top {
+		out = out[:s.Top]
+	}
+
+	for i, c := range out {
+		out[i].Mean, out[i].StdDev = c.Dist.Mean(), c.Dist.StdDev()
+		out[i].Min, out[i].Max = c.Dist.Min(), c.Dist.Max()
+	}
+
+	return out, nil
+}
+
+// designPool is the dice of a candidate before any modifier
+type designPool struct {
+	expr string
+	dice int
+}
+
+// designPools returns every pool of dice described by s, without modifiers
+func designPools(s Design) []designPool {
+	var sizes []int
+	for _, n := range s.Dice {
+		if !matches(n, sizes) {
+			sizes = append(sizes, n)
+		}
+	}
+	sort.Ints(sizes)
+
+	var (
+		out  []designPool
+		pool func(from, types, dice int, expr string)
+	)
+
+	// pool adds the pools that extend expr with sizes from sizes[from:]
+	pool = func(from, types, dice int, expr string) {
+		if types == s.MaxTypes {
+			return
+		}
+
+		for i := from; i < len(sizes); i++ {
+			for n := 1; dice+n <= s.MaxDice; n++ {
+				term := fmt.Sprintf("%dd%d", n, sizes[i])
+				if expr != "" {
+					term = expr + "+" + term
+				}
+
+				out = append(out, designPool{expr: term, dice: dice + n})
+				pool(i+1, types+1, dice+n, term)
+
+				if s.Keep && expr == "" {
+					for k := 1; k < n; k++ {
+						out = append(out,
+							designPool{expr: fmt.Sprintf("%sKh%d", term, k), dice: n},
+							designPool{expr: fmt.Sprintf("%sKl%d", term, k), dice: n},
+						)
+					}
+				}
+			}
+		}
+	}
+	pool(0, 0, 0, "")
+
+	return out
+}
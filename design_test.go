@@ -0,0 +1,41 @@
+package roll
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDesignerInvalidDice(t *testing.T) {
+	for _, n := range []int{1, 0, -6} {
+		_, err := Designer(Design{Dice: []int{6, n}}, DesignMean(7))
+		if err == nil || !strings.Contains(err.Error(), "at least 2 sides") {
+			t.Errorf("d%d: got %v, want an error about its sides", n, err)
+		}
+	}
+}
+
+func TestDesigner(t *testing.T) {
+	out, err := Designer(Design{Dice: []int{6}, MaxDice: 3, Top: 1}, DesignMean(7), DesignVariance(35./6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Expr != "2d6" || out[0].Miss > 1e-9 {
+		t.Errorf("got %+v, want 2d6", out)
+	}
+}
+
+// TestDesignerSkipsInexact checks that pools too large to enumerate are left out rather than
+// ending the search
+func TestDesignerSkipsInexact(t *testing.T) {
+	if _, err := MustParse("4d100Kh1").Distribution(nil); err != ErrInexact {
+		t.Fatalf("4d100Kh1 should be inexact, got %v", err)
+	}
+
+	out, err := Designer(Design{Dice: []int{100}, MaxDice: 4, Keep: true, Top: 3}, DesignMean(150))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 {
+		t.Errorf("got %d candidates, want 3", len(out))
+	}
+}
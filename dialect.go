@@ -0,0 +1,744 @@
+package roll
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dialect is the dice notation of another dice roller, such as Roll20's 4d6kh3 or Avrae's
+// 1d20ro<3. Dialect.Parse translates its notation to an Expr that rolls exactly as it would in
+// that roller and Dialect.Format prints an Expr back out in it. Notation with no go-roll
+// equivalent, such as Roll20's compounding explosions or Foundry's success counting, is an
+// error rather than being rolled differently; cosmetic notation such as sorting, critical
+// highlighting and [flavour text] is ignored.
+/* For Example:
+
+   e, err := roll.Roll20.Parse("4d6kh3+d20!")
+   fmt.Println(e) // 4d6Kh3+1d20X20
+
+   s, err := roll.Avrae.Format(roll.MustParse("4d6Dl1+1d20Ro1"))
+   fmt.Println(s) // 4d6pl1+1d20ro1
+
+*/
+type Dialect struct {
+	Name string
+
+	// mods translate the modifiers that follow a dice term to native ones. They're tried in
+	// order and the first to match is used.
+	mods []dialectMod
+	// print renders a native modifier of a die with sides sides (0 if not known), returning
+	// false if the dialect has no equivalent
+	print func(m modifier, sides int) (string, bool)
+	// vars matches a variable reference, capturing its name, and varFmt prints one
+	vars   *regexp.Regexp
+	varFmt string
+	// pools is set when {2d20}kh style pools are supported
+	pools bool
+	// merge is set when consecutive modifiers of the same kind select the union of their faces,
+	// as Roll20's r1r3 rerolls both 1s and 3s
+	merge bool
+}
+
+// dialectMod translates a modifier matched by re at the start of the text following a dice term
+type dialectMod struct {
+	re     *regexp.Regexp
+	native func(m []string, sides int) (string, error)
+}
+
+// Dialects supported by Dialect.Parse and Dialect.Format
+var (
+	// Native is go-roll's own notation, as read by Parse
+	Native = &Dialect{Name: "native"}
+
+	// Roll20 is the notation of Roll20's dice roller, i.e 4d6kh3, 1d6!, 2d20r<2cs>19
+	Roll20 = &Dialect{
+		Name: "Roll20",
+		mods: []dialectMod{
+			unsupported(`!!`, "compounding explosions"),
+			unsupported(`!p`, "penetrating explosions"),
+			{rx(`!` + `(?:` + roll20Point + `)?`), explodeMod("X", true)},
+			{rx(`ro` + roll20Point), pointMod("Ro", true)},
+			{rx(`r` + roll20Point), pointMod("R", true)},
+			{rx(`(k|d)(h|l)?(\d*)`), selectMod},
+			ignored(`c[sf]` + roll20Point),
+			ignored(`s[ad]?`),
+			unsupported(`mt\d*`, "counting matched dice"),
+			ignored(`m\d*`),
+			unsupported(`f|[<>=]`, "counting successes"),
+		},
+		print:  roll20Print,
+		vars:   rx(`@\{([A-Za-z_][A-Za-z0-9_]*)\}`),
+		varFmt: "@{%s}",
+		pools:  true,
+		merge:  true,
+	}
+
+	// Foundry is the notation of Foundry VTT, i.e 4d6kh3x6, {2d20}kh, 1d10r<3
+	Foundry = &Dialect{
+		Name: "Foundry",
+		mods: []dialectMod{
+			unsupported(`xo`, "exploding once"),
+			{rx(`x` + `(?:` + foundryPoint + `)?`), explodeMod("X", false)},
+			{rx(`rr` + `(?:` + foundryPoint + `)?`), pointMod("R", false)},
+			{rx(`r` + `(?:` + foundryPoint + `)?`), pointMod("Ro", false)},
+			unsupported(`cs|cf|sf|df|ms`, "counting successes"),
+			{rx(`(k|d)(h|l)?(\d*)`), selectMod},
+			unsupported(`min|max`, "minimum and maximum results"),
+			unsupported(`even|odd`, "counting even or odd results"),
+		},
+		print:  foundryPrint,
+		vars:   rx(`@([A-Za-z_][A-Za-z0-9_]*)`),
+		varFmt: "@%s",
+		pools:  true,
+	}
+
+	// Avrae is the notation of the Avrae Discord bot, i.e 4d6kh3, 1d20ro<3, 8d6e6, 4d6p1
+	Avrae = &Dialect{
+		Name: "Avrae",
+		mods: []dialectMod{
+			unsupported(`ra`, "rerolling and adding"),
+			unsupported(`mi|ma`, "minimum and maximum results"),
+			{rx(`rr` + avraeSelector), avraeMod("R")},
+			{rx(`ro` + avraeSelector), avraeMod("Ro")},
+			{rx(`e` + avraeSelector), avraeMod("X")},
+			{rx(`k` + avraeSelector), avraeMod("K")},
+			{rx(`p` + avraeSelector), avraeMod("D")},
+		},
+		print: avraePrint,
+		merge: true,
+	}
+
+	dialects = map[string]*Dialect{
+		"native":  Native,
+		"roll20":  Roll20,
+		"foundry": Foundry,
+		"avrae":   Avrae,
+	}
+)
+
+// poolTerm is a member of a {...} pool
+var poolTerm = regexp.MustCompile(`^(\d*)d(\d+)$`)
+
+// Compare points accepted after a modifier, capturing the comparison and number
+const (
+	roll20Point   = `(>|<|=)?(\d+)`
+	foundryPoint  = `(>=|<=|>|<|=)?(\d+)`
+	avraeSelector = `(h|l|>|<)?(\d+)`
+)
+
+// LookupDialect returns the Dialect called name, ignoring case: native, roll20, foundry or avrae
+func LookupDialect(name string) (*Dialect, bool) {
+	d, ok := dialects[strings.ToLower(name)]
+	return d, ok
+}
+
+// DialectNames returns the names accepted by LookupDialect, sorted
+func DialectNames() []string {
+	var out []string
+	for name := range dialects {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Variable returns the dialect's reference to the variable name, i.e @{name} in Roll20, or an
+// empty string if it has no variables
+func (d *Dialect) Variable(name string) string {
+	switch {
+	case d.mods == nil:
+		return "$" + name
+	case d.varFmt == "":
+		return ""
+	}
+
+	return fmt.Sprintf(d.varFmt, name)
+}
+
+// Parse reads s in the dialect's notation. Errors are *ParseErrors positioned in s.
+func (d *Dialect) Parse(s string) (*Expr, error) {
+	if d.mods == nil {
+		return Parse(s)
+	}
+
+	s = strings.TrimSpace(s)
+	t := &translator{d: d, src: s}
+	if err := t.run(); err != nil {
+		return nil, err
+	}
+
+	e, err := Parse(t.out.String())
+	if pe, ok := err.(*ParseError); ok {
+		return nil, t.position(pe)
+	}
+
+	return e, err
+}
+
+// Format prints e in the dialect's notation. Comparisons, ternaries, bands and modifiers the
+// dialect can't express are an error.
+func (d *Dialect) Format(e *Expr) (string, error) {
+	if d.mods == nil {
+		return e.String(), nil
+	}
+	if e.bands != nil {
+		return "", fmt.Errorf("%s has no equivalent of bands", d.Name)
+	}
+
+	return d.format(e.root)
+}
+
+func (d *Dialect) format(n node) (string, error) {
+	switch n := n.(type) {
+	case numNode:
+		return n.String(), nil
+
+	case varNode:
+		if d.varFmt == "" {
+			return "", fmt.Errorf("%s has no equivalent of variables: %s", d.Name, n)
+		}
+		return fmt.Sprintf(d.varFmt, string(n)), nil
+
+	case *groupNode:
+		x, err := d.format(n.x)
+		return "(" + x + ")", err
+
+	case *negNode:
+		x, err := d.format(n.x)
+		return "-" + x, err
+
+	case *binaryNode:
+		l, err := d.format(n.l)
+		if err != nil {
+			return "", err
+		}
+		r, err := d.format(n.r)
+		return l + n.op + r, err
+
+	case *diceNode:
+		var b strings.Builder
+
+		count, err := d.format(n.count)
+		if err != nil {
+			return "", err
+		}
+		sides, err := d.format(n.sides)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(count + "d" + sides)
+
+		for _, m := range n.mods {
+			s, ok := d.print(m, len(n.die.faces))
+			if !ok {
+				return "", fmt.Errorf("%s has no equivalent of %s in %s", d.Name, m.src, n)
+			}
+			b.WriteString(s)
+		}
+		return b.String(), nil
+	}
+
+	return "", fmt.Errorf("%s has no equivalent of %s", d.Name, n)
+}
+
+// translator rewrites a dialect's notation as native notation, recording the position in src
+// of every byte written so that errors from Parse can be placed in src
+type translator struct {
+	d   *Dialect
+	src string
+	pos int
+	out strings.Builder
+	at  []int
+	// value is set when the last thing written can be the count of a dice term
+	value bool
+}
+
+// emit writes s to the output as the translation of src[from:]
+func (t *translator) emit(s string, from int) {
+	t.out.WriteString(s)
+	for i := 0; i < len(s); i++ {
+		t.at = append(t.at, from)
+	}
+}
+
+// errorf returns a *ParseError for src[pos:end]
+func (t *translator) errorf(kind ParseErrorKind, pos, end int, format string, args ...interface{}) error {
+	pe := parseErr(kind, t.src, pos, end, format, args...)
+	pe.Suggestion = ""
+	return pe
+}
+
+// position moves a *ParseError in the translated output to the part of src it came from
+func (t *translator) position(pe *ParseError) *ParseError {
+	out := *pe
+	out.Input, out.Suggestion = t.src, ""
+	out.Pos, out.End = len(t.src), len(t.src)+1
+
+	if pe.Pos < len(t.at) {
+		out.Pos = t.at[pe.Pos]
+		out.End = out.Pos + 1
+	}
+	if pe.End > pe.Pos && pe.End-1 < len(t.at) && t.at[pe.End-1] >= out.Pos {
+		out.End = t.at[pe.End-1] + 1
+	}
+
+	return &out
+}
+
+func (t *translator) run() error {
+	for t.pos < len(t.src) {
+		var (
+			c    = t.src[t.pos]
+			rest = t.src[t.pos:]
+		)
+
+		switch {
+		case c == ' ' || c == '\t':
+			t.emit(string(c), t.pos)
+			t.pos++
+
+		case c >= '0' && c <= '9':
+			n := scanNum.FindString(rest)
+			t.emit(n, t.pos)
+			t.pos += len(n)
+			t.value = true
+
+		case strings.IndexByte("+-*(", c) >= 0:
+			t.emit(string(c), t.pos)
+			t.pos++
+			t.value = false
+
+		case c == ')':
+			t.emit(")", t.pos)
+			t.pos++
+			t.value = true
+
+		case c == '[':
+			if err := t.flavour(); err != nil {
+				return err
+			}
+
+		case t.d.vars != nil && t.d.vars.MatchString(rest):
+			m := t.d.vars.FindStringSubmatch(rest)
+			t.emit("$"+m[1], t.pos)
+			t.pos += len(m[0])
+			// A variable followed by a dice term must be separated from it
+			if t.pos < len(t.src) && (t.src[t.pos] == 'd' || t.src[t.pos] == 'D') {
+				t.emit(" ", t.pos)
+			}
+			t.value = true
+
+		case c == 'd' || c == 'D':
+			if err := t.dice(); err != nil {
+				return err
+			}
+
+		case c == '{' && t.d.pools:
+			if err := t.pool(); err != nil {
+				return err
+			}
+
+		default:
+			return t.errorf(SyntaxError, t.pos, t.pos+1, "%c is not part of %s notation", c, t.d.Name)
+		}
+	}
+
+	return nil
+}
+
+// flavour skips [text] annotating a term
+func (t *translator) flavour() error {
+	end := strings.IndexByte(t.src[t.pos:], ']')
+	if end < 0 {
+		return t.errorf(Unbalanced, t.pos, len(t.src), "missing ]")
+	}
+
+	t.pos += end + 1
+	return nil
+}
+
+// dice translates a dice term from its d onwards, adding a count of 1 if there isn't one
+func (t *translator) dice() error {
+	start := t.pos
+	if !t.value {
+		t.emit("1", start)
+	}
+	t.emit("d", start)
+	t.pos++
+
+	sides, rest := 0, t.src[t.pos:]
+	switch {
+	case strings.HasPrefix(rest, "%"):
+		sides = 100
+		t.emit("100", t.pos)
+		t.pos++
+
+	case strings.HasPrefix(rest, "F") || strings.HasPrefix(rest, "f"):
+		return t.errorf(InvalidDie, start, t.pos+1, "go-roll has no equivalent of fate dice in expressions")
+
+	case scanNum.MatchString(rest):
+		n := scanNum.FindString(rest)
+		sides, _ = strconv.Atoi(n)
+		t.emit(n, t.pos)
+		t.pos += len(n)
+
+	default:
+		// Parenthesised or variable sides are translated by run, without modifiers that need
+		// to know the sides
+		t.value = false
+		return nil
+	}
+
+	return t.modifiers(sides)
+}
+
+// pool translates a {...} pool of identical dice, such as {2d20} or {1d20,1d20}, to a single
+// dice term
+func (t *translator) pool() error {
+	start := t.pos
+	end := strings.IndexByte(t.src[t.pos:], '}')
+	if end < 0 {
+		return t.errorf(Unbalanced, start, len(t.src), "missing }")
+	}
+
+	count, sides := 0, 0
+	for _, item := range strings.Split(t.src[start+1:start+end], ",") {
+		m := poolTerm.FindStringSubmatch(strings.TrimSpace(item))
+		if m == nil {
+			return t.errorf(InvalidDie, start, start+end+1, "only pools of one kind of die, such as {2d20} or {1d20,1d20}, have an equivalent in go-roll")
+		}
+
+		n, s := 1, 0
+		if m[1] != "" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		s, _ = strconv.Atoi(m[2])
+		if sides != 0 && s != sides {
+			return t.errorf(InvalidDie, start, start+end+1, "only pools of one kind of die, such as {2d20} or {1d20,1d20}, have an equivalent in go-roll")
+		}
+		count, sides = count+n, s
+	}
+
+	t.emit(fmt.Sprintf("%dd%d", count, sides), start)
+	t.pos = start + end + 1
+
+	return t.modifiers(sides)
+}
+
+// modifiers translates the modifiers following a dice term with sides sides
+func (t *translator) modifiers(sides int) error {
+	t.value = true
+
+	var prev string
+	for t.pos < len(t.src) {
+		var (
+			rest    = t.src[t.pos:]
+			matched bool
+		)
+
+		for _, mod := range t.d.mods {
+			m := mod.re.FindStringSubmatch(rest)
+			if m == nil {
+				continue
+			}
+
+			s, err := mod.native(m, sides)
+			if err != nil {
+				return t.errorf(InvalidModifier, t.pos, t.pos+len(m[0]), "%s: %v", m[0], err)
+			}
+
+			// A modifier of the same kind as the last, such as the Kn2 following Kn1, joins it.
+			// Keeps or drops that mix selectors, such as kh1kl1, can't be joined.
+			kind := strings.TrimRight(s, "0123456789,")
+			switch {
+			case t.d.merge && kind == prev && valueMods[kind]:
+				t.emit(","+s[len(kind):], t.pos)
+			case t.d.merge && prev != "" && kind != "" && strings.Contains("KD", kind[:1]) && kind[0] == prev[0]:
+				return t.errorf(InvalidModifier, t.pos, t.pos+len(m[0]), "%s: go-roll has no equivalent of combining different selectors", m[0])
+			default:
+				t.emit(s, t.pos)
+			}
+			t.pos += len(m[0])
+			prev, matched = kind, true
+			break
+		}
+
+		if !matched {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// valueMods are the native modifiers that take a list of faces
+var valueMods = map[string]bool{"Kn": true, "Dn": true, "X": true, "R": true, "Ro": true}
+
+// rx compiles a pattern anchored to the start of the text
+func rx(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(`^(?:` + pattern + `)`)
+}
+
+// unsupported is a modifier that has no go-roll equivalent
+func unsupported(pattern, what string) dialectMod {
+	return dialectMod{rx(pattern), func([]string, int) (string, error) {
+		return "", fmt.Errorf("go-roll has no equivalent of %s", what)
+	}}
+}
+
+// ignored is a modifier that only changes how a roll is displayed
+func ignored(pattern string) dialectMod {
+	return dialectMod{rx(pattern), func([]string, int) (string, error) { return "", nil }}
+}
+
+// selectMod translates k, kh3, dl1 etc. A bare k or d keeps the highest or drops the lowest.
+func selectMod(m []string, sides int) (string, error) {
+	op, hl, n := strings.ToUpper(m[1]), m[2], m[3]
+	if hl == "" {
+		hl = map[string]string{"K": "h", "D": "l"}[op]
+	}
+	if n == "" {
+		n = "1"
+	}
+
+	return op + hl + n, nil
+}
+
+// explodeMod translates an explosion with an optional compare point, which defaults to the
+// highest face
+func explodeMod(native string, inclusive bool) func([]string, int) (string, error) {
+	return func(m []string, sides int) (string, error) {
+		if m[2] == "" {
+			if sides == 0 {
+				return "", fmt.Errorf("exploding on the highest face needs a number of sides")
+			}
+			return native + strconv.Itoa(sides), nil
+		}
+
+		return pointMod(native, inclusive)(m, sides)
+	}
+}
+
+// pointMod translates a modifier with a compare point, i.e r<2 or x>=5
+func pointMod(native string, inclusive bool) func([]string, int) (string, error) {
+	return func(m []string, sides int) (string, error) {
+		if m[2] == "" {
+			m = []string{m[0], "=", "1"}
+		}
+
+		faces, err := comparePoint(m[1], m[2], sides, inclusive)
+		if err != nil {
+			return "", err
+		}
+
+		return native + joinInts(faces), nil
+	}
+}
+
+// avraeMod translates Avrae's selectors: h3 and l3 select the highest or lowest three, >3 and
+// <3 the faces above or below 3 and 3 the faces that rolled 3
+func avraeMod(native string) func([]string, int) (string, error) {
+	return func(m []string, sides int) (string, error) {
+		if m[1] == "h" || m[1] == "l" {
+			if native != "K" && native != "D" {
+				return "", fmt.Errorf("go-roll has no equivalent of selecting the highest or lowest dice to reroll or explode")
+			}
+			return native + m[1] + m[2], nil
+		}
+
+		faces, err := comparePoint(m[1], m[2], sides, false)
+		if err != nil {
+			return "", err
+		}
+		if native == "K" || native == "D" {
+			return native + "n" + joinInts(faces), nil
+		}
+
+		return native + joinInts(faces), nil
+	}
+}
+
+// comparePoint returns the faces of a die with sides sides that satisfy op n. When inclusive, as
+// in Roll20, > and < include n.
+func comparePoint(op, n string, sides int, inclusive bool) ([]int, error) {
+	v, err := strconv.Atoi(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", n)
+	}
+
+	lo, hi := v, v
+	switch op {
+	case ">", ">=":
+		if sides == 0 {
+			return nil, fmt.Errorf("%s needs a number of sides", op)
+		}
+		if op == ">" && !inclusive {
+			lo++
+		}
+		hi = sides
+	case "<", "<=":
+		if op == "<" && !inclusive {
+			hi--
+		}
+		lo = 1
+	}
+
+	var out []int
+	for f := lo; f <= hi; f++ {
+		out = append(out, f)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s%s matches no faces", op, n)
+	}
+
+	return out, nil
+}
+
+// joinInts joins ns with commas
+func joinInts(ns []int) string {
+	var out []string
+	for _, n := range ns {
+		out = append(out, strconv.Itoa(n))
+	}
+
+	return strings.Join(out, ",")
+}
+
+// points groups the faces in match into compare points in the style of a dialect: a run of the
+// highest faces is written by ge given its lowest face, a run from 1 by le given its highest face
+// and anything else as single faces
+func points(match []int, sides int, ge, le func(int) string) []string {
+	sorted := append([]int(nil), match...)
+	sort.Ints(sorted)
+
+	run := true
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] != sorted[i-1]+1 {
+			run = false
+		}
+	}
+
+	if l := len(sorted); run && l > 1 {
+		switch {
+		case sides > 0 && sorted[l-1] == sides:
+			return []string{ge(sorted[0])}
+		case sorted[0] == 1:
+			return []string{le(sorted[l-1])}
+		}
+	}
+
+	var out []string
+	for _, f := range sorted {
+		out = append(out, strconv.Itoa(f))
+	}
+
+	return out
+}
+
+// printSelect prints a keep or drop of the highest or lowest dice with the dialect's keep and
+// drop letters
+func printSelect(m modifier, keep, drop string) (string, bool) {
+	op := keep
+	if m.kind == modDrop {
+		op = drop
+	}
+
+	switch m.hl {
+	case HIGH:
+		return fmt.Sprintf("%sh%d", op, m.n), true
+	case LOW:
+		return fmt.Sprintf("%sl%d", op, m.n), true
+	}
+
+	return "", false
+}
+
+// single prints a modifier that takes one compare point
+func single(prefix string, pts []string) (string, bool) {
+	if len(pts) != 1 {
+		return "", false
+	}
+
+	return prefix + pts[0], true
+}
+
+// each prints a modifier that can be repeated for every compare point
+func each(prefix string, pts []string) (string, bool) {
+	var b strings.Builder
+	for _, p := range pts {
+		b.WriteString(prefix + p)
+	}
+
+	return b.String(), true
+}
+
+func roll20Print(m modifier, sides int) (string, bool) {
+	pts := points(m.match, sides,
+		func(lo int) string { return fmt.Sprintf(">%d", lo) },
+		func(hi int) string { return fmt.Sprintf("<%d", hi) })
+
+	switch m.kind {
+	case modKeep, modDrop:
+		return printSelect(m, "k", "d")
+	case modExplode:
+		if len(m.match) == 1 && m.match[0] == sides {
+			return "!", true
+		}
+		return single("!", pts)
+	case modReroll:
+		return each("r", pts)
+	case modRerollOnce:
+		return single("ro", pts)
+	}
+
+	return "", false
+}
+
+func foundryPrint(m modifier, sides int) (string, bool) {
+	pts := points(m.match, sides,
+		func(lo int) string { return fmt.Sprintf(">=%d", lo) },
+		func(hi int) string { return fmt.Sprintf("<=%d", hi) })
+
+	switch m.kind {
+	case modKeep, modDrop:
+		return printSelect(m, "k", "d")
+	case modExplode:
+		if len(m.match) == 1 && m.match[0] == sides {
+			return "x", true
+		}
+		return single("x", pts)
+	case modReroll:
+		return single("rr", pts)
+	case modRerollOnce:
+		return single("r", pts)
+	}
+
+	return "", false
+}
+
+func avraePrint(m modifier, sides int) (string, bool) {
+	pts := points(m.match, sides,
+		func(lo int) string { return fmt.Sprintf(">%d", lo-1) },
+		func(hi int) string { return fmt.Sprintf("<%d", hi+1) })
+
+	switch m.kind {
+	case modKeep, modDrop:
+		return printSelect(m, "k", "p")
+	case modKeepN:
+		return each("k", pts)
+	case modDropN:
+		return each("p", pts)
+	case modExplode:
+		return each("e", pts)
+	case modReroll:
+		return each("rr", pts)
+	case modRerollOnce:
+		return each("ro", pts)
+	}
+
+	return "", false
+}
@@ -0,0 +1,172 @@
+package roll
+
+import (
+	"errors"
+	"testing"
+)
+
+// dialectCase is a line of a dialect's conformance table: native notation s parses to the
+// expression want and formats back as format, which is s itself when empty. An unsupported case
+// must fail to parse with a *ParseError at pos.
+type dialectCase struct {
+	s, want, format string
+	unsupported     bool
+	pos             int
+}
+
+var dialectConformance = map[*Dialect][]dialectCase{
+	Roll20: {
+		{s: "4d6kh3", want: "4d6Kh3"},
+		{s: "4d6k3", want: "4d6Kh3", format: "4d6kh3"},
+		{s: "4d6d1", want: "4d6Dl1", format: "4d6dl1"},
+		{s: "2d20kl1", want: "2d20Kl1"},
+		{s: "d20+5", want: "1d20+5", format: "1d20+5"},
+		{s: "d%", want: "1d100", format: "1d100"},
+		{s: "1d6!", want: "1d6X6"},
+		{s: "1d20!>19", want: "1d20X19,20"},
+		{s: "1d6r1r3", want: "1d6R1,3"},
+		{s: "1d10r<3", want: "1d10R1,2,3"},
+		{s: "1d20ro<3", want: "1d20Ro1,2,3"},
+		{s: "{2d20}kh1", want: "2d20Kh1", format: "2d20kh1"},
+		{s: "{1d20,1d20}kl", want: "2d20Kl1", format: "2d20kl1"},
+		{s: "1d20cs>19", want: "1d20", format: "1d20"},
+		{s: "4d6sa", want: "4d6", format: "4d6"},
+		{s: "2d6[fire]+3", want: "2d6+3", format: "2d6+3"},
+		{s: "1d6+@{str}", want: "1d6+$str"},
+		{s: "2d6kh1+3*(1d4-1)", want: "2d6Kh1+3*(1d4-1)"},
+		{s: "1d6!!", unsupported: true, pos: 3},
+		{s: "1d6!p", unsupported: true, pos: 3},
+		{s: "3d6mt", unsupported: true, pos: 3},
+		{s: "1d20>10", unsupported: true, pos: 4},
+		{s: "1d6+@str", unsupported: true, pos: 4},
+		{s: "8d6e6", unsupported: true, pos: 3},
+	},
+	Foundry: {
+		{s: "4d6kh3", want: "4d6Kh3"},
+		{s: "4d6d1", want: "4d6Dl1", format: "4d6dl1"},
+		{s: "2d20kl1", want: "2d20Kl1"},
+		{s: "d%", want: "1d100", format: "1d100"},
+		{s: "1d6x", want: "1d6X6"},
+		{s: "1d20x>=19", want: "1d20X19,20"},
+		{s: "1d6r1", want: "1d6Ro1"},
+		{s: "1d10r<3", want: "1d10Ro1,2", format: "1d10r<=2"},
+		{s: "1d10rr1", want: "1d10R1"},
+		{s: "1d6rr<=3", want: "1d6R1,2,3"},
+		{s: "{2d20}kh1", want: "2d20Kh1", format: "2d20kh1"},
+		{s: "2d6[fire]+3", want: "2d6+3", format: "2d6+3"},
+		{s: "1d6+@str", want: "1d6+$str"},
+		{s: "2d6kh1+3*(1d4-1)", want: "2d6Kh1+3*(1d4-1)"},
+		{s: "1d6xo", unsupported: true, pos: 3},
+		{s: "1d20cs>19", unsupported: true, pos: 4},
+		{s: "1d6min2", unsupported: true, pos: 3},
+		{s: "1d6!", unsupported: true, pos: 3},
+		{s: "1d6+@{str}", unsupported: true, pos: 4},
+	},
+	Avrae: {
+		{s: "4d6kh3", want: "4d6Kh3"},
+		{s: "4d6k3", want: "4d6Kn3"},
+		{s: "4d6k>3", want: "4d6Kn4,5,6"},
+		{s: "4d6p1", want: "4d6Dn1"},
+		{s: "4d6ph1", want: "4d6Dh1"},
+		{s: "2d20kl1", want: "2d20Kl1"},
+		{s: "d%", want: "1d100", format: "1d100"},
+		{s: "8d6e6", want: "8d6X6"},
+		{s: "1d20e>18", want: "1d20X19,20"},
+		{s: "1d6e2e4", want: "1d6X2,4"},
+		{s: "1d20ro1", want: "1d20Ro1"},
+		{s: "1d20ro<3", want: "1d20Ro1,2"},
+		{s: "1d6rr1rr3", want: "1d6R1,3"},
+		{s: "1d6rr<4", want: "1d6R1,2,3"},
+		{s: "2d6[fire]+3", want: "2d6+3", format: "2d6+3"},
+		{s: "2d6kh1+3*(1d4-1)", want: "2d6Kh1+3*(1d4-1)"},
+		{s: "4d6ra1", unsupported: true, pos: 3},
+		{s: "1d6mi2", unsupported: true, pos: 3},
+		{s: "1d6!", unsupported: true, pos: 3},
+		{s: "{2d20}kh1", unsupported: true, pos: 0},
+		{s: "1d6+@str", unsupported: true, pos: 4},
+	},
+}
+
+func TestDialectConformance(t *testing.T) {
+	for d, cases := range dialectConformance {
+		for _, c := range cases {
+			e, err := d.Parse(c.s)
+			if c.unsupported {
+				var pe *ParseError
+				if !errors.As(err, &pe) {
+					t.Errorf("%s %s: got %v, want a *ParseError", d.Name, c.s, err)
+				} else if pe.Pos != c.pos {
+					t.Errorf("%s %s: error at %d, want %d: %v", d.Name, c.s, pe.Pos, c.pos, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %s: %v", d.Name, c.s, err)
+				continue
+			}
+			if e.String() != c.want {
+				t.Errorf("%s %s: parsed as %s, want %s", d.Name, c.s, e, c.want)
+			}
+
+			want := c.format
+			if want == "" {
+				want = c.s
+			}
+			f, err := d.Format(e)
+			if err != nil || f != want {
+				t.Errorf("%s %s: formatted as %q, %v, want %q", d.Name, c.s, f, err, want)
+				continue
+			}
+
+			// Formatted notation reads back as the same expression
+			back, err := d.Parse(f)
+			if err != nil || back.String() != c.want {
+				t.Errorf("%s %s: %s parsed back as %v, %v", d.Name, c.s, f, back, err)
+			}
+		}
+	}
+}
+
+// TestDialectFormatUnsupported checks that native notation a dialect can't express is an error
+// rather than being formatted as something that rolls differently
+func TestDialectFormatUnsupported(t *testing.T) {
+	for d, exprs := range map[*Dialect][]string{
+		Roll20:  {"1d6Kn5,6", "4d6Dn1", "3d6Km1", "1d6X2,4", "2d6>7", "1d6>3?1:0"},
+		Foundry: {"1d6R1,3", "1d6Kn5,6", "4d6Dn1", "3d6Km1", "2d6>7"},
+		Avrae:   {"3d6Km1", "2d6>7", "1d6+$str"},
+	} {
+		for _, s := range exprs {
+			if f, err := d.Format(MustParse(s)); err == nil {
+				t.Errorf("%s %s: formatted as %q, want an error", d.Name, s, f)
+			}
+		}
+	}
+}
+
+func TestDialectNative(t *testing.T) {
+	for _, name := range DialectNames() {
+		if _, ok := LookupDialect(name); !ok {
+			t.Errorf("%s is listed but can't be looked up", name)
+		}
+	}
+	if d, ok := LookupDialect("ROLL20"); !ok || d != Roll20 {
+		t.Errorf("dialect names should ignore case")
+	}
+
+	for d, want := range map[*Dialect]string{Native: "$str", Roll20: "@{str}", Foundry: "@str", Avrae: ""} {
+		if got := d.Variable("str"); got != want {
+			t.Errorf("%s: variable written as %q, want %q", d.Name, got, want)
+		}
+	}
+
+	for _, s := range []string{"4d6Kh3+1d20X20", "1d6Ro1,2", "3d6Km1", "1d6+$str>4"} {
+		e, err := Native.Parse(s)
+		if err != nil {
+			t.Errorf("native %s: %v", s, err)
+			continue
+		}
+		if f, err := Native.Format(e); err != nil || f != s {
+			t.Errorf("native %s: formatted as %q, %v", s, f, err)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package roll
 
 // Dice represents a number of Die
 type Dice struct {
-	N   int
-	Die Die
+	N   int `json:"n"`
+	Die Die `json:"die"`
 }
 
 // Roll dice and return the Result
@@ -11,6 +11,11 @@ func (d Dice) Roll() Result {
 	return Roll(d.N, d.Die)
 }
 
+// RollWith rolls dice using src and returns the Result. A nil src uses the default source.
+func (d Dice) RollWith(src Source) Result {
+	return RollWith(src, d.N, d.Die)
+}
+
 // Min returns the minimum possible roll for a Dice struct
 func (d Dice) Min() int {
 	t := 0
@@ -32,3 +37,8 @@ func (d Dice) Max() int {
 
 	return t
 }
+
+// Distribution returns the probability of every possible sum of a roll of Dice
+func (d Dice) Distribution() Distribution {
+	return d.Die.Distribution().Repeat(d.N)
+}
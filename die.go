@@ -13,8 +13,8 @@ func init() {
 // Face represents a single face of a die and can have both a number and textual
 // value for custom dice
 type Face struct {
-	N     int
-	Value string
+	N     int    `json:"n"`
+	Value string `json:"value"`
 }
 
 // Faces is a set of Face structs that satisfies the sort interface
@@ -24,40 +24,141 @@ func (f Faces) Len() int           { return len(f) }
 func (f Faces) Less(i, j int) bool { return f[i].N < f[j].N }
 func (f Faces) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
 
-func (f Faces) contains(m int) bool {
-	for _, n := range f {
-		if n.N == m {
-			return true
+// NewDie returns a unique Die useful for custom dice systems like FFG/Genesys. The faces are
+// copied so later changes to faces don't affect the Die.
+func NewDie(faces Faces) Die {
+	return newDie(faces, nil)
+}
+
+// newDie builds a Die from faces and, for weighted dice, their weights
+func newDie(faces Faces, weights []float64) Die {
+	d := Die{faces: make(Faces, len(faces)), index: make(map[int]int)}
+
+	// Sort the faces, and their weights with them
+	order := make([]int, len(faces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return faces[order[i]].N < faces[order[j]].N })
+	for i, o := range order {
+		d.faces[i] = faces[o]
+	}
+
+	if weights != nil {
+		d.weights = make([]float64, len(weights))
+		for i, o := range order {
+			d.weights[i] = weights[o]
 		}
+		d.alias = newAliasTable(d.weights)
 	}
 
-	return false
-}
+	total, mass, first := 0., 0., true
+	for i, f := range d.faces {
+		w := d.weight(i)
+		if w <= 0 {
+			continue
+		}
 
-// NewDie returns a unique Die useful for custom dice systems like FFG/Genesys
-func NewDie(faces Faces) Die {
-	return Die{faces: faces}
+		d.index[f.N]++
+		total += float64(f.N) * w
+		mass += w
+
+		if first {
+			d.min, first = f, false
+		}
+		d.max = f
+	}
+
+	if mass > 0 {
+		d.mean = total / mass
+	}
+
+	return d
 }
 
 // Die represents a single rollable die. Die mthods always return a Face that has both
-// a numerical value and symbol represented as a string.
+// a numerical value and symbol represented as a string. A Die is immutable once created by
+// NewDie so the same Die, including the package level dice such as D6, can be shared between
+// goroutines.
 type Die struct {
-	faces Faces
+	faces    Faces       // sorted by N, never modified after NewDie
+	min, max Face        // lowest and highest faces
+	mean     float64     // average value of a roll
+	index    map[int]int // number of faces showing each N
+	weights  []float64   // relative weight of each face, nil for a fair die
+	alias    *aliasTable // samples weighted faces
 }
 
 // Roll returns a random face of d Die
 func (d Die) Roll() Face {
-	return d.faces[rand.Intn(len(d.faces))]
+	return d.RollWith(nil)
+}
+
+// RollWith returns a random face of d Die using src. A nil src uses the default source.
+func (d Die) RollWith(src Source) Face {
+	if ds, ok := src.(DieSource); ok {
+		return ds.RollDie(d)
+	}
+	if d.alias != nil {
+		return d.faces[d.alias.sample(src)]
+	}
+
+	return d.faces[intn(src, len(d.faces))]
+}
+
+// weight returns the relative weight of face i
+func (d Die) weight(i int) float64 {
+	if d.weights == nil {
+		return 1
+	}
+
+	return d.weights[i]
+}
+
+// Distribution returns the probability of rolling each number on Die
+func (d Die) Distribution() Distribution {
+	out, total := make(Distribution), 0.
+
+	for i, f := range d.faces {
+		out[f.N] += d.weight(i)
+		total += d.weight(i)
+	}
+
+	for n := range out {
+		out[n] /= total
+	}
+
+	return out
 }
 
 // Min returns the lowest value face of Die
 func (d Die) Min() Face {
-	sort.Sort(d.faces)
-	return d.faces[0]
+	return d.min
 }
 
 // Max returns the highest value face of Die
 func (d Die) Max() Face {
-	sort.Sort(d.faces)
-	return d.faces[len(d.faces)-1]
+	return d.max
+}
+
+// Mean returns the average value of a roll of Die
+func (d Die) Mean() float64 {
+	return d.mean
+}
+
+// Has reports whether any face of Die that can be rolled shows n
+func (d Die) Has(n int) bool {
+	return d.index[n] > 0
+}
+
+// Faces returns a copy of the faces of Die, sorted by N
+func (d Die) Faces() Faces {
+	out := make(Faces, len(d.faces))
+	copy(out, d.faces)
+	return out
+}
+
+// Sides returns the number of faces on Die
+func (d Die) Sides() int {
+	return len(d.faces)
 }
@@ -0,0 +1,65 @@
+package roll
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSharedDiceRace rolls and inspects the package level dice from many goroutines at once.
+// Run it with go test -race.
+func TestSharedDiceRace(t *testing.T) {
+	r := NewTableRegistry()
+	tables := []Table{
+		{ID: "parent", Dice: Dice{N: 2, Die: D6}, Reroll: TableReroll{Match: TableMatchSet{12}, Dice: Dice{N: 2, Die: D6}}, Items: []TableItem{
+			{Match: MatchRange(2, 6), Text: "low", Subtable: "child"},
+			{Match: MatchRange(7, 12), Text: "high"},
+		}},
+		{ID: "child", Dice: Dice{N: 4, Die: Fate}, Mod: 4, Items: []TableItem{
+			{Match: MatchRange(0, 8), Text: "fate"},
+		}},
+	}
+	for _, tbl := range tables {
+		if err := r.Add(tbl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dice := []Die{D6, Fate, D20, D66}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				for _, d := range dice {
+					f := d.Roll()
+					if f.N < d.Min().N || f.N > d.Max().N {
+						t.Errorf("rolled %d outside %d-%d", f.N, d.Min().N, d.Max().N)
+						return
+					}
+					d.Mean()
+				}
+
+				set := Set{{N: 3, Die: D6}, {N: 4, Die: Fate}}
+				if s := set.Roll().Sum(); s < set.Min() || s > set.Max() {
+					t.Errorf("set rolled %d outside %d-%d", s, set.Min(), set.Max())
+					return
+				}
+				if s := (Dice{N: 4, Die: D6}).Roll().Keep(3, HIGH).Sum(); s < 3 || s > 18 {
+					t.Errorf("4d6Kh3 rolled %d", s)
+					return
+				}
+
+				if out, err := r.Roll("parent", nil); err != nil || out == "" {
+					t.Errorf("table rolled %q, %v", out, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if D6.Min().N != 1 || D6.Max().N != 6 || Fate.Min().N != -1 || Fate.Max().N != 1 {
+		t.Errorf("shared dice changed: D6 %d-%d, Fate %d-%d", D6.Min().N, D6.Max().N, Fate.Min().N, Fate.Max().N)
+	}
+}
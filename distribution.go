@@ -0,0 +1,207 @@
+package roll
+
+import (
+	"math"
+	"sort"
+)
+
+// Distribution is the probability of each possible value of a total. Probabilities of a
+// complete distribution sum to 1.
+type Distribution map[int]float64
+
+// Point returns the distribution of a constant
+func Point(n int) Distribution {
+	return Distribution{n: 1}
+}
+
+// Values returns the values with a non-zero probability in ascending order
+func (d Distribution) Values() []int {
+	var out []int
+
+	for v, p := range d {
+		if p > 0 {
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+
+	return out
+}
+
+// P returns the probability of v
+func (d Distribution) P(v int) float64 {
+	return d[v]
+}
+
+// PFunc returns the probability of any value for which fn returns true
+func (d Distribution) PFunc(fn func(v int) bool) float64 {
+	t := 0.
+
+	for v, p := range d {
+		if fn(v) {
+			t += p
+		}
+	}
+
+	return t
+}
+
+// AtLeast returns the probability of a value of n or more
+func (d Distribution) AtLeast(n int) float64 {
+	return d.PFunc(func(v int) bool { return v >= n })
+}
+
+// AtMost returns the probability of a value of n or less
+func (d Distribution) AtMost(n int) float64 {
+	return d.PFunc(func(v int) bool { return v <= n })
+}
+
+// Min returns the lowest possible value
+func (d Distribution) Min() int {
+	v := d.Values()
+	if len(v) == 0 {
+		return 0
+	}
+
+	return v[0]
+}
+
+// Max returns the highest possible value
+func (d Distribution) Max() int {
+	v := d.Values()
+	if len(v) == 0 {
+		return 0
+	}
+
+	return v[len(v)-1]
+}
+
+// Mean returns the expected value
+func (d Distribution) Mean() float64 {
+	t := 0.
+
+	for v, p := range d {
+		t += float64(v) * p
+	}
+
+	return t
+}
+
+// Variance returns the variance of the values
+func (d Distribution) Variance() float64 {
+	var (
+		m = d.Mean()
+		t = 0.
+	)
+
+	for v, p := range d {
+		t += (float64(v) - m) * (float64(v) - m) * p
+	}
+
+	return t
+}
+
+// StdDev returns the standard deviation of the values
+func (d Distribution) StdDev() float64 {
+	return math.Sqrt(d.Variance())
+}
+
+// Percentile returns the lowest value v for which P(total <= v) is at least q, where q is
+// between 0 and 1. Percentile(0.5) is the median.
+func (d Distribution) Percentile(q float64) int {
+	values, c := d.Values(), 0.
+
+	for _, v := range values {
+		c += d[v]
+		// Allow for rounding error in the cumulative probability
+		if c >= q-1e-12 {
+			return v
+		}
+	}
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	return values[len(values)-1]
+}
+
+// Total returns the sum of the probabilities, which is 1 for a complete distribution
+func (d Distribution) Total() float64 {
+	t := 0.
+
+	for _, p := range d {
+		t += p
+	}
+
+	return t
+}
+
+// Normalize returns a copy of the distribution scaled so that its probabilities sum to 1
+func (d Distribution) Normalize() Distribution {
+	t, out := d.Total(), make(Distribution, len(d))
+	if t == 0 {
+		return out
+	}
+
+	for v, p := range d {
+		out[v] = p / t
+	}
+
+	return out
+}
+
+// Map returns the distribution of fn applied to the values of d
+func (d Distribution) Map(fn func(v int) int) Distribution {
+	out := make(Distribution, len(d))
+
+	for v, p := range d {
+		out[fn(v)] += p
+	}
+
+	return out
+}
+
+// Combine returns the distribution of fn(a, b) where a and b are independent values drawn from d
+// and o
+func (d Distribution) Combine(o Distribution, fn func(a, b int) int) Distribution {
+	out := make(Distribution)
+
+	for a, pa := range d {
+		for b, pb := range o {
+			out[fn(a, b)] += pa * pb
+		}
+	}
+
+	return out
+}
+
+// Add returns the distribution of the sum of independent values drawn from d and o
+func (d Distribution) Add(o Distribution) Distribution {
+	return d.Combine(o, func(a, b int) int { return a + b })
+}
+
+// Repeat returns the distribution of the sum of n independent values drawn from d
+func (d Distribution) Repeat(n int) Distribution {
+	out := Point(0)
+
+	// Square and multiply keeps the number of convolutions logarithmic in n
+	for sq := d; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			out = out.Add(sq)
+		}
+		if n > 1 {
+			sq = sq.Add(sq)
+		}
+	}
+
+	return out
+}
+
+// mix adds o, scaled by p, to d. It's used to build mixtures such as the distribution of a
+// ternary.
+func (d Distribution) mix(o Distribution, p float64) {
+	for v, q := range o {
+		d[v] += q * p
+	}
+}
@@ -0,0 +1,253 @@
+package roll
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// EncodingVersion is the version of the JSON schema written for Die, Result, Table and
+// TableRegistry. Decoding accepts any version up to EncodingVersion, and data without a version
+// is read as version 1. Face, Dice, Set and Results have no version of their own and are
+// encoded as plain objects and arrays of the versioned types.
+//
+// Every type round trips through JSON and gob: decoding what was encoded gives a value that
+// rolls, prints and compares identically, with two exceptions. A Result's Source isn't encoded,
+// so explosions and rerolls of a decoded Result use the default source, and the Action and
+// ActionWith funcs of a TableItem can't be encoded. Give such items a Subtable instead, which is
+// encoded and rebound when the table is added to a TableRegistry; encoding an item with an
+// action and no Subtable is an error rather than silently losing it.
+const EncodingVersion = 1
+
+// checkVersion returns an error for data written by a newer version of the schema
+func checkVersion(kind string, v int) error {
+	if v > EncodingVersion {
+		return fmt.Errorf("%s: unsupported encoding version %d, expected %d or lower", kind, v, EncodingVersion)
+	}
+
+	return nil
+}
+
+type dieJSON struct {
+	Version int       `json:"version"`
+	Faces   Faces     `json:"faces"`
+	Weights []float64 `json:"weights,omitempty"`
+}
+
+// MarshalJSON encodes d as its faces, sorted by N, and the weights of a weighted Die
+func (d Die) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dieJSON{Version: EncodingVersion, Faces: d.faces, Weights: d.weights})
+}
+
+// UnmarshalJSON decodes a Die encoded by MarshalJSON
+func (d *Die) UnmarshalJSON(data []byte) error {
+	var v dieJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkVersion("die", v.Version); err != nil {
+		return err
+	}
+
+	switch {
+	case len(v.Faces) == 0:
+		*d = Die{}
+	case v.Weights == nil:
+		*d = NewDie(v.Faces)
+	default:
+		w, err := NewWeightedDie(v.Faces, v.Weights)
+		if err != nil {
+			return err
+		}
+		*d = w
+	}
+
+	return nil
+}
+
+// GobEncode encodes d for encoding/gob using the JSON schema
+func (d Die) GobEncode() ([]byte, error) { return d.MarshalJSON() }
+
+// GobDecode decodes a Die encoded by GobEncode
+func (d *Die) GobDecode(data []byte) error { return d.UnmarshalJSON(data) }
+
+type resultJSON struct {
+	Version int   `json:"version"`
+	Die     Die   `json:"die"`
+	Rolls   Faces `json:"rolls"`
+	Dropped Faces `json:"dropped,omitempty"`
+	Order   []int `json:"order,omitempty"`
+}
+
+// MarshalJSON encodes r as its Die, the faces kept and dropped and, if it's known, the position
+// in roll order of each face kept and then dropped
+func (r Result) MarshalJSON() ([]byte, error) {
+	v := resultJSON{Version: EncodingVersion, Die: r.die, Rolls: r.rolls, Dropped: r.dropped}
+	if len(r.rollPos) == len(r.rolls) && len(r.dropPos) == len(r.dropped) {
+		v.Order = append(append([]int(nil), r.rollPos...), r.dropPos...)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a Result encoded by MarshalJSON. Every face must be on the Die.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var v resultJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkVersion("result", v.Version); err != nil {
+		return err
+	}
+
+	for _, f := range append(joinFaces(v.Rolls, nil), v.Dropped...) {
+		if !v.Die.Has(f.N) {
+			return fmt.Errorf("result: %d is not a face of the die", f.N)
+		}
+	}
+
+	out := Result{die: v.Die, rolls: v.Rolls, dropped: v.Dropped}
+	if v.Order != nil {
+		errOrder := fmt.Errorf("result: order must give the position of every face once")
+		if len(v.Order) != len(v.Rolls)+len(v.Dropped) {
+			return errOrder
+		}
+		seen := make([]bool, len(v.Order))
+		for _, p := range v.Order {
+			if p < 0 || p >= len(seen) || seen[p] {
+				return errOrder
+			}
+			seen[p] = true
+		}
+
+		if n := len(v.Rolls); n > 0 {
+			out.rollPos = v.Order[:n:n]
+		}
+		if len(v.Dropped) > 0 {
+			out.dropPos = v.Order[len(v.Rolls):]
+		}
+	}
+
+	*r = out
+	return nil
+}
+
+// GobEncode encodes r for encoding/gob using the JSON schema
+func (r Result) GobEncode() ([]byte, error) { return r.MarshalJSON() }
+
+// GobDecode decodes a Result encoded by GobEncode
+func (r *Result) GobDecode(data []byte) error { return r.UnmarshalJSON(data) }
+
+type tableJSON struct {
+	Version int             `json:"version"`
+	ID      string          `json:"id"`
+	Name    string          `json:"name,omitempty"`
+	Dice    Dice            `json:"dice"`
+	Mod     int             `json:"mod,omitempty"`
+	Reroll  *tableReroll    `json:"reroll,omitempty"`
+	Items   []tableItemJSON `json:"items"`
+}
+
+type tableReroll struct {
+	Match TableMatchSet `json:"match"`
+	Dice  Dice          `json:"dice"`
+}
+
+type tableItemJSON struct {
+	Match    TableMatchSet `json:"match"`
+	Text     string        `json:"text"`
+	Subtable string        `json:"subtable,omitempty"`
+}
+
+// MarshalJSON encodes t. Items with an Action or ActionWith must also have a Subtable, which is
+// encoded in their place.
+func (t Table) MarshalJSON() ([]byte, error) {
+	v := tableJSON{Version: EncodingVersion, ID: t.ID, Name: t.Name, Dice: t.Dice, Mod: t.Mod}
+
+	if t.Reroll.Match != nil || t.Reroll.Dice.N != 0 {
+		v.Reroll = &tableReroll{Match: t.Reroll.Match, Dice: t.Reroll.Dice}
+	}
+
+	for _, item := range t.Items {
+		if item.Subtable == "" && (item.Action != nil || item.ActionWith != nil) {
+			return nil, fmt.Errorf("table %s: item %q has an action that cannot be encoded, give it a Subtable", t.ID, item.Text)
+		}
+		v.Items = append(v.Items, tableItemJSON{Match: item.Match, Text: item.Text, Subtable: item.Subtable})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a Table encoded by MarshalJSON. Items with a Subtable have no action
+// until the table is added to a TableRegistry.
+func (t *Table) UnmarshalJSON(data []byte) error {
+	var v tableJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkVersion("table", v.Version); err != nil {
+		return err
+	}
+
+	*t = Table{ID: v.ID, Name: v.Name, Dice: v.Dice, Mod: v.Mod}
+	if v.Reroll != nil {
+		t.Reroll = TableReroll{Match: v.Reroll.Match, Dice: v.Reroll.Dice}
+	}
+	for _, item := range v.Items {
+		t.Items = append(t.Items, TableItem{Match: item.Match, Text: item.Text, Subtable: item.Subtable})
+	}
+
+	return nil
+}
+
+// GobEncode encodes t for encoding/gob using the JSON schema
+func (t Table) GobEncode() ([]byte, error) { return t.MarshalJSON() }
+
+// GobDecode decodes a Table encoded by GobEncode
+func (t *Table) GobDecode(data []byte) error { return t.UnmarshalJSON(data) }
+
+type registryJSON struct {
+	Version int     `json:"version"`
+	Tables  []Table `json:"tables"`
+}
+
+// MarshalJSON encodes the tables of r, sorted by ID
+func (r TableRegistry) MarshalJSON() ([]byte, error) {
+	v := registryJSON{Version: EncodingVersion, Tables: []Table{}}
+
+	for _, t := range r {
+		v.Tables = append(v.Tables, t)
+	}
+	sort.Slice(v.Tables, func(i, j int) bool { return v.Tables[i].ID < v.Tables[j].ID })
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a TableRegistry encoded by MarshalJSON, adding its tables to r so that
+// their Subtables are bound. Any tables already in r are kept.
+func (r *TableRegistry) UnmarshalJSON(data []byte) error {
+	var v registryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkVersion("table registry", v.Version); err != nil {
+		return err
+	}
+
+	if *r == nil {
+		*r = NewTableRegistry()
+	}
+	for _, t := range v.Tables {
+		if err := r.Add(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GobEncode encodes r for encoding/gob using the JSON schema
+func (r TableRegistry) GobEncode() ([]byte, error) { return r.MarshalJSON() }
+
+// GobDecode decodes a TableRegistry encoded by GobEncode
+func (r *TableRegistry) GobDecode(data []byte) error { return r.UnmarshalJSON(data) }
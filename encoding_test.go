@@ -0,0 +1,188 @@
+package roll
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTrip encodes v as JSON and gob and decodes each into a new value of the same type
+func roundTrip(t *testing.T, name string, v interface{}) (fromJSON, fromGob interface{}) {
+	t.Helper()
+
+	typ := reflect.TypeOf(v)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: json: %v", name, err)
+	}
+	j := reflect.New(typ)
+	if err := json.Unmarshal(data, j.Interface()); err != nil {
+		t.Fatalf("%s: json: %v in %s", name, err, data)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("%s: gob: %v", name, err)
+	}
+	g := reflect.New(typ)
+	if err := gob.NewDecoder(&buf).Decode(g.Interface()); err != nil {
+		t.Fatalf("%s: gob: %v", name, err)
+	}
+
+	return j.Elem().Interface(), g.Elem().Interface()
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	weighted, err := NewWeightedDie(Faces{{N: 3, Value: "three"}, {N: 1, Value: "one"}, {N: 2, Value: "two"}}, []float64{1, 0, 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		src     = rand.New(rand.NewSource(1))
+		result  = RollWith(src, 6, D6).Keep(4, HIGH).Explode(6)
+		results = Results{RollWith(src, 2, Fate), RollWith(src, 1, weighted)}
+	)
+	result.src, results[0].src, results[1].src = nil, nil, nil
+
+	for name, v := range map[string]interface{}{
+		"face":          Face{N: 11, Value: "11"},
+		"faces":         Faces{{N: -1, Value: "[-]"}, {N: 1, Value: "[+]"}},
+		"die":           D20,
+		"fate":          Fate,
+		"d66":           D66,
+		"weighted die":  weighted,
+		"dice":          Dice{N: 3, Die: D8},
+		"set":           Set{{N: 2, Die: D6}, {N: 1, Die: weighted}, {N: 4, Die: Fate}},
+		"result":        result,
+		"results":       results,
+		"empty result":  Result{},
+		"table":         Table{ID: "t", Name: "Table", Dice: Dice{N: 2, Die: D6}, Mod: -1, Reroll: TableReroll{Match: TableMatchSet{12}, Dice: Dice{N: 1, Die: D6}}, Items: []TableItem{{Match: MatchRange(1, 6), Text: "low"}, {Match: MatchRange(7, 12), Text: "high", Subtable: "other"}}},
+		"minimal table": Table{ID: "m", Dice: Dice{N: 1, Die: D4}, Items: []TableItem{{Match: TableMatchSet{1, 2, 3, 4}, Text: "any"}}},
+	} {
+		j, g := roundTrip(t, name, v)
+		if !reflect.DeepEqual(j, v) {
+			t.Errorf("%s: json round trip gave %#v, want %#v", name, j, v)
+		}
+		if !reflect.DeepEqual(g, v) {
+			t.Errorf("%s: gob round trip gave %#v, want %#v", name, g, v)
+		}
+	}
+}
+
+func TestEncodingWeightedDieRolls(t *testing.T) {
+	d, err := NewWeightedDie(Faces{{N: 1, Value: "1"}, {N: 2, Value: "2"}, {N: 3, Value: "3"}}, []float64{5, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, g := roundTrip(t, "weighted die", d)
+	for _, decoded := range []Die{j.(Die), g.(Die)} {
+		a, b := seqSource{1, 500000000, 3, 900000000, 2, 100}, seqSource{1, 500000000, 3, 900000000, 2, 100}
+		for i := 0; i < 3; i++ {
+			if x, y := d.RollWith(&a), decoded.RollWith(&b); x != y {
+				t.Errorf("decoded die rolled %v, original %v", y, x)
+			}
+		}
+	}
+}
+
+func TestEncodingTableRegistry(t *testing.T) {
+	r := NewTableRegistry()
+	for _, tbl := range []Table{
+		{ID: "parent", Name: "Parent", Dice: Dice{N: 1, Die: D4}, Items: []TableItem{
+			{Match: TableMatchSet{1, 2}, Text: "plain"},
+			{Match: TableMatchSet{3, 4}, Text: "nested", Subtable: "child"},
+		}},
+		{ID: "child", Name: "Child", Dice: Dice{N: 1, Die: D6}, Items: []TableItem{
+			{Match: MatchRange(1, 6), Text: "from the child"},
+		}},
+	} {
+		if err := r.Add(tbl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, g := roundTrip(t, "registry", r)
+	for name, decoded := range map[string]TableRegistry{"json": j.(TableRegistry), "gob": g.(TableRegistry)} {
+		got, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: registry encoded as %s, want %s", name, got, want)
+		}
+
+		// The Subtable is rebound, so rolling a 3 on the parent rolls on the child
+		src := seqSource{3, 1}
+		if out, err := decoded.Roll("parent", &src); err != nil || out != "nested; from the child" {
+			t.Errorf("%s: decoded parent rolled %q, %v", name, out, err)
+		}
+	}
+
+	// Items with an action and no Subtable can't be encoded
+	bad := Table{ID: "bad", Dice: Dice{N: 1, Die: D4}, Items: []TableItem{{Match: TableMatchSet{1}, Text: "x", Action: func() string { return "" }}}}
+	if _, err := json.Marshal(bad); err == nil {
+		t.Errorf("table with an action encoded without a Subtable")
+	}
+}
+
+func TestEncodingVersions(t *testing.T) {
+	for name, c := range map[string]struct {
+		data string
+		v    interface{}
+	}{
+		"die":      {`{"version": 2, "faces": [{"n": 1, "value": "1"}]}`, &Die{}},
+		"result":   {`{"version": 2, "die": {"faces": [{"n": 1, "value": "1"}]}, "rolls": []}`, &Result{}},
+		"table":    {`{"version": 2, "id": "t", "dice": {"n": 1, "die": {"faces": [{"n": 1, "value": "1"}]}}, "items": []}`, &Table{}},
+		"registry": {`{"version": 2, "tables": []}`, &TableRegistry{}},
+	} {
+		err := json.Unmarshal([]byte(c.data), c.v)
+		if err == nil || !strings.Contains(err.Error(), "unsupported encoding version 2") {
+			t.Errorf("%s: decoding version 2 gave %v", name, err)
+		}
+
+		// Data without a version is read as version 1
+		if err := json.Unmarshal([]byte(strings.Replace(c.data, `"version": 2, `, "", 1)), c.v); err != nil {
+			t.Errorf("%s: decoding without a version gave %v", name, err)
+		}
+	}
+
+	var r Result
+	if err := json.Unmarshal([]byte(`{"die": {"faces": [{"n": 1, "value": "1"}]}, "rolls": [{"n": 7, "value": "7"}]}`), &r); err == nil {
+		t.Errorf("decoded a result with a face that isn't on its die")
+	}
+}
+
+func TestEncodingResultOrder(t *testing.T) {
+	r := rollSeq(4, D6, 3, 6, 1, 5).Keep(2, HIGH)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Result
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Rolled(), r.Rolled()) {
+		t.Errorf("decoded %v in roll order, want %v", got.Rolled(), r.Rolled())
+	}
+
+	for _, order := range []string{`[0, 1]`, `[0, 1, 2, 2]`, `[0, 1, 2, 4]`, `[-1, 0, 1, 2]`} {
+		data := `{"die": {"faces": [{"n": 1, "value": "1"}]}, "rolls": [{"n": 1, "value": "1"}, {"n": 1, "value": "1"}], "dropped": [{"n": 1, "value": "1"}, {"n": 1, "value": "1"}], "order": ` + order + `}`
+		if err := json.Unmarshal([]byte(data), &got); err == nil {
+			t.Errorf("decoded a result with order %s", order)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package roll
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInexact is returned by Distribution for expressions whose distribution can't be computed
+// exactly in reasonable time, such as a modifier that follows an explosion or a keep of a very
+// large pool. Use the sim package to estimate them instead.
+var ErrInexact = errors.New("distribution cannot be computed exactly")
+
+const (
+	// maxMultisets is the largest number of distinct pools enumerated for a single dice term
+	maxMultisets = 1000000
+	// explodeEpsilon is the probability below which further explosions are ignored
+	explodeEpsilon = 1e-15
+	// maxExplodeDepth stops dice that always explode, such as d1X1 built from variables
+	maxExplodeDepth = 1000
+)
+
+// Distribution computes the exact probability of every Total of the expression with variables
+// bound from vars, as Eval would roll it. When the expression is a comparison the distribution
+// is of its left hand side, like Total. A *MissingVariableError is returned if a variable isn't
+// bound, a *LimitError if its Cost exceeds its Limits and ErrInexact if the expression is beyond
+// the exact engine, see ErrInexact.
+func (e *Expr) Distribution(vars Vars) (Distribution, error) {
+	for _, name := range e.Vars() {
+		if _, ok := vars[name]; !ok {
+			return nil, &MissingVariableError{Name: name}
+		}
+	}
+
+	if err := e.limits.Check(e.Cost(vars)); err != nil {
+		return nil, err
+	}
+
+	root := e.root
+	if c, ok := unwrap(root).(*cmpNode); ok {
+		root = c.l
+	}
+
+	return distribution(root, vars)
+}
+
+// Die returns a weighted Die with a face for every Total of the expression, see Distribution.
+// Rolling the Die takes constant time however many dice the expression rolls.
+func (e *Expr) Die(vars Vars) (Die, error) {
+	d, err := e.Distribution(vars)
+	if err != nil {
+		return Die{}, err
+	}
+
+	return DistributionDie(d)
+}
+
+// distribution returns the distribution of n. Every dice term is rolled independently so the
+// distributions of operands can be combined directly.
+func distribution(n node, vars Vars) (Distribution, error) {
+	switch n := n.(type) {
+	case numNode:
+		return Point(int(n)), nil
+
+	case varNode:
+		return Point(vars[string(n)]), nil
+
+	case *groupNode:
+		return distribution(n.x, vars)
+
+	case *negNode:
+		x, err := distribution(n.x, vars)
+		if err != nil {
+			return nil, err
+		}
+		return x.Map(func(v int) int { return -v }), nil
+
+	case *binaryNode:
+		l, r, err := distribution2(n.l, n.r, vars)
+		if err != nil {
+			return nil, err
+		}
+		return l.Combine(r, func(a, b int) int { return arith(n.op, a, b) }), nil
+
+	case *cmpNode:
+		l, r, err := distribution2(n.l, n.r, vars)
+		if err != nil {
+			return nil, err
+		}
+		return l.Combine(r, func(a, b int) int {
+			if compare(n.op, a, b) {
+				return 1
+			}
+			return 0
+		}), nil
+
+	case *condNode:
+		c, err := distribution(n.cond, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		out, p := make(Distribution), c.PFunc(func(v int) bool { return v != 0 })
+		for _, branch := range []struct {
+			x node
+			p float64
+		}{{n.t, p}, {n.f, 1 - p}} {
+			if branch.p <= 0 {
+				continue
+			}
+
+			x, err := distribution(branch.x, vars)
+			if err != nil {
+				return nil, err
+			}
+			out.mix(x, branch.p)
+		}
+		return out, nil
+
+	case *diceNode:
+		return n.distribution(vars)
+	}
+
+	return nil, ErrInexact
+}
+
+func distribution2(l, r node, vars Vars) (Distribution, Distribution, error) {
+	a, err := distribution(l, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := distribution(r, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, b, nil
+}
+
+// distribution of the dice term, mixing over the distributions of its count and sides
+func (d *diceNode) distribution(vars Vars) (Distribution, error) {
+	counts, err := distribution(d.count, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	sides := Point(0)
+	if d.die.faces == nil {
+		if sides, err = distribution(d.sides, vars); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make(Distribution)
+	for s, ps := range sides {
+		die := d.die
+		if die.faces == nil {
+			die = NewDie(makeFaces(s))
+		}
+
+		for n, pn := range counts {
+			if n < 1 || d.die.faces == nil && s < 2 {
+				return nil, &InvalidDieError{Term: d.String(), Count: n, Sides: s}
+			}
+
+			t, err := termDistribution(n, die, d.mods)
+			if err != nil {
+				return nil, err
+			}
+			out.mix(t, ps*pn)
+		}
+	}
+
+	return out, nil
+}
+
+// termDistribution returns the distribution of the sum of n dice with mods applied
+func termDistribution(n int, die Die, mods []modifier) (Distribution, error) {
+	if n < 0 {
+		n = 0
+	}
+
+	// The dice are independent so keeping or dropping the first or last of them is the same as
+	// rolling fewer, and rerolling changes the faces of each die on its own, as long as nothing
+	// has been removed from the pool first
+	var (
+		raw   = die.Distribution()
+		faces = raw
+	)
+leading:
+	for len(mods) > 0 {
+		m := mods[0]
+		switch {
+		case (m.kind == modKeep || m.kind == modDrop) && (m.hl == FIRST || m.hl == LAST):
+			if m.n >= 1 && m.n <= n {
+				if m.kind == modKeep {
+					n = m.n
+				} else {
+					n -= m.n
+				}
+			}
+		case m.kind == modReroll || m.kind == modRerollOnce:
+			faces = rerollDistribution(faces, raw, m.match, m.kind == modRerollOnce)
+		default:
+			break leading
+		}
+		mods = mods[1:]
+	}
+
+	// A single explosion is allowed as the last modifier
+	var explode []int
+	if l := len(mods); l > 0 && mods[l-1].kind == modExplode {
+		explode, mods = mods[l-1].match, mods[:l-1]
+	}
+
+	perDie := true
+	for _, m := range mods {
+		switch {
+		case m.kind == modExplode || m.kind == modReroll || m.kind == modRerollOnce:
+			return nil, ErrInexact
+		case m.kind == modKeep || m.kind == modDrop:
+			if m.hl == FIRST || m.hl == LAST {
+				return nil, ErrInexact
+			}
+			perDie = false
+		}
+	}
+
+	// Explosions add dice that aren't rerolled
+	chain := Point(0)
+	if len(explode) > 0 {
+		var err error
+		if chain, err = explodeDistribution(raw, explode); err != nil {
+			return nil, err
+		}
+	}
+
+	// With only KeepN and DropN every die is kept or dropped on its own value
+	if perDie {
+		one := make(Distribution)
+		for v, p := range faces {
+			vals := applyValues([]int{v}, mods)
+			if len(vals) == 0 {
+				one[0] += p
+				continue
+			}
+
+			if matches(v, explode) {
+				one.mix(chain.Map(func(c int) int { return v + c }), p)
+			} else {
+				one[v] += p
+			}
+		}
+
+		return one.Repeat(n), nil
+	}
+
+	return poolDistribution(n, faces, mods, explode, chain)
+}
+
+// poolDistribution enumerates every multiset of n faces, weighted by its multinomial
+// probability, and applies mods to each
+func poolDistribution(n int, faces Distribution, mods []modifier, explode []int, chain Distribution) (Distribution, error) {
+	values := faces.Values()
+	if multisets(n, len(values)) > maxMultisets {
+		return nil, ErrInexact
+	}
+
+	var (
+		out    = make(Distribution)
+		sums   = make(map[int]Distribution) // distribution of the kept sum by number of dice exploding
+		pool   = make([]int, 0, n)
+		lgN, _ = math.Lgamma(float64(n + 1))
+		visit  func(i, left int, logP float64)
+	)
+
+	// visit chooses how many of values[i] are in the pool, keeping pool sorted ascending
+	visit = func(i, left int, logP float64) {
+		if i == len(values)-1 {
+			lg, _ := math.Lgamma(float64(left + 1))
+			logP += float64(left)*math.Log(faces[values[i]]) - lg
+			for j := 0; j < left; j++ {
+				pool = append(pool, values[i])
+			}
+
+			var (
+				p      = math.Exp(lgN + logP)
+				kept   = applyValues(pool, mods)
+				sum    = 0
+				chains = 0
+			)
+			for _, v := range kept {
+				sum += v
+				if matches(v, explode) {
+					chains++
+				}
+			}
+
+			if sums[chains] == nil {
+				sums[chains] = make(Distribution)
+			}
+			sums[chains][sum] += p
+
+			pool = pool[:len(pool)-left]
+			return
+		}
+
+		lp := math.Log(faces[values[i]])
+		for c := 0; c <= left; c++ {
+			lg, _ := math.Lgamma(float64(c + 1))
+			for j := 0; j < c; j++ {
+				pool = append(pool, values[i])
+			}
+			visit(i+1, left-c, logP+float64(c)*lp-lg)
+			pool = pool[:len(pool)-c]
+		}
+	}
+
+	if len(values) == 0 || n == 0 {
+		return Point(0), nil
+	}
+	visit(0, n, 0)
+
+	// Add the explosions once for each number of exploding dice rather than once per pool
+	for chains, d := range sums {
+		out.mix(d.Add(chain.Repeat(chains)), 1)
+	}
+
+	return out, nil
+}
+
+// multisets returns the number of multisets of size n drawn from k values, saturating at
+// maxMultisets+1
+func multisets(n, k int) float64 {
+	if k == 0 {
+		return 0
+	}
+
+	// C(n+k-1, k-1), built up one factor at a time
+	c := 1.
+	for i := 1; i < k; i++ {
+		c = c * float64(n+i) / float64(i)
+		if c > maxMultisets {
+			return maxMultisets + 1
+		}
+	}
+
+	return c
+}
+
+// applyValues applies the Keep, Drop, KeepN and DropN mods to the sorted values of a pool and
+// returns the values left
+func applyValues(values []int, mods []modifier) []int {
+	out := values
+	for _, m := range mods {
+		var next []int
+
+		switch m.kind {
+		case modKeep, modDrop:
+			if m.n < 1 || m.n > len(out) {
+				continue
+			}
+			for i, sel := range pick(out, m.n, m.hl) {
+				if sel == (m.kind == modKeep) {
+					next = append(next, out[i])
+				}
+			}
+
+		case modKeepN, modDropN:
+			for _, v := range out {
+				if matches(v, m.match) == (m.kind == modKeepN) {
+					next = append(next, v)
+				}
+			}
+		}
+
+		out = next
+	}
+
+	return out
+}
+
+// rerollDistribution returns the distribution of a die with faces that is rerolled once, or
+// until it isn't, when it rolls a number in match. Rerolls are drawn from raw, the distribution
+// of the die before any modifier, as they are when the dice are rolled, which differs from faces
+// when an earlier reroll has changed it.
+func rerollDistribution(faces, raw Distribution, match []int, once bool) Distribution {
+	var (
+		p = faces.PFunc(func(v int) bool { return matches(v, match) })
+		q = raw.PFunc(func(v int) bool { return matches(v, match) })
+	)
+	if p == 0 || (q >= 1 && !once) {
+		return faces
+	}
+
+	out := make(Distribution)
+	for v, pv := range faces {
+		if !matches(v, match) {
+			out[v] += pv
+		}
+	}
+	for v, pv := range raw {
+		switch {
+		case once:
+			out[v] += p * pv
+		case !matches(v, match):
+			out[v] += p * pv / (1 - q)
+		}
+	}
+
+	return out
+}
+
+// explodeDistribution returns the distribution of the dice added by a die that exploded,
+// including any further explosions. Chains less likely than explodeEpsilon are ignored.
+func explodeDistribution(faces Distribution, match []int) (Distribution, error) {
+	var (
+		out     = make(Distribution)
+		pending = Point(0) // sums so far of chains that are still exploding
+	)
+
+	for depth := 0; len(pending) > 0; depth++ {
+		if depth >= maxExplodeDepth {
+			return nil, ErrInexact
+		}
+
+		next := make(Distribution)
+		for s, ps := range pending {
+			for v, pv := range faces {
+				if matches(v, match) {
+					next[s+v] += ps * pv
+				} else {
+					out[s+v] += ps * pv
+				}
+			}
+		}
+
+		if next.Total() < explodeEpsilon {
+			break
+		}
+		pending = next
+	}
+
+	return out, nil
+}
@@ -0,0 +1,176 @@
+package roll
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// odometer is a Source that walks every sequence of draws an evaluation can make, depth first,
+// so that an expression can be enumerated by evaluating it once per sequence
+type odometer struct {
+	digits, bases []int
+	pos           int
+}
+
+func (o *odometer) Intn(n int) int {
+	if o.pos == len(o.digits) {
+		o.digits = append(o.digits, 0)
+		o.bases = append(o.bases, n)
+	}
+	o.bases[o.pos] = n
+	o.pos++
+
+	return o.digits[o.pos-1]
+}
+
+// p returns the probability of the sequence just drawn
+func (o *odometer) p() float64 {
+	p := 1.
+	for _, b := range o.bases[:o.pos] {
+		p /= float64(b)
+	}
+
+	return p
+}
+
+// next moves to the next sequence, returning false once every sequence has been drawn
+func (o *odometer) next() bool {
+	o.digits, o.bases = o.digits[:o.pos], o.bases[:o.pos]
+	o.pos = 0
+
+	for i := len(o.digits) - 1; i >= 0; i-- {
+		if o.digits[i]++; o.digits[i] < o.bases[i] {
+			o.digits, o.bases = o.digits[:i+1], o.bases[:i+1]
+			return true
+		}
+	}
+
+	return false
+}
+
+// enumerate returns the distribution of e by evaluating every sequence of draws it can make
+func enumerate(t *testing.T, e *Expr, vars Vars) Distribution {
+	var (
+		o   = &odometer{}
+		out = make(Distribution)
+	)
+	e = e.WithSource(o)
+
+	for {
+		r, err := e.Eval(vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[r.Total] += o.p()
+
+		if !o.next() {
+			return out
+		}
+	}
+}
+
+// sameDistribution reports any value whose probability differs between got and want by more
+// than tol
+func sameDistribution(t *testing.T, name string, got, want Distribution, tol func(p float64) float64) {
+	t.Helper()
+
+	for _, v := range Compare(got, want).Values {
+		if math.Abs(v.A-v.B) > tol(v.B) {
+			t.Errorf("%s: P(%d) = %.6f, want %.6f", name, v.Value, v.A, v.B)
+		}
+	}
+}
+
+func TestDistributionEnumeration(t *testing.T) {
+	for _, s := range []string{
+		"3d6",
+		"4d6Kh3",
+		"4d6Dl1",
+		"2d20Kl1+5",
+		"3d4Kh2+1d6-1d4",
+		"5d4Dh1Dl1",
+		"4d6Km2",
+		"3d6Ro1",
+		"2d6Ro1,2",
+		"1d6Ro1Ro2",
+		"2d6Ro1Ro1",
+		"3d4Ro1Ro2Kh2",
+		"(1d3)d4",
+		"2d(1d4+1)",
+		"1d6*1d4",
+		"1d20>=11?2d6:1d4",
+		"3d6Kn5,6",
+		"4d4Dn1",
+	} {
+		e, err := Parse(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+
+		d, err := e.Distribution(nil)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		sameDistribution(t, s, d, enumerate(t, e, nil), func(float64) float64 { return 1e-9 })
+	}
+}
+
+// Explosions and rerolls that repeat until a face doesn't match can draw without end, so they're
+// checked against simulation instead of enumeration
+func TestDistributionSimulation(t *testing.T) {
+	const rolls = 200000
+
+	for _, s := range []string{
+		"3d6X6",
+		"2d10Kh1X9,10",
+		"4d6R1",
+		"3d8R1,2",
+		"1d6R1R2",
+		"3d6R1R2",
+		"2d8R1,2R3Kh1",
+		"2d6R1Ro2",
+		"1d6Ro1R2",
+		"5d6Dl2X6",
+		"1d20+1d6X6",
+		"6d10Kh3X10",
+	} {
+		e := MustParse(s).WithSource(rand.New(rand.NewSource(1)))
+		d, err := e.Distribution(nil)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+
+		counts := make(Distribution)
+		if err := e.EvalN(rolls, nil, func(total int) { counts[total]++ }); err != nil {
+			t.Fatal(err)
+		}
+
+		// Allow 5 standard errors, and a little more for the rarest values
+		sameDistribution(t, s, counts.Normalize(), d, func(p float64) float64 {
+			return 5*math.Sqrt(p*(1-p)/rolls) + 1e-4
+		})
+		if diff := math.Abs(counts.Normalize().Mean() - d.Mean()); diff > 5*d.StdDev()/math.Sqrt(rolls) {
+			t.Errorf("%s: simulated mean %.4f, exact %.4f", s, counts.Normalize().Mean(), d.Mean())
+		}
+	}
+}
+
+func TestChainedRerollMean(t *testing.T) {
+	// 1d6R1 is 2 to 6, then R2 rerolls the 2s on a plain d6 until they aren't 2, which can give 1
+	for s, want := range map[string]float64{
+		"1d6R1R2":   4.36,
+		"1d6Ro1Ro2": 3.5 + 2.5/6 + 7.0/36*1.5,
+	} {
+		d, err := MustParse(s).Distribution(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(d.Mean()-want) > 1e-9 {
+			t.Errorf("%s: mean %.4f, want %.4f", s, d.Mean(), want)
+		}
+	}
+}
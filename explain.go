@@ -0,0 +1,306 @@
+package roll
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// explainRolls is the number of rolls simulated to estimate the average of an expression whose
+// distribution can't be computed exactly
+const explainRolls = 10000
+
+// Explain describes e in plain English along with the range and average of its total, i.e
+// Explain(MustParse("4d10X10Kh3Dl1")) begins "Roll four ten-sided dice, explode 10s, keep the
+// highest three, then drop the lowest one." Modifiers are described in the order they apply.
+// Expressions that reference variables are described without statistics, use ExplainVars to
+// bind them.
+func Explain(e *Expr) string {
+	return ExplainVars(e, nil)
+}
+
+// ExplainVars is Explain with variables bound from vars. Statistics are only included when every
+// variable the expression references is bound.
+func ExplainVars(e *Expr, vars Vars) string {
+	var (
+		out  []string
+		root = unwrap(e.root)
+	)
+
+	if c, ok := root.(*cmpNode); ok {
+		out = append(out, sentence(explainSteps(c.l)), sentence("succeed if the total "+cmpWords[c.op]+" "+explainNoun(c.r)))
+	} else {
+		out = append(out, sentence(explainSteps(root)))
+	}
+
+	if e.bands != nil {
+		of := "total"
+		if _, ok := root.(*cmpNode); ok {
+			of = "margin of success"
+		}
+		out = append(out, sentence(fmt.Sprintf("label the result by its %s: %s", of, explainBands(e.bands))))
+	}
+
+	for _, name := range e.Vars() {
+		if _, ok := vars[name]; !ok {
+			return strings.Join(out, " ")
+		}
+	}
+
+	if s := explainStats(e, vars); s != "" {
+		out = append(out, s)
+	}
+
+	return strings.Join(out, " ")
+}
+
+// explainStats describes the range and average of e's total and the chance of a check passing
+func explainStats(e *Expr, vars Vars) string {
+	var (
+		root   = unwrap(e.root)
+		lo, hi = bounds(root, vars, 0)
+	)
+	if c, ok := root.(*cmpNode); ok {
+		lo, hi = bounds(c.l, vars, 0)
+	}
+
+	d, err := e.Distribution(vars)
+	if err != nil {
+		// Without an exact distribution the range comes from the bounds and the average from
+		// rolling
+		var sum float64
+		if err := e.EvalN(explainRolls, vars, func(t int) { sum += float64(t) }); err != nil {
+			return ""
+		}
+		return explainRange(lo, hi, lo, hi, "about "+explainFloat(sum/explainRolls)) + "."
+	}
+
+	s := explainRange(lo, hi, d.Min(), d.Max(), explainFloat(d.Mean()))
+
+	if _, ok := root.(*cmpNode); ok {
+		if pass, err := distribution(root, vars); err == nil {
+			s += fmt.Sprintf(", and the check succeeds %s%% of the time", explainFloat(100*pass.P(1)))
+		}
+	}
+
+	return s + "."
+}
+
+// explainRange describes the range of totals between min and max, which have no minimum or
+// maximum where the bounds lo and hi are unbounded, and their average mean
+func explainRange(lo, hi, min, max int, mean string) string {
+	switch {
+	case lo <= -maxBound && hi >= maxBound:
+		return "Totals have no minimum or maximum and average " + mean
+	case hi >= maxBound:
+		return fmt.Sprintf("Totals start at %d with no maximum and average %s", min, mean)
+	case lo <= -maxBound:
+		return fmt.Sprintf("Totals go up to %d with no minimum and average %s", max, mean)
+	case min == max:
+		return fmt.Sprintf("The total is always %d", min)
+	default:
+		return fmt.Sprintf("Totals range from %d to %d with an average of %s", min, max, mean)
+	}
+}
+
+// explainSteps describes n as a sequence of instructions, i.e "roll one twenty-sided die, then
+// add 5"
+func explainSteps(n node) string {
+	switch n := unwrap(n).(type) {
+	case *binaryNode:
+		return explainSteps(n.l) + ", then " + opWords[n.op] + " " + explainNoun(n.r)
+
+	case *condNode:
+		return "if " + explainNoun(n.cond) + ", " + explainSteps(n.t) + "; otherwise " + explainSteps(n.f)
+
+	case *diceNode:
+		s := "roll " + explainDice(n)
+		switch steps := explainMods(n.mods); len(steps) {
+		case 0:
+		case 1:
+			s += " and " + steps[0]
+		default:
+			s += ", " + strings.Join(steps[:len(steps)-1], ", ") + ", then " + steps[len(steps)-1]
+		}
+		return s
+	}
+
+	return "take " + explainNoun(n)
+}
+
+// explainNoun describes n as a value, i.e "two four-sided dice plus 1"
+func explainNoun(n node) string {
+	switch n := n.(type) {
+	case numNode, varNode:
+		return n.String()
+
+	case *groupNode:
+		if b, ok := unwrap(n).(*binaryNode); ok {
+			return "the total of " + explainNoun(b)
+		}
+		return explainNoun(n.x)
+
+	case *negNode:
+		return "minus " + explainNoun(n.x)
+
+	case *binaryNode:
+		return explainNoun(n.l) + " " + nounOpWords[n.op] + " " + explainNoun(n.r)
+
+	case *cmpNode:
+		return explainNoun(n.l) + " " + cmpWords[n.op] + " " + explainNoun(n.r)
+
+	case *condNode:
+		return explainNoun(n.t) + " if " + explainNoun(n.cond) + ", otherwise " + explainNoun(n.f)
+
+	case *diceNode:
+		s := explainDice(n)
+		if steps := explainMods(n.mods); len(steps) > 0 {
+			s += " (" + strings.Join(steps, ", then ") + ")"
+		}
+		return s
+	}
+
+	return n.String()
+}
+
+// explainDice describes the dice of a term without its modifiers, i.e "four ten-sided dice"
+func explainDice(d *diceNode) string {
+	kind := "dice"
+	if c, ok := d.count.(numNode); ok && c == 1 {
+		kind = "die"
+	}
+
+	if s, ok := d.sides.(numNode); ok {
+		kind = numberWord(int(s)) + "-sided " + kind
+	} else {
+		kind += " with " + explainNoun(d.sides) + " sides"
+	}
+
+	if c, ok := d.count.(numNode); ok {
+		return numberWord(int(c)) + " " + kind
+	}
+
+	return "a number of " + kind + " equal to " + explainNoun(d.count)
+}
+
+// explainMods describes each of a term's modifiers as an instruction, in the order they apply
+func explainMods(mods []modifier) []string {
+	var out []string
+
+	for _, m := range mods {
+		switch m.kind {
+		case modKeep:
+			out = append(out, "keep "+fmt.Sprintf(selectWords[m.hl], numberWord(m.n)))
+		case modDrop:
+			out = append(out, "drop "+fmt.Sprintf(selectWords[m.hl], numberWord(m.n)))
+		case modKeepN:
+			out = append(out, "keep only "+faceList(m.match, "and"))
+		case modDropN:
+			out = append(out, "drop any "+faceList(m.match, "or"))
+		case modExplode:
+			out = append(out, "explode "+faceList(m.match, "and"))
+		case modReroll:
+			out = append(out, "reroll "+faceList(m.match, "and")+" every time they come up")
+		case modRerollOnce:
+			out = append(out, "reroll "+faceList(m.match, "and")+" once")
+		default:
+			out = append(out, "apply "+m.src)
+		}
+	}
+
+	return out
+}
+
+var (
+	opWords     = map[string]string{"+": "add", "-": "subtract", "*": "multiply by"}
+	nounOpWords = map[string]string{"+": "plus", "-": "minus", "*": "multiplied by"}
+	cmpWords    = map[string]string{
+		">=": "is at least",
+		"<=": "is at most",
+		">":  "is more than",
+		"<":  "is less than",
+		"==": "is exactly",
+		"!=": "is not",
+	}
+	selectWords = map[MatchType]string{
+		HIGH:   "the highest %s",
+		LOW:    "the lowest %s",
+		MIDDLE: "the middle %s",
+		FIRST:  "the first %s rolled",
+		LAST:   "the last %s rolled",
+	}
+	numberWords = []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+		"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen",
+		"nineteen", "twenty",
+	}
+)
+
+// numberWord spells out n up to twenty
+func numberWord(n int) string {
+	if n >= 0 && n < len(numberWords) {
+		return numberWords[n]
+	}
+
+	return strconv.Itoa(n)
+}
+
+// faceList lists faces as plurals joined with conj, i.e "1s, 2s or 3s"
+func faceList(faces []int, conj string) string {
+	var out []string
+	for _, f := range faces {
+		out = append(out, strconv.Itoa(f)+"s")
+	}
+
+	if len(out) < 2 {
+		return strings.Join(out, "")
+	}
+
+	return strings.Join(out[:len(out)-1], ", ") + " " + conj + " " + out[len(out)-1]
+}
+
+// explainBands lists bands with their ranges, i.e "miss (6 or less), weak hit (7 to 9) or strong
+// hit (10 or more)"
+func explainBands(b Bands) string {
+	var out []string
+
+	for _, band := range b {
+		var rng string
+		switch {
+		case band.Min == math.MinInt32 && band.Max == math.MaxInt32:
+			rng = "any value"
+		case band.Min == math.MinInt32:
+			rng = fmt.Sprintf("%d or less", band.Max)
+		case band.Max == math.MaxInt32:
+			rng = fmt.Sprintf("%d or more", band.Min)
+		case band.Min == band.Max:
+			rng = strconv.Itoa(band.Min)
+		default:
+			rng = fmt.Sprintf("%d to %d", band.Min, band.Max)
+		}
+		out = append(out, fmt.Sprintf("%s (%s)", band.Label, rng))
+	}
+
+	if len(out) < 2 {
+		return strings.Join(out, "")
+	}
+
+	return strings.Join(out[:len(out)-1], ", ") + " or " + out[len(out)-1]
+}
+
+// explainFloat formats f to at most two decimal places
+func explainFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 2, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// sentence capitalises s and ends it with a full stop
+func sentence(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
@@ -0,0 +1,20 @@
+package roll
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainRange(t *testing.T) {
+	for s, want := range map[string]string{
+		"3d6":           "Totals range from 3 to 18 with an average of 10.5.",
+		"2d6X6":         "Totals start at 2 with no maximum and average 8.4.",
+		"4d10Kh3X10Dl1": "Totals start at 2 with no maximum and average about ",
+		"4d10X10Kh3":    "Totals range from 3 to 30 with an average of about ",
+		"-1d6X6":        "Totals go up to -1 with no minimum and average -4.2.",
+	} {
+		if got := Explain(MustParse(s)); !strings.Contains(got, want) {
+			t.Errorf("%s: got %q, want it to contain %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package roll
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed dice expression such as 1d20+5 or 4d6Kh3+2d4-1. Expressions can be rolled
+// repeatedly without being parsed again.
+type Expr struct {
+	src    string
+	root   node
+	bands  Bands
+	source Source
+	limits Limits
+}
+
+// Outcome is the result of rolling an Expr: the total and the Result of every dice term, in the
+// order they appear in the expression. Check is set when the expression is a comparison such
+// as 1d20+5 >= 15 and Label is set when the expression has Bands.
+type Outcome struct {
+	Total   int
+	Results Results
+	Check   *Check
+	Label   string
+}
+
+// Check is the result of comparing a total against a target. Margin is how far the total beat
+// (positive) or missed (negative) the target by; for < and <= a lower total is better, so
+// Margin is Target - Total.
+type Check struct {
+	Target int
+	Pass   bool
+	Margin int
+}
+
+// Vars binds values to the variables referenced by an expression, keyed by name without the
+// leading $
+type Vars map[string]int
+
+// MissingVariableError is returned by Eval when an expression references a variable that
+// hasn't been bound
+type MissingVariableError struct {
+	Name string
+}
+
+func (e *MissingVariableError) Error() string {
+	return fmt.Sprintf("variable $%s is not bound", e.Name)
+}
+
+// InvalidDieError is returned by Eval when a dice term whose count or sides are variables or
+// expressions, such as $n d6 or 2d(1d4), comes to roll fewer than 1 die or a die of fewer than 2
+// sides. The same dice written as literals are rejected by Parse.
+type InvalidDieError struct {
+	Term         string
+	Count, Sides int
+}
+
+func (e *InvalidDieError) Error() string {
+	if e.Count < 1 {
+		return fmt.Sprintf("non-euclidean die: %s rolls %d dice", e.Term, e.Count)
+	}
+
+	return fmt.Sprintf("non-euclidean die: %s rolls a d%d", e.Term, e.Sides)
+}
+
+// Parse reads a dice expression. An expression is made up of dice terms using the syntax
+// accepted by FromString (3d6, 4d10Kh3X10 etc), whole numbers, variables and parentheses
+// joined with +, - and *. Variables are written as $name and are bound when the expression is
+// evaluated with Eval. The dice count and sides may be variables or parenthesised
+// expressions, i.e (1d4)d6, $level d6 or 2d$die. A variable followed directly by a dice term
+// must be separated from it by a space.
+//
+// Expressions can also describe checks:
+//   - comparisons with >=, <=, >, <, == and != evaluate to 1 or 0. When the whole expression is
+//     a comparison, i.e 1d20+5 >= 15, the Outcome's Total is the left hand side and its Check
+//     records whether it passed and by what margin.
+//   - cond ? a : b evaluates to a if cond is non-zero and b otherwise, i.e 1d20>=20 ? 2d8+3 : 1d8+3
+//   - a trailing [bands] labels the Outcome using ParseBands syntax, i.e 2d6+1 [pbta] or
+//     1d20+7 >= 18 [pf2]. Bands match the Check's Margin when there is one and Total otherwise.
+//
+// Expressions are parsed and evaluated within DefaultLimits, use ParseWithLimits for others.
+func Parse(s string) (*Expr, error) {
+	return parse(s, DefaultLimits)
+}
+
+// parse parses s within l, suggesting a correction for common mistakes
+func parse(s string, l Limits) (*Expr, error) {
+	e, err := parseExpr(s, l)
+	if pe, ok := err.(*ParseError); ok && pe.Kind != LimitExceeded {
+		pe.Suggestion = suggest(pe.Input, pe.Pos, l)
+	}
+
+	return e, err
+}
+
+func parseExpr(s string, l Limits) (*Expr, error) {
+	s = strings.TrimSpace(s)
+	if err := exceeds("MaxLength", l.MaxLength, len(s)); err != nil {
+		return nil, spanErr(err, LimitExceeded, s, l.MaxLength, len(s))
+	}
+
+	toks, err := scan(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: s, toks: toks, limits: l}
+	root, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Expr{src: s, root: root, limits: l}
+	if t := p.peek(); t.kind == tokBands {
+		p.next()
+		if e.bands, err = ParseBands(t.text[1 : len(t.text)-1]); err != nil {
+			return nil, p.errorf(InvalidBands, t, "%v", err)
+		}
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(SyntaxError, t, "unexpected %s", t.text)
+	}
+
+	return e, nil
+}
+
+// MustParse is like Parse but panics if s cannot be parsed. It simplifies declaring
+// package level expressions.
+func MustParse(s string) *Expr {
+	e, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+// Roll evaluates the expression and returns its Outcome. Variables evaluate as 0, use Eval
+// to bind them. Terms that would exceed the expression's Limits roll no dice, and none are
+// rolled if its Cost exceeds them; use Eval to get the error.
+func (e *Expr) Roll() Outcome {
+	ev := &evaluator{source: e.source, limits: e.limits}
+	ev.err = e.limits.Check(e.Cost(nil))
+
+	return e.eval(ev)
+}
+
+// Eval evaluates the expression with variables bound from vars. A *MissingVariableError is
+// returned, without rolling any dice, if the expression references a variable that isn't in vars
+// and a *LimitError if evaluating it would exceed its Limits, see EvalContext.
+func (e *Expr) Eval(vars Vars) (Outcome, error) {
+	return e.EvalContext(context.Background(), vars)
+}
+
+func (e *Expr) eval(ev *evaluator) Outcome {
+	var o Outcome
+
+	if c, ok := unwrap(e.root).(*cmpNode); ok {
+		l, r, pass := c.compare(ev)
+		o.Total, o.Check = l, &Check{Target: r, Pass: pass, Margin: l - r}
+		if c.op == "<" || c.op == "<=" {
+			o.Check.Margin = r - l
+		}
+	} else {
+		o.Total = e.root.eval(ev)
+	}
+	o.Results = ev.results
+
+	if e.bands != nil {
+		if o.Check != nil {
+			o.Label = e.bands.Label(o.Check.Margin)
+		} else {
+			o.Label = e.bands.Label(o.Total)
+		}
+	}
+
+	return o
+}
+
+// Bands returns the labels applied to the expression's Outcomes
+func (e *Expr) Bands() Bands {
+	return e.bands
+}
+
+// WithSource returns a copy of the expression that rolls its dice using src. Sources such as
+// *rand.Rand aren't safe for concurrent use, so neither is the returned Expr.
+func (e *Expr) WithSource(src Source) *Expr {
+	out := *e
+	out.source = src
+	return &out
+}
+
+// WithBands returns a copy of the expression that labels its Outcomes with b
+func (e *Expr) WithBands(b Bands) *Expr {
+	out := *e
+	out.bands = b
+	return &out
+}
+
+// Vars returns the names of the variables referenced by the expression in the order they first
+// appear
+func (e *Expr) Vars() []string {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+
+	walk(e.root, func(n node) {
+		if v, ok := n.(varNode); ok && !seen[string(v)] {
+			seen[string(v)] = true
+			names = append(names, string(v))
+		}
+	})
+
+	return names
+}
+
+// String returns the expression as it was parsed
+func (e *Expr) String() string {
+	return e.src
+}
+
+// evaluator carries state through a single evaluation of an expression tree
+type evaluator struct {
+	vars    Vars
+	source  Source
+	results Results
+
+	// limits are checked as dice are rolled. The first error, including ctx's, stops any more
+	// dice being rolled.
+	limits Limits
+	ctx    context.Context
+	err    error
+	dice   int
+
+	// batch evaluations only compute totals, reusing buf and tmp between rolls rather than
+	// recording Results
+	batch    bool
+	buf, tmp []int
+	gen      []int
+}
+
+// node is an element of a parsed expression
+type node interface {
+	eval(ev *evaluator) int
+	String() string
+}
+
+// numNode is a literal whole number
+type numNode int
+
+func (n numNode) eval(ev *evaluator) int { return int(n) }
+func (n numNode) String() string         { return strconv.Itoa(int(n)) }
+
+// varNode is a variable bound at evaluation time
+type varNode string
+
+func (v varNode) eval(ev *evaluator) int { return ev.vars[string(v)] }
+func (v varNode) String() string         { return "$" + string(v) }
+
+// groupNode is a parenthesised expression
+type groupNode struct {
+	x node
+}
+
+func (g *groupNode) eval(ev *evaluator) int { return g.x.eval(ev) }
+func (g *groupNode) String() string         { return "(" + g.x.String() + ")" }
+
+// negNode negates its operand
+type negNode struct {
+	x node
+}
+
+func (n *negNode) eval(ev *evaluator) int { return -n.x.eval(ev) }
+func (n *negNode) String() string         { return "-" + n.x.String() }
+
+// binaryNode is an arithmetic operation on two nodes
+type binaryNode struct {
+	op   string
+	l, r node
+}
+
+func (b *binaryNode) eval(ev *evaluator) int {
+	return arith(b.op, b.l.eval(ev), b.r.eval(ev))
+}
+
+// arith applies the arithmetic operator op to l and r
+func arith(op string, l, r int) int {
+	switch op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	}
+
+	return 0
+}
+
+func (b *binaryNode) String() string {
+	return b.l.String() + b.op + b.r.String()
+}
+
+// cmpNode compares two nodes and evaluates to 1 if the comparison holds and 0 if not
+type cmpNode struct {
+	op   string
+	l, r node
+}
+
+func (c *cmpNode) eval(ev *evaluator) int {
+	if _, _, pass := c.compare(ev); pass {
+		return 1
+	}
+
+	return 0
+}
+
+func (c *cmpNode) compare(ev *evaluator) (int, int, bool) {
+	l, r := c.l.eval(ev), c.r.eval(ev)
+	return l, r, compare(c.op, l, r)
+}
+
+// compare reports whether l op r holds for the comparison operator op
+func compare(op string, l, r int) bool {
+	switch op {
+	case ">=":
+		return l >= r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case "<":
+		return l < r
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+
+	return false
+}
+
+func (c *cmpNode) String() string {
+	return c.l.String() + c.op + c.r.String()
+}
+
+// condNode evaluates t if cond is non-zero and f otherwise
+type condNode struct {
+	cond, t, f node
+}
+
+func (c *condNode) eval(ev *evaluator) int {
+	if c.cond.eval(ev) != 0 {
+		return c.t.eval(ev)
+	}
+
+	return c.f.eval(ev)
+}
+
+func (c *condNode) String() string {
+	return c.cond.String() + "?" + c.t.String() + ":" + c.f.String()
+}
+
+// diceNode is a dice term such as 4d6Kh3
+type diceNode struct {
+	count, sides node
+	die          Die // set when sides is a literal
+	mods         []modifier
+}
+
+func (d *diceNode) eval(ev *evaluator) int {
+	if ev.batch {
+		return d.total(ev)
+	}
+
+	return d.roll(ev).Sum()
+}
+
+// roll the term and record its Result in ev
+func (d *diceNode) roll(ev *evaluator) Result {
+	var (
+		n     = d.count.eval(ev)
+		sides = len(d.die.faces)
+	)
+	if d.die.faces == nil {
+		sides = d.sides.eval(ev)
+	}
+
+	// A rejected term rolls nothing, without building a die its sides may be too large for
+	if !ev.valid(d, n, sides) || !ev.start(n, sides) {
+		r := Result{die: d.die, src: ev.source}
+		ev.results = append(ev.results, r)
+		return r
+	}
+
+	die := d.die
+	if die.faces == nil {
+		die = NewDie(makeFaces(sides))
+	}
+
+	r := RollWith(ev.source, n, die)
+	for _, m := range d.mods {
+		switch {
+		case m.kind == modReroll && ev.limits.MaxExplodeDepth > 0:
+			var stopped bool
+			if r, stopped = r.reroll(false, ev.limits.MaxExplodeDepth, m.match); stopped {
+				ev.limit("MaxExplodeDepth", ev.limits.MaxExplodeDepth)
+			}
+
+		case m.kind != modExplode || (ev.limits.MaxExplodeDepth == 0 && ev.limits.MaxDice == 0):
+			r = m.apply(r)
+
+		default:
+			var limit string
+			switch r, limit = r.explode(ev.limits.MaxExplodeDepth, ev.limits.MaxDice, m.match); limit {
+			case "MaxExplodeDepth":
+				ev.limit(limit, ev.limits.MaxExplodeDepth)
+			case "MaxDice":
+				ev.limit(limit, ev.limits.MaxDice)
+			}
+		}
+	}
+	ev.rolled(len(r.rolls) + len(r.dropped))
+
+	ev.results = append(ev.results, r)
+	return r
+}
+
+// valid reports whether the term can roll n dice of sides, recording an *InvalidDieError in
+// ev if it can't. sides is ignored when the term's sides are a literal, which check has already
+// tested.
+func (ev *evaluator) valid(d *diceNode, n, sides int) bool {
+	if n >= 1 && (d.die.faces != nil || sides >= 2) {
+		return true
+	}
+
+	if ev.err == nil {
+		ev.err = &InvalidDieError{Term: d.String(), Count: n, Sides: sides}
+	}
+	return false
+}
+
+// check that the term can be rolled where its count and sides are known at parse time
+func (d *diceNode) check() error {
+	if c, ok := d.count.(numNode); ok && c == 0 {
+		return fmt.Errorf("non-euclidean die: %s", d)
+	}
+	if s, ok := d.sides.(numNode); ok && s < 2 {
+		return fmt.Errorf("non-euclidean die: %s", d)
+	}
+
+	return nil
+}
+
+func (d *diceNode) String() string {
+	var b strings.Builder
+
+	b.WriteString(d.count.String())
+	if _, ok := d.count.(varNode); ok {
+		b.WriteString(" ")
+	}
+	b.WriteString("d")
+	b.WriteString(d.sides.String())
+	for _, m := range d.mods {
+		b.WriteString(m.src)
+	}
+
+	return b.String()
+}
+
+// walk calls fn for n and every node beneath it, depth first
+func walk(n node, fn func(node)) {
+	fn(n)
+	walkChildren(n, func(x node) { walk(x, fn) })
+}
+
+// walkChildren calls fn for each of the nodes directly beneath n
+func walkChildren(n node, fn func(node)) {
+	switch n := n.(type) {
+	case *groupNode:
+		fn(n.x)
+	case *negNode:
+		fn(n.x)
+	case *binaryNode:
+		fn(n.l)
+		fn(n.r)
+	case *cmpNode:
+		fn(n.l)
+		fn(n.r)
+	case *condNode:
+		fn(n.cond)
+		fn(n.t)
+		fn(n.f)
+	case *diceNode:
+		fn(n.count)
+		fn(n.sides)
+	}
+}
+
+// unwrap returns the node inside any enclosing parentheses
+func unwrap(n node) node {
+	for {
+		g, ok := n.(*groupNode)
+		if !ok {
+			return n
+		}
+		n = g.x
+	}
+}
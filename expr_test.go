@@ -0,0 +1,169 @@
+package roll
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDynamicDiceChecks(t *testing.T) {
+	for _, c := range []struct {
+		s            string
+		count, sides int
+	}{
+		{"(0)d6", 0, 6},
+		{"(-3)d6", -3, 6},
+		{"2d(-5)", 2, -5},
+		{"2d(1)", 2, 1},
+		{"$n d6", 0, 6},
+		{"2d$s", 2, 1},
+	} {
+		e, err := Parse(c.s)
+		if err != nil {
+			t.Errorf("%s: %v", c.s, err)
+			continue
+		}
+		vars := Vars{"n": 0, "s": 1}
+
+		var bad *InvalidDieError
+		if _, err := e.Eval(vars); !errors.As(err, &bad) || bad.Count != c.count || bad.Sides != c.sides {
+			t.Errorf("%s: Eval got %v, want an InvalidDieError for %dd%d", c.s, err, c.count, c.sides)
+		}
+		if err := e.EvalN(10, vars, func(int) {}); !errors.As(err, &bad) {
+			t.Errorf("%s: EvalN got %v, want an InvalidDieError", c.s, err)
+		}
+		if _, err := e.Distribution(vars); !errors.As(err, &bad) {
+			t.Errorf("%s: Distribution got %v, want an InvalidDieError", c.s, err)
+		}
+	}
+
+	for _, s := range []string{"(1)d6", "2d(2)", "(1d4)d(1d6+1)"} {
+		if _, err := MustParse(s).Eval(nil); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+
+	if _, err := FromString("(0)d6"); err == nil {
+		t.Errorf("FromString((0)d6) rolled nothing without an error")
+	}
+}
+
+// evalSeq evaluates s with its dice rolling the numbers of seq in turn
+func evalSeq(t *testing.T, s string, seq ...int) Outcome {
+	t.Helper()
+
+	src := seqSource(seq)
+	o, err := MustParse(s).WithSource(&src).Eval(nil)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	if len(src) != 0 {
+		t.Errorf("%s: %d numbers left over from %v", s, len(src), seq)
+	}
+
+	return o
+}
+
+func TestComparisons(t *testing.T) {
+	for _, c := range []struct {
+		s     string
+		seq   []int
+		total int
+		check *Check
+	}{
+		{"1d20+5 >= 15", []int{10}, 15, &Check{Target: 15, Pass: true, Margin: 0}},
+		{"1d20+5 >= 15", []int{9}, 14, &Check{Target: 15, Pass: false, Margin: -1}},
+		{"1d20+5 >= 15", []int{20}, 25, &Check{Target: 15, Pass: true, Margin: 10}},
+		{"1d20 > 15", []int{15}, 15, &Check{Target: 15, Pass: false, Margin: 0}},
+		{"1d20 > 15", []int{16}, 16, &Check{Target: 15, Pass: true, Margin: 1}},
+		// Under checks count the margin the other way, so a pass is never negative
+		{"1d100 <= 40", []int{25}, 25, &Check{Target: 40, Pass: true, Margin: 15}},
+		{"1d100 <= 40", []int{55}, 55, &Check{Target: 40, Pass: false, Margin: -15}},
+		{"1d100 < 40", []int{40}, 40, &Check{Target: 40, Pass: false, Margin: 0}},
+		{"1d6 == 3", []int{3}, 3, &Check{Target: 3, Pass: true, Margin: 0}},
+		{"1d6 != 3", []int{3}, 3, &Check{Target: 3, Pass: false, Margin: 0}},
+		{"1d6 != 3", []int{5}, 5, &Check{Target: 3, Pass: true, Margin: 2}},
+		{"(1d20 >= 1d20)", []int{7, 12}, 7, &Check{Target: 12, Pass: false, Margin: -5}},
+		// Comparisons within an expression are 1 or 0
+		{"(1d6 >= 4) + (1d6 >= 4) + (1d6 >= 4)", []int{4, 3, 6}, 2, nil},
+		{"(1d6 < 4) * 10", []int{2}, 10, nil},
+		{"2 * (1d6 == 6) + 1", []int{5}, 1, nil},
+	} {
+		o := evalSeq(t, c.s, c.seq...)
+
+		if o.Total != c.total {
+			t.Errorf("%s with %v: total %d, want %d", c.s, c.seq, o.Total, c.total)
+		}
+		switch {
+		case c.check == nil && o.Check != nil:
+			t.Errorf("%s with %v: got check %+v, want none", c.s, c.seq, *o.Check)
+		case c.check != nil && o.Check == nil:
+			t.Errorf("%s with %v: got no check, want %+v", c.s, c.seq, *c.check)
+		case c.check != nil && *o.Check != *c.check:
+			t.Errorf("%s with %v: got check %+v, want %+v", c.s, c.seq, *o.Check, *c.check)
+		}
+	}
+}
+
+func TestTernaries(t *testing.T) {
+	for _, c := range []struct {
+		s     string
+		seq   []int
+		total int
+		dice  int // terms rolled, the branch not taken rolls nothing
+	}{
+		{"1d20>=20 ? 2d8+3 : 1d8+3", []int{20, 5, 6}, 14, 2},
+		{"1d20>=20 ? 2d8+3 : 1d8+3", []int{19, 4}, 7, 2},
+		{"1d6 ? 1 : 2", []int{1}, 1, 1},
+		{"(1d6 - 1) ? 1 : 2", []int{1}, 2, 1},
+		{"1d6 >= 4 ? 10 : (1d6 >= 2 ? 5 : 0)", []int{1, 3}, 5, 2},
+		{"1d6 >= 4 ? 10 : (1d6 >= 2 ? 5 : 0)", []int{1, 1}, 0, 2},
+		{"1d6 >= 4 ? 10 : (1d6 >= 2 ? 5 : 0)", []int{6}, 10, 1},
+		{"(1d20 >= 10 ? 1d6 : 0) + 1d4", []int{10, 6, 2}, 8, 3},
+	} {
+		o := evalSeq(t, c.s, c.seq...)
+
+		if o.Total != c.total || len(o.Results) != c.dice {
+			t.Errorf("%s with %v: total %d from %d terms, want %d from %d", c.s, c.seq, o.Total, len(o.Results), c.total, c.dice)
+		}
+		if o.Check != nil {
+			t.Errorf("%s with %v: got check %+v, want none", c.s, c.seq, *o.Check)
+		}
+	}
+}
+
+func TestBandLabels(t *testing.T) {
+	for _, c := range []struct {
+		s     string
+		seq   []int
+		label string
+	}{
+		// Without a check the total is labelled
+		{"2d6+1 [pbta]", []int{1, 2}, "miss"},
+		{"2d6+1 [pbta]", []int{2, 3}, "miss"},
+		{"2d6+1 [pbta]", []int{3, 3}, "weak hit"},
+		{"2d6+1 [pbta]", []int{4, 4}, "weak hit"},
+		{"2d6+1 [pbta]", []int{6, 3}, "strong hit"},
+		// With a check the margin is
+		{"1d20+7 >= 18 [pf2]", []int{1}, "critical failure"},
+		{"1d20+7 >= 18 [pf2]", []int{2}, "failure"},
+		{"1d20+7 >= 18 [pf2]", []int{10}, "failure"},
+		{"1d20+7 >= 18 [pf2]", []int{11}, "success"},
+		{"1d20+7 >= 18 [pf2]", []int{20}, "success"},
+		{"1d20+8 >= 18 [pf2]", []int{20}, "critical success"},
+		// An under check's margin is positive when it passes
+		{"1d100 <= 50 [fail:..-1, pass:0..19, crit:20..]", []int{30}, "crit"},
+		{"1d100 <= 50 [fail:..-1, pass:0..19, crit:20..]", []int{50}, "pass"},
+		{"1d100 <= 50 [fail:..-1, pass:0..19, crit:20..]", []int{51}, "fail"},
+		{"1d6 [six:6]", []int{5}, ""},
+		{"1d6 [six:6]", []int{6}, "six"},
+	} {
+		if o := evalSeq(t, c.s, c.seq...); o.Label != c.label {
+			t.Errorf("%s with %v: label %q, want %q", c.s, c.seq, o.Label, c.label)
+		}
+	}
+
+	src := seqSource{3, 4}
+	if o, err := MustParse("2d6").WithBands(PbtA).WithSource(&src).Eval(nil); err != nil || o.Label != "weak hit" {
+		t.Errorf("2d6 with PbtA: got label %q, %v, want weak hit", o.Label, err)
+	}
+}
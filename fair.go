@@ -0,0 +1,237 @@
+package roll
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// CryptoSource is a Source backed by crypto/rand, for games where rolls must not be
+// predictable from earlier ones. It's much slower than math/rand. Intn panics if the operating
+// system's random number generator fails.
+type CryptoSource struct{}
+
+// Intn returns a number in [0, n)
+func (CryptoSource) Intn(n int) int {
+	return uniform(func() uint64 {
+		var buf [8]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			panic(err)
+		}
+		return binary.BigEndian.Uint64(buf[:])
+	}, n)
+}
+
+// FairRoller rolls dice that can be proven fair with a commit-reveal scheme. The server seed
+// is kept secret while its SHA-256 Commitment is published to the players, who choose the
+// client seed. Every roll gets the next nonce and draws its numbers from
+// HMAC-SHA256(server seed, client seed:nonce:block), so neither side alone can choose the
+// outcome. When the server seed is revealed anyone can check it against the commitment and
+// recompute every roll from its Proof. A FairRoller is not safe for concurrent use.
+/* For Example:
+
+   f, err := roll.NewFairRoller("alice,bob")
+   fmt.Println(f.Commitment()) // published before rolling
+
+   r := f.Roll(3, roll.D6)
+   p, _ := r.Proof()
+
+   // Later, once f.ServerSeed() is revealed
+   err = p.VerifyRoll(serverSeed, 3, roll.D6, r.Ints())
+
+*/
+type FairRoller struct {
+	seed       []byte
+	clientSeed string
+	nonce      uint64
+}
+
+// NewFairRoller returns a FairRoller with a new secret server seed from crypto/rand. The seeds
+// of several players can be combined into clientSeed, i.e by joining them with commas.
+func NewFairRoller(clientSeed string) (*FairRoller, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+
+	return &FairRoller{seed: seed, clientSeed: clientSeed}, nil
+}
+
+// NewFairRollerWithSeed returns a FairRoller for a hex encoded server seed that was committed
+// to earlier, starting from nonce
+func NewFairRollerWithSeed(serverSeed, clientSeed string, nonce uint64) (*FairRoller, error) {
+	seed, err := hex.DecodeString(serverSeed)
+	if err != nil || len(seed) == 0 {
+		return nil, fmt.Errorf("server seed must be hex encoded")
+	}
+
+	return &FairRoller{seed: seed, clientSeed: clientSeed, nonce: nonce}, nil
+}
+
+// Commitment returns the hex encoded SHA-256 hash of the server seed
+func (f *FairRoller) Commitment() string {
+	return commit(f.seed)
+}
+
+// ServerSeed reveals the hex encoded server seed. Rolls made after it has been revealed can be
+// predicted, so start a new FairRoller.
+func (f *FairRoller) ServerSeed() string {
+	return hex.EncodeToString(f.seed)
+}
+
+// Nonce returns the nonce of the next roll
+func (f *FairRoller) Nonce() uint64 {
+	return f.nonce
+}
+
+// Next returns the Source for the next roll and advances the nonce. The Source can roll any
+// number of dice or expressions, which are all proven by its Proof.
+func (f *FairRoller) Next() *FairSource {
+	src := newFairSource(f.seed, Proof{Commitment: f.Commitment(), ClientSeed: f.clientSeed, Nonce: f.nonce})
+	f.nonce++
+	return src
+}
+
+// Roll rolls n Die from the next Source. Result.Proof returns the proof of the roll.
+func (f *FairRoller) Roll(n int, d Die) Result {
+	return RollWith(f.Next(), n, d)
+}
+
+// Eval evaluates e with vars using the next Source and returns the Outcome and its Proof
+func (f *FairRoller) Eval(e *Expr, vars Vars) (Outcome, Proof, error) {
+	src := f.Next()
+	o, err := e.WithSource(src).Eval(vars)
+	return o, src.proof, err
+}
+
+// Proof is the public record of a fair roll. Together with the revealed server seed it's
+// enough to recompute every number drawn for the roll.
+type Proof struct {
+	Commitment string
+	ClientSeed string
+	Nonce      uint64
+}
+
+// Proof returns the Proof of a Result rolled with a FairSource, or false if it wasn't
+func (r Result) Proof() (Proof, bool) {
+	if src, ok := r.src.(*FairSource); ok {
+		return src.proof, true
+	}
+
+	return Proof{}, false
+}
+
+// Source checks serverSeed against the proof's commitment and returns a new Source that
+// replays the numbers of the roll
+func (p Proof) Source(serverSeed string) (*FairSource, error) {
+	seed, err := hex.DecodeString(serverSeed)
+	if err != nil {
+		return nil, fmt.Errorf("server seed must be hex encoded")
+	}
+	if !VerifyCommitment(serverSeed, p.Commitment) {
+		return nil, fmt.Errorf("server seed does not match commitment %s", p.Commitment)
+	}
+
+	return newFairSource(seed, p), nil
+}
+
+// VerifyRoll recomputes a roll of n Die and returns an error if its numbers, in roll order, are
+// not faces
+func (p Proof) VerifyRoll(serverSeed string, n int, d Die, faces []int) error {
+	src, err := p.Source(serverSeed)
+	if err != nil {
+		return err
+	}
+
+	want := RollWith(src, n, d).Ints()
+	if len(want) != len(faces) {
+		return fmt.Errorf("rolled %v, proof gives %v", faces, want)
+	}
+	for i := range want {
+		if want[i] != faces[i] {
+			return fmt.Errorf("rolled %v, proof gives %v", faces, want)
+		}
+	}
+
+	return nil
+}
+
+// VerifyExpr recomputes the Outcome of expression s evaluated with vars and returns it, with an
+// error if its Total is not total
+func (p Proof) VerifyExpr(serverSeed, s string, vars Vars, total int) (Outcome, error) {
+	src, err := p.Source(serverSeed)
+	if err != nil {
+		return Outcome{}, err
+	}
+
+	e, err := Parse(s)
+	if err != nil {
+		return Outcome{}, err
+	}
+
+	o, err := e.WithSource(src).Eval(vars)
+	if err != nil {
+		return o, err
+	}
+	if o.Total != total {
+		return o, fmt.Errorf("total %d, proof gives %d", total, o.Total)
+	}
+
+	return o, nil
+}
+
+// VerifyCommitment reports whether the hex encoded serverSeed hashes to commitment
+func VerifyCommitment(serverSeed, commitment string) bool {
+	seed, err := hex.DecodeString(serverSeed)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal([]byte(commit(seed)), []byte(commitment))
+}
+
+func commit(seed []byte) string {
+	h := sha256.Sum256(seed)
+	return hex.EncodeToString(h[:])
+}
+
+// FairSource is the deterministic Source of a single fair roll, see FairRoller
+type FairSource struct {
+	seed  []byte
+	proof Proof
+	block uint64
+	buf   []byte
+}
+
+func newFairSource(seed []byte, p Proof) *FairSource {
+	return &FairSource{seed: seed, proof: p}
+}
+
+// Proof returns the proof of the numbers drawn from src
+func (src *FairSource) Proof() Proof {
+	return src.proof
+}
+
+// Intn returns a number in [0, n)
+func (src *FairSource) Intn(n int) int {
+	return uniform(src.uint64, n)
+}
+
+// uint64 returns the next 8 bytes of HMAC-SHA256(seed, clientSeed:nonce:block), moving to the
+// next block when the current one is used up
+func (src *FairSource) uint64() uint64 {
+	if len(src.buf) < 8 {
+		mac := hmac.New(sha256.New, src.seed)
+		mac.Write([]byte(src.proof.ClientSeed + ":" + strconv.FormatUint(src.proof.Nonce, 10) + ":" + strconv.FormatUint(src.block, 10)))
+		src.buf = mac.Sum(nil)
+		src.block++
+	}
+
+	v := binary.BigEndian.Uint64(src.buf)
+	src.buf = src.buf[8:]
+	return v
+}
@@ -0,0 +1,148 @@
+package roll
+
+import (
+	"strings"
+	"testing"
+)
+
+// zeroSeed is a fixed server seed for reproducible fair rolls
+var zeroSeed = strings.Repeat("00", 32)
+
+// TestFairRollerGolden pins the numbers drawn for a seed, which must not change between versions
+// or old proofs would stop verifying
+func TestFairRollerGolden(t *testing.T) {
+	f, err := NewFairRollerWithSeed(zeroSeed, "alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := f.Commitment(), "66687aadf862bd776c8fc18b8e9f8e20089714856ee233b3902a591d0d5f2925"; got != want {
+		t.Errorf("commitment %s, want %s", got, want)
+	}
+	if got := f.Roll(5, D6).Ints(); !equalInts(got, []int{4, 1, 6, 3, 2}) {
+		t.Errorf("nonce 0 rolled %v", got)
+	}
+	if got := f.Roll(3, D20).Ints(); !equalInts(got, []int{19, 20, 20}) {
+		t.Errorf("nonce 1 rolled %v", got)
+	}
+
+	o, p, err := f.Eval(MustParse("4d6Kh3+2"), nil)
+	if err != nil || o.Total != 10 || p.Nonce != 2 || p.ClientSeed != "alice" || p.Commitment != f.Commitment() {
+		t.Errorf("nonce 2 gave %d with %+v, %v", o.Total, p, err)
+	}
+	if f.Nonce() != 3 {
+		t.Errorf("next nonce %d, want 3", f.Nonce())
+	}
+}
+
+func TestVerifyCommitment(t *testing.T) {
+	f, err := NewFairRoller("alice,bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifyCommitment(f.ServerSeed(), f.Commitment()) {
+		t.Errorf("the server seed should match its commitment")
+	}
+	if VerifyCommitment(zeroSeed, f.Commitment()) {
+		t.Errorf("another seed shouldn't match the commitment")
+	}
+	if VerifyCommitment("not hex", f.Commitment()) {
+		t.Errorf("a seed that isn't hex shouldn't match")
+	}
+
+	if _, err := NewFairRollerWithSeed("not hex", "", 0); err == nil {
+		t.Errorf("a server seed that isn't hex should be an error")
+	}
+}
+
+func TestVerifyRoll(t *testing.T) {
+	f, _ := NewFairRollerWithSeed(zeroSeed, "alice", 7)
+	r := f.Roll(4, D6)
+
+	p, ok := r.Proof()
+	if !ok {
+		t.Fatal("a fair roll should have a proof")
+	}
+	if err := p.VerifyRoll(zeroSeed, 4, D6, r.Ints()); err != nil {
+		t.Errorf("VerifyRoll: %v", err)
+	}
+	if _, ok := Roll(4, D6).Proof(); ok {
+		t.Errorf("an ordinary roll shouldn't have a proof")
+	}
+
+	tampered := append([]int(nil), r.Ints()...)
+	tampered[0] = tampered[0]%6 + 1
+	if err := p.VerifyRoll(zeroSeed, 4, D6, tampered); err == nil {
+		t.Errorf("VerifyRoll accepted changed faces")
+	}
+	if err := p.VerifyRoll(zeroSeed, 3, D6, r.Ints()[:3]); err != nil {
+		t.Errorf("VerifyRoll of the first 3 dice: %v", err)
+	}
+
+	other, _ := NewFairRoller("")
+	if err := p.VerifyRoll(other.ServerSeed(), 4, D6, r.Ints()); err == nil {
+		t.Errorf("VerifyRoll accepted another server seed")
+	}
+
+	// A proof claiming another nonce or client seed gives other numbers. 10d20 are rolled so
+	// that they can't match by chance.
+	r = f.Roll(10, D20)
+	p, _ = r.Proof()
+	for _, q := range []Proof{
+		{Commitment: p.Commitment, ClientSeed: p.ClientSeed, Nonce: p.Nonce + 1},
+		{Commitment: p.Commitment, ClientSeed: "mallory", Nonce: p.Nonce},
+	} {
+		if err := q.VerifyRoll(zeroSeed, 10, D20, r.Ints()); err == nil {
+			t.Errorf("proof %+v verified a roll it didn't make", q)
+		}
+	}
+	if err := p.VerifyRoll(zeroSeed, 10, D20, r.Ints()); err != nil {
+		t.Errorf("VerifyRoll: %v", err)
+	}
+}
+
+func TestVerifyExpr(t *testing.T) {
+	f, _ := NewFairRollerWithSeed(zeroSeed, "alice", 0)
+	o, p, err := f.Eval(MustParse("3d6+$bonus"), Vars{"bonus": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.VerifyExpr(zeroSeed, "3d6+$bonus", Vars{"bonus": 2}, o.Total)
+	if err != nil || got.Total != o.Total {
+		t.Errorf("VerifyExpr: got %d, %v", got.Total, err)
+	}
+	if _, err := p.VerifyExpr(zeroSeed, "3d6+$bonus", Vars{"bonus": 2}, o.Total+1); err == nil {
+		t.Errorf("VerifyExpr accepted the wrong total")
+	}
+	if _, err := p.VerifyExpr(strings.Repeat("11", 32), "3d6+$bonus", Vars{"bonus": 2}, o.Total); err == nil {
+		t.Errorf("VerifyExpr accepted a tampered server seed")
+	}
+	if _, err := p.VerifyExpr(zeroSeed, "3d6+", nil, o.Total); err == nil {
+		t.Errorf("VerifyExpr accepted a bad expression")
+	}
+
+	// The nonce is part of the proof: nonce 0 rolls 4, 1, 6 and nonce 1 rolls 19 on a d20 first
+	q := p
+	q.Nonce = 1
+	if got, err := q.VerifyExpr(zeroSeed, "1d20", nil, 19); err != nil || got.Total != 19 {
+		t.Errorf("VerifyExpr of nonce 1: got %d, %v", got.Total, err)
+	}
+	if _, err := q.VerifyExpr(zeroSeed, "3d6+$bonus", Vars{"bonus": 2}, o.Total); err == nil {
+		t.Errorf("VerifyExpr accepted a tampered nonce")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
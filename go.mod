@@ -4,7 +4,7 @@ go 1.13
 
 require (
 	github.com/spf13/cobra v0.0.6
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/pflag v1.0.5
 	gonum.org/v1/netlib v0.0.0-20190926062253-2d6e29b73a19 // indirect
 	gonum.org/v1/plot v0.0.0-20190615073203-9aa86143727f
 )
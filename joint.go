@@ -0,0 +1,402 @@
+package roll
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Joint is the probability of each combination of values of several named components, for
+// mechanics that read more than one number from a roll such as the net successes and
+// advantages of Genesys or the width and height of an ORE set. Probabilities of a complete
+// distribution sum to 1.
+type Joint struct {
+	names []string
+	cells map[string]jointCell
+}
+
+// jointCell is the probability of one combination of values
+type jointCell struct {
+	values []int
+	p      float64
+}
+
+// JointOutcome is one combination of values of a Joint, in the order of its component names
+type JointOutcome struct {
+	names  []string
+	Values []int
+}
+
+// Get returns the value of the component name, 0 if there is no such component
+func (o JointOutcome) Get(name string) int {
+	for i, n := range o.names {
+		if n == name {
+			return o.Values[i]
+		}
+	}
+
+	return 0
+}
+
+// NewJoint returns an empty Joint over the components names
+func NewJoint(names ...string) *Joint {
+	return &Joint{names: append([]string(nil), names...), cells: make(map[string]jointCell)}
+}
+
+// jointKey returns the map key of values
+func jointKey(values []int) string {
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(v))
+	}
+
+	return b.String()
+}
+
+// Names returns the names of the components
+func (j *Joint) Names() []string {
+	return append([]string(nil), j.names...)
+}
+
+// index returns the position of the component name
+func (j *Joint) index(name string) (int, error) {
+	for i, n := range j.names {
+		if n == name {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no component %q, components are %s", name, strings.Join(j.names, ", "))
+}
+
+// AddOutcome adds p to the probability of values, one for each component. It panics if the
+// number of values doesn't match the number of components.
+func (j *Joint) AddOutcome(p float64, values ...int) {
+	if len(values) != len(j.names) {
+		panic(fmt.Sprintf("roll: %d values for a Joint of %d components", len(values), len(j.names)))
+	}
+
+	k := jointKey(values)
+	c, ok := j.cells[k]
+	if !ok {
+		c.values = append([]int(nil), values...)
+	}
+	c.p += p
+	j.cells[k] = c
+}
+
+// Outcomes returns the outcomes with a non-zero probability, ordered by the value of the first
+// component, then the second and so on
+func (j *Joint) Outcomes() []JointOutcome {
+	var out []JointOutcome
+
+	for _, c := range j.cells {
+		if c.p > 0 {
+			out = append(out, JointOutcome{names: j.names, Values: c.values})
+		}
+	}
+	sort.Slice(out, func(a, b int) bool {
+		for i, v := range out[a].Values {
+			if w := out[b].Values[i]; v != w {
+				return v < w
+			}
+		}
+		return false
+	})
+
+	return out
+}
+
+// P returns the probability of values, one for each component
+func (j *Joint) P(values ...int) float64 {
+	return j.cells[jointKey(values)].p
+}
+
+// PFunc returns the probability of any outcome for which fn returns true, i.e
+//
+//	j.PFunc(func(o JointOutcome) bool { return o.Get("success") >= 1 && o.Get("advantage") >= 2 })
+func (j *Joint) PFunc(fn func(o JointOutcome) bool) float64 {
+	t := 0.
+
+	for _, c := range j.cells {
+		if fn(JointOutcome{names: j.names, Values: c.values}) {
+			t += c.p
+		}
+	}
+
+	return t
+}
+
+// Total returns the sum of the probabilities, which is 1 for a complete distribution
+func (j *Joint) Total() float64 {
+	t := 0.
+
+	for _, c := range j.cells {
+		t += c.p
+	}
+
+	return t
+}
+
+// Normalize returns a copy of the joint distribution scaled so that its probabilities sum to 1
+func (j *Joint) Normalize() *Joint {
+	t, out := j.Total(), NewJoint(j.names...)
+	if t == 0 {
+		return out
+	}
+
+	for k, c := range j.cells {
+		out.cells[k] = jointCell{values: c.values, p: c.p / t}
+	}
+
+	return out
+}
+
+// Marginal returns the distribution of the component name alone
+func (j *Joint) Marginal(name string) (Distribution, error) {
+	i, err := j.index(name)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Distribution)
+	for _, c := range j.cells {
+		out[c.values[i]] += c.p
+	}
+
+	return out, nil
+}
+
+// Project returns the joint distribution of the components names alone, in the order given
+func (j *Joint) Project(names ...string) (*Joint, error) {
+	idx := make([]int, len(names))
+	for i, name := range names {
+		var err error
+		if idx[i], err = j.index(name); err != nil {
+			return nil, err
+		}
+	}
+
+	out := NewJoint(names...)
+	values := make([]int, len(names))
+	for _, c := range j.cells {
+		for i, x := range idx {
+			values[i] = c.values[x]
+		}
+		out.AddOutcome(c.p, values...)
+	}
+
+	return out, nil
+}
+
+// Map returns the joint distribution over the components names of fn applied to each outcome of
+// j, such as the net successes of a roll counting successes and failures separately
+func (j *Joint) Map(names []string, fn func(o JointOutcome) []int) *Joint {
+	out := NewJoint(names...)
+
+	for _, c := range j.cells {
+		out.AddOutcome(c.p, fn(JointOutcome{names: j.names, Values: c.values})...)
+	}
+
+	return out
+}
+
+// Add returns the joint distribution of the component-wise sum of independent outcomes drawn
+// from j and o, which must have the same components
+func (j *Joint) Add(o *Joint) (*Joint, error) {
+	if strings.Join(j.names, ",") != strings.Join(o.names, ",") {
+		return nil, fmt.Errorf("can't add joint distributions of %s and %s", strings.Join(j.names, ", "), strings.Join(o.names, ", "))
+	}
+
+	return j.add(o), nil
+}
+
+func (j *Joint) add(o *Joint) *Joint {
+	out := NewJoint(j.names...)
+	values := make([]int, len(j.names))
+
+	for _, a := range j.cells {
+		for _, b := range o.cells {
+			for i := range values {
+				values[i] = a.values[i] + b.values[i]
+			}
+			out.AddOutcome(a.p*b.p, values...)
+		}
+	}
+
+	return out
+}
+
+// addWithin is add, returning ErrInexact instead if it would combine more than max pairs of
+// outcomes. A max of 0 is no limit.
+func (j *Joint) addWithin(o *Joint, max int) (*Joint, error) {
+	if max > 0 && float64(len(j.cells))*float64(len(o.cells)) > float64(max) {
+		return nil, ErrInexact
+	}
+
+	return j.add(o), nil
+}
+
+// Repeat returns the joint distribution of the component-wise sum of n independent outcomes
+// drawn from j
+func (j *Joint) Repeat(n int) *Joint {
+	out, _ := j.repeat(n, 0)
+	return out
+}
+
+// repeat is Repeat with each addition made within max, see addWithin
+func (j *Joint) repeat(n, max int) (*Joint, error) {
+	out := NewJoint(j.names...)
+	out.AddOutcome(1, make([]int, len(j.names))...)
+
+	var err error
+	for sq := j; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			if out, err = out.addWithin(sq, max); err != nil {
+				return nil, err
+			}
+		}
+		if n > 1 {
+			if sq, err = sq.addWithin(sq, max); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// DieJoint returns the joint distribution over the components names of fn applied to a single
+// roll of d. Pools whose components are sums over their dice, such as counts of symbols, can be
+// built from it with Repeat and Add.
+func DieJoint(d Die, names []string, fn func(f Face) []int) *Joint {
+	out := NewJoint(names...)
+
+	for i, f := range d.faces {
+		out.AddOutcome(d.weight(i), fn(f)...)
+	}
+
+	return out.Normalize()
+}
+
+// JointOf returns the exact joint distribution over the components names of fn applied to every
+// roll of pools. fn is passed the faces rolled by each Dice of pools, in order, and the faces of
+// each are sorted by N so a pool is enumerated once per combination of faces rather than once
+// per ordering. Put dice that must be told apart, such as the dragon die of Dragon Age, in a
+// Dice of their own. ErrInexact is returned if there are too many combinations to enumerate.
+func JointOf(names []string, pools Set, fn func(rolls []Faces) []int) (*Joint, error) {
+	var (
+		kinds = make([][]Face, len(pools))
+		probs = make([][]float64, len(pools))
+		count = 1.
+	)
+	for i, d := range pools {
+		kinds[i], probs[i] = faceKinds(d.Die)
+		if count *= multisets(d.N, len(kinds[i])); count > maxMultisets {
+			return nil, ErrInexact
+		}
+	}
+
+	var (
+		out   = NewJoint(names...)
+		rolls = make([]Faces, len(pools))
+		err   error
+		pool  func(i int, p float64)
+	)
+
+	// pool enumerates the rolls of pools[i] and the pools after it
+	pool = func(i int, p float64) {
+		if err != nil {
+			return
+		}
+		if i == len(pools) {
+			values := fn(rolls)
+			if len(values) != len(names) {
+				err = fmt.Errorf("joint function returned %d values for %d components", len(values), len(names))
+				return
+			}
+			out.AddOutcome(p, values...)
+			return
+		}
+
+		n := pools[i].N
+		if n <= 0 || len(kinds[i]) == 0 {
+			rolls[i] = nil
+			pool(i+1, p)
+			return
+		}
+
+		var (
+			lgN, _ = math.Lgamma(float64(n + 1))
+			visit  func(k, left int, logP float64)
+		)
+
+		// visit chooses how many of kinds[i][k] are rolled, keeping rolls[i] sorted
+		rolls[i] = rolls[i][:0]
+		visit = func(k, left int, logP float64) {
+			if k == len(kinds[i])-1 {
+				lg, _ := math.Lgamma(float64(left + 1))
+				logP += float64(left)*math.Log(probs[i][k]) - lg
+				for j := 0; j < left; j++ {
+					rolls[i] = append(rolls[i], kinds[i][k])
+				}
+				pool(i+1, p*math.Exp(lgN+logP))
+
+				rolls[i] = rolls[i][:len(rolls[i])-left]
+				return
+			}
+
+			lp := math.Log(probs[i][k])
+			for c := 0; c <= left; c++ {
+				lg, _ := math.Lgamma(float64(c + 1))
+				for j := 0; j < c; j++ {
+					rolls[i] = append(rolls[i], kinds[i][k])
+				}
+				visit(k+1, left-c, logP+float64(c)*lp-lg)
+				rolls[i] = rolls[i][:len(rolls[i])-c]
+			}
+		}
+		visit(0, n, 0)
+	}
+	pool(0, 1)
+
+	return out, err
+}
+
+// faceKinds returns the distinct faces of d, sorted by N, and the probability of each
+func faceKinds(d Die) ([]Face, []float64) {
+	var (
+		kinds []Face
+		probs []float64
+		index = make(map[Face]int)
+		total = 0.
+	)
+
+	for i, f := range d.faces {
+		w := d.weight(i)
+		if w <= 0 {
+			continue
+		}
+		total += w
+
+		k, ok := index[f]
+		if !ok {
+			k = len(kinds)
+			index[f] = k
+			kinds = append(kinds, f)
+			probs = append(probs, 0)
+		}
+		probs[k] += w
+	}
+
+	for k := range probs {
+		probs[k] /= total
+	}
+
+	return kinds, probs
+}
@@ -0,0 +1,211 @@
+package roll
+
+import (
+	"math"
+	"testing"
+)
+
+// parity is a d6 read as its value and whether it's even
+func parity() *Joint {
+	return DieJoint(D6, []string{"value", "even"}, func(f Face) []int {
+		return []int{f.N, 1 - f.N%2}
+	})
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestJoint(t *testing.T) {
+	j := parity()
+
+	if !near(j.Total(), 1) || !near(j.P(4, 1), 1./6) || j.P(4, 0) != 0 {
+		t.Errorf("got total %v, P(4, even) %v and P(4, odd) %v", j.Total(), j.P(4, 1), j.P(4, 0))
+	}
+
+	m, err := j.Marginal("even")
+	if err != nil || !near(m[0], 0.5) || !near(m[1], 0.5) {
+		t.Errorf("Marginal: got %v, %v", m, err)
+	}
+	if _, err := j.Marginal("odd"); err == nil {
+		t.Errorf("Marginal of an unknown component should be an error")
+	}
+
+	p, err := j.Project("even", "value")
+	if err != nil || !near(p.P(1, 6), 1./6) || len(p.Outcomes()) != 6 {
+		t.Errorf("Project: got %v, %v", p, err)
+	}
+	if _, err := j.Project("value", "odd"); err == nil {
+		t.Errorf("Project of an unknown component should be an error")
+	}
+
+	if got := j.PFunc(func(o JointOutcome) bool { return o.Get("value") >= 5 && o.Get("even") == 1 }); !near(got, 1./6) {
+		t.Errorf("PFunc: got %v, want 1/6", got)
+	}
+
+	outs := j.Outcomes()
+	for i, o := range outs {
+		if o.Values[0] != i+1 {
+			t.Errorf("Outcomes: got %v at %d, want them in order", o.Values, i)
+		}
+	}
+
+	sum := j.Map([]string{"sum"}, func(o JointOutcome) []int { return []int{o.Get("value") + o.Get("even")} })
+	if d, _ := sum.Marginal("sum"); !near(d.Mean(), 4) {
+		t.Errorf("Map: got mean %v, want 4", d.Mean())
+	}
+
+	half := NewJoint("x")
+	half.AddOutcome(1, 1)
+	half.AddOutcome(3, 2)
+	if n := half.Normalize(); !near(n.P(2), 0.75) || !near(half.P(2), 3) {
+		t.Errorf("Normalize: got %v, leaving %v", n.P(2), half.P(2))
+	}
+}
+
+func TestJointAdd(t *testing.T) {
+	j := parity()
+
+	two, err := j.Add(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(two.Total(), 1) || !near(two.P(12, 2), 1./36) || !near(two.P(7, 1), 6./36) {
+		t.Errorf("Add: got total %v, P(12, 2) %v, P(7, 1) %v", two.Total(), two.P(12, 2), two.P(7, 1))
+	}
+
+	if _, err := j.Add(NewJoint("value")); err == nil {
+		t.Errorf("adding joints of different components should be an error")
+	}
+
+	// Repeat is repeated Add, and its marginal is the sum of the dice
+	three, _ := two.Add(j)
+	rep := j.Repeat(3)
+	for _, o := range three.Outcomes() {
+		if !near(rep.P(o.Values...), three.P(o.Values...)) {
+			t.Errorf("Repeat: P(%v) = %v, want %v", o.Values, rep.P(o.Values...), three.P(o.Values...))
+		}
+	}
+	if d, _ := rep.Marginal("value"); !near(d.Mean(), 10.5) || !near(d[18], 1./216) {
+		t.Errorf("Repeat: got mean %v and P(18) %v", d.Mean(), d[18])
+	}
+	if z := j.Repeat(0); !near(z.P(0, 0), 1) {
+		t.Errorf("Repeat(0): got %v", z.Outcomes())
+	}
+}
+
+func TestJointOf(t *testing.T) {
+	// The highest and lowest of 2d6
+	j, err := JointOf([]string{"high", "low"}, Set{{N: 2, Die: D6}}, func(rolls []Faces) []int {
+		return []int{rolls[0][1].N, rolls[0][0].N}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(j.Total(), 1) || !near(j.P(6, 6), 1./36) || !near(j.P(6, 1), 2./36) || j.P(1, 6) != 0 {
+		t.Errorf("got P(6, 6) %v, P(6, 1) %v, P(1, 6) %v", j.P(6, 6), j.P(6, 1), j.P(1, 6))
+	}
+
+	if _, err := JointOf([]string{"x"}, Set{{N: 100, Die: D20}}, func([]Faces) []int { return []int{0} }); err != ErrInexact {
+		t.Errorf("got %v for a pool too large to enumerate, want ErrInexact", err)
+	}
+	if _, err := JointOf([]string{"x", "y"}, Set{{N: 1, Die: D6}}, func([]Faces) []int { return []int{0} }); err == nil {
+		t.Errorf("a function returning too few values should be an error")
+	}
+}
+
+func TestGenesys(t *testing.T) {
+	j, err := Genesys("2a1p2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Successes per die: ability 5/8, proficiency 10/12 with its triumph, difficulty -4/8
+	s, _ := j.Marginal("success")
+	if !near(s.Mean(), 13./12) {
+		t.Errorf("success mean %v, want 13/12", s.Mean())
+	}
+	if tr, _ := j.Marginal("triumph"); !near(tr[1], 1./12) {
+		t.Errorf("P(triumph) = %v, want 1/12", tr[1])
+	}
+	if d, _ := j.Marginal("despair"); !near(d[0], 1) {
+		t.Errorf("P(no despair) = %v, want 1", d[0])
+	}
+
+	// A single boost die: success on 3 and 4, advantage on 4, 5 (twice) and 6
+	b, _ := Genesys("b")
+	if !near(b.P(1, 1, 0, 0), 1./6) || !near(b.P(0, 2, 0, 0), 1./6) || !near(b.P(0, 0, 0, 0), 2./6) {
+		t.Errorf("boost: got %v", b.Outcomes())
+	}
+
+	for _, pool := range []string{"", "2x", "a2"} {
+		if _, err := Genesys(pool); err == nil || err == ErrInexact {
+			t.Errorf("%q: got %v, want an invalid pool error", pool, err)
+		}
+	}
+	if _, err := Genesys("100a100d"); err != ErrInexact {
+		t.Errorf("100a100d: got %v, want ErrInexact", err)
+	}
+}
+
+func TestDragonAge(t *testing.T) {
+	j, err := DragonAge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two of 3d6 match with probability 1 - 6*5*4/216, and the dragon die is independent of
+	// whether they do
+	stunt, _ := j.Marginal("stunt")
+	if !near(stunt[0], 120./216) {
+		t.Errorf("P(no stunt) = %v, want 120/216", stunt[0])
+	}
+	for v := 1; v <= 6; v++ {
+		if !near(stunt[v], 16./216) {
+			t.Errorf("P(%d stunt points) = %v, want 16/216", v, stunt[v])
+		}
+	}
+
+	if total, _ := j.Marginal("total"); !near(total.Mean(), 10.5) {
+		t.Errorf("total mean %v, want 10.5", total.Mean())
+	}
+	// 6, 6, 6 is the only roll of 18, with 6 stunt points
+	if !near(j.P(18, 6), 1./216) || !near(j.P(3, 1), 1./216) {
+		t.Errorf("P(18, 6) = %v, P(3, 1) = %v, want 1/216", j.P(18, 6), j.P(3, 1))
+	}
+}
+
+func TestORE(t *testing.T) {
+	j, err := ORE(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, _ := j.Marginal("width")
+	if !near(w[0], 0.72) || !near(w[2], 0.27) || !near(w[3], 0.01) {
+		t.Errorf("widths %v, want 0.72, 0.27 and 0.01", w)
+	}
+	if !near(j.P(0, 0), 0.72) || !near(j.P(3, 10), 0.001) || !near(j.P(2, 10), 0.027) {
+		t.Errorf("P(0, 0) %v, P(3, 10) %v, P(2, 10) %v", j.P(0, 0), j.P(3, 10), j.P(2, 10))
+	}
+
+	// With four dice two pairs are read as the higher pair: a pair of 10s is 6*9*8 rolls with
+	// two other faces and 6*9 with another pair, while a pair of 1s is never the higher
+	four, _ := ORE(4)
+	if !near(four.P(2, 10), 486./10000) || !near(four.P(2, 1), 432./10000) || !near(four.P(4, 5), 1./10000) {
+		t.Errorf("P(2, 10) %v, P(2, 1) %v, P(4, 5) %v", four.P(2, 10), four.P(2, 1), four.P(4, 5))
+	}
+}
+
+func TestParseJoint(t *testing.T) {
+	for _, spec := range []string{"genesys:2a1p2d", "dragonage", "ore:6", "ORE:2"} {
+		if j, err := ParseJoint(spec); err != nil || !near(j.Total(), 1) {
+			t.Errorf("%s: got %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"genesys:zz", "dragonage:3", "ore:0", "ore", "fate"} {
+		if _, err := ParseJoint(spec); err == nil {
+			t.Errorf("%s: want an error", spec)
+		}
+	}
+}
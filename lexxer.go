@@ -4,136 +4,489 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
-	"strings"
 )
 
 var (
-	lexDice    = regexp.MustCompile(`\d+d\d+`)
-	lexKeep    = regexp.MustCompile(`K(l|h)\d+`)
-	lexKeepN   = regexp.MustCompile(`Kn[\d,]+`)
-	lexDrop    = regexp.MustCompile(`D(l|h)\d+`)
-	lexDropN   = regexp.MustCompile(`Dn[\d,]+`)
-	lexExp     = regexp.MustCompile(`X[\d,]+`)
-	lexNum     = regexp.MustCompile(`\d+`)
-	lexMatcher = regexp.MustCompile(fmt.Sprintf("(%s|%s|%s|%s|%s|%s)", lexDice, lexKeep, lexKeepN, lexDrop, lexDropN, lexExp))
+	lexKeep   = regexp.MustCompile(`K(l|h|m|f|e)\d+`)
+	lexKeepN  = regexp.MustCompile(`Kn[\d,]+`)
+	lexDrop   = regexp.MustCompile(`D(l|h|m|f|e)\d+`)
+	lexDropN  = regexp.MustCompile(`Dn[\d,]+`)
+	lexExp    = regexp.MustCompile(`X[\d,]+`)
+	lexReroll = regexp.MustCompile(`Ro?[\d,]+`)
+	lexNum    = regexp.MustCompile(`\d+`)
+
+	// Anchored patterns used by the expression scanner
+	scanNum   = regexp.MustCompile(`^\d+`)
+	scanVar   = regexp.MustCompile(`^\$[A-Za-z_][A-Za-z0-9_]*`)
+	scanMod   = regexp.MustCompile(fmt.Sprintf("^(%s|%s|%s|%s|%s|%s)", lexKeep, lexKeepN, lexDrop, lexDropN, lexExp, lexReroll))
+	scanOp    = regexp.MustCompile(`^(>=|<=|==|!=|[-+*()<>?:])`)
+	scanBands = regexp.MustCompile(`^\[[^\]]*\]`)
 )
 
 /*FromString reads a dice string like 3d6X6Kh2: roll 3 6 sided dice, exploding 6s, and keep the lowest 2, and returns a Result struct
 FromString will return an error containing any unparsed characters which can be used to check syntax and troubleshoot dice strings.
+FromString only accepts a single dice term, use Parse for expressions that add terms and modifiers together.
 FromString does some minimal checking of input:
 	* Comma separated number lists (explode, dropN, keepN etc) are filtered to remove duplicate numbers and an error will be raised if the number of arguments exceeds or equals the faces of the die as this likely means that it will match all items.
 	* Keep/Drop operations will return an error if the
 */
 func FromString(s string) (Result, error) {
-	var roll Result
+	e, err := Parse(s)
+	if err != nil {
+		return Result{}, err
+	}
+
+	d, ok := e.root.(*diceNode)
+	if !ok {
+		return Result{}, parseErr(NotDiceTerm, e.src, 0, len(e.src), "not a single dice term, use Parse for expressions")
+	}
+
+	ev := &evaluator{limits: e.limits}
+	r := d.roll(ev)
 
-	// Remove leading/trailing spaces
-	s = strings.TrimSpace(s)
+	return r, ev.err
+}
+
+// token kinds produced by the scanner
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNum
+	tokVar
+	tokDice
+	tokMod
+	tokOp
+	tokBands
+)
 
-	// Get indices
-	for i, op := range lexMatcher.FindAllString(s, -1) {
-		if i == 0 && !lexDice.MatchString(op) {
-			return roll, fmt.Errorf("%s: first operation must be a dice string (3d6 etc)", op)
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// scan splits s into tokens. Whitespace between tokens is ignored.
+func scan(s string) ([]token, error) {
+	var toks []token
+
+	for pos := 0; pos < len(s); {
+		if s[pos] == ' ' || s[pos] == '\t' {
+			pos++
+			continue
 		}
 
+		rest := s[pos:]
 		switch {
-		case lexDice.MatchString(op):
-			n, die := parseDie(op)
-			if n == 0 || die.faces.Len() == 0 || die.faces.Len() == 1 {
-				return roll, fmt.Errorf("non-euclidean die: %s", op)
-			}
-
-			roll = Roll(n, die)
-
-		case lexKeep.MatchString(op):
-			k, m := parseKeep(op)
-			if k < 1 {
-				return roll, fmt.Errorf("cannot keep a negative quantity of dice: %s", op)
-			}
-			roll = roll.Keep(k, m)
-
-		case lexKeepN.MatchString(op):
-			n := parseComSepN(op)
-			if len(n) >= len(roll.die.faces) {
-				return roll, fmt.Errorf("numbers kept equals or exceeds faces of die")
-			}
-			roll = roll.KeepN(n...)
-
-		case lexDrop.MatchString(op):
-			d, m := parseDrop(op)
-			if d > roll.rolls.Len() {
-				return roll, fmt.Errorf("cannot drop more dice than rolled: %s", op)
-			}
-			roll = roll.Drop(d, m)
-
-		case lexDropN.MatchString(op):
-			n := parseComSepN(op)
-			if len(n) >= len(roll.die.faces) {
-				return roll, fmt.Errorf("numbers dropped equals or exceeds faces of die")
-			}
-			roll = roll.DropN(parseComSepN(op)...)
-
-		case lexExp.MatchString(op):
-			n := parseComSepN(op)
-			if len(n) >= len(roll.die.faces) {
-				return roll, fmt.Errorf("numbers exploded equals or exceeds faces of die")
-			}
-			roll = roll.Explode(n...)
-
+		case scanNum.MatchString(rest):
+			toks = append(toks, token{tokNum, scanNum.FindString(rest), pos})
+		case scanVar.MatchString(rest):
+			toks = append(toks, token{tokVar, scanVar.FindString(rest), pos})
+		case rest[0] == 'd':
+			toks = append(toks, token{tokDice, "d", pos})
+		case scanMod.MatchString(rest):
+			toks = append(toks, token{tokMod, scanMod.FindString(rest), pos})
+		case scanOp.MatchString(rest):
+			toks = append(toks, token{tokOp, scanOp.FindString(rest), pos})
+		case scanBands.MatchString(rest):
+			toks = append(toks, token{tokBands, scanBands.FindString(rest), pos})
 		default:
-			return roll, fmt.Errorf("invalid operation: %s", op)
+			return nil, parseErr(SyntaxError, s, pos, len(s), "unparsed characters: %s", rest)
+		}
+
+		pos += len(toks[len(toks)-1].text)
+	}
+
+	return append(toks, token{tokEOF, "", len(s)}), nil
+}
+
+// parser is a recursive descent parser over the output of scan
+type parser struct {
+	src    string
+	toks   []token
+	pos    int
+	limits Limits
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// errorf returns a *ParseError of kind for the span of token t
+func (p *parser) errorf(kind ParseErrorKind, t token, format string, args ...interface{}) error {
+	end := t.pos + len(t.text)
+	if t.kind == tokEOF {
+		end = t.pos + 1
+	}
+
+	return parseErr(kind, p.src, t.pos, end, format, args...)
+}
+
+// end returns the position following the last token consumed
+func (p *parser) end() int {
+	if p.pos == 0 {
+		return 0
+	}
+
+	t := p.toks[p.pos-1]
+	return t.pos + len(t.text)
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			return true
 		}
+	}
+
+	return false
+}
+
+// cond := compare ['?' cond ':' cond]
+func (p *parser) parseCond() (node, error) {
+	c, err := p.parseCompare()
+	if err != nil || !p.isOp("?") {
+		return c, err
+	}
+	p.next()
+
+	t, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isOp(":") {
+		return nil, p.errorf(Unbalanced, p.peek(), "missing :")
+	}
+	p.next()
+
+	f, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+
+	return &condNode{cond: c, t: t, f: f}, nil
+}
 
-		// Remmove parsed op from s
-		s = strings.Replace(s, op, "", 1)
+// compare := sum [('>='|'<='|'>'|'<'|'=='|'!=') sum]
+func (p *parser) parseCompare() (node, error) {
+	l, err := p.parseSum()
+	if err != nil || !p.isOp(">=", "<=", ">", "<", "==", "!=") {
+		return l, err
 	}
+	op := p.next().text
 
-	if len(s) > 0 {
-		return roll, fmt.Errorf("unparsed characters: %s", s)
+	r, err := p.parseSum()
+	if err != nil {
+		return nil, err
 	}
 
-	return roll, nil
+	return &cmpNode{op: op, l: l, r: r}, nil
 }
 
-func parseDie(s string) (int, Die) {
-	n, f := 0, 0
-	fmt.Sscanf(s, "%dd%d", &n, &f)
-	return n, Die{faces: makeFaces(f)}
+// sum := product (('+'|'-') product)*
+func (p *parser) parseSum() (node, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("+", "-") {
+		op := p.next().text
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+
+	return l, nil
 }
 
-func parseKeep(s string) (int, MatchType) {
-	m, n := LOW, 0
+// product := unary ('*' unary)*
+func (p *parser) parseProduct() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
 
-	if strings.Contains(s, "l") {
-		fmt.Sscanf(s, "Kl%d", &n)
-	} else {
-		m = HIGH
-		fmt.Sscanf(s, "Kh%d", &n)
+	for p.isOp("*") {
+		op := p.next().text
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
 	}
 
-	return n, m
+	return l, nil
 }
 
-func parseDrop(s string) (int, MatchType) {
-	m, n := LOW, 0
+// unary := '-' unary | dice
+func (p *parser) parseUnary() (node, error) {
+	// Every level of nesting passes through here, starting from depth 0
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := exceeds("MaxDepth", p.limits.MaxDepth, p.depth-1); err != nil {
+		t := p.peek()
+		return nil, spanErr(err, LimitExceeded, p.src, t.pos, t.pos+len(t.text))
+	}
 
-	if strings.Contains(s, "l") {
-		fmt.Sscanf(s, "Dl%d", &n)
-	} else {
-		m = HIGH
-		fmt.Sscanf(s, "Dh%d", &n)
+	if p.isOp("-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{x: x}, nil
 	}
 
-	return n, m
+	return p.parseDice()
 }
 
-func parseComSepN(s string) []int {
+// dice := primary ['d' primary modifier*]
+func (p *parser) parseDice() (node, error) {
+	start := p.peek().pos
+	count, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokDice {
+		if t := p.peek(); t.kind == tokMod {
+			return nil, p.errorf(InvalidModifier, t, "%s: modifiers must follow a dice string (3d6 etc)", t.text)
+		}
+		return count, nil
+	}
+	p.next()
+
+	sides, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	if c, ok := count.(numNode); ok {
+		if err := exceeds("MaxDice", p.limits.MaxDice, int(c)); err != nil {
+			return nil, spanErr(err, LimitExceeded, p.src, start, p.end())
+		}
+		n = int(c)
+	}
+
+	d := &diceNode{count: count, sides: sides}
+	if c, ok := sides.(numNode); ok {
+		if err := exceeds("MaxSides", p.limits.MaxSides, int(c)); err != nil {
+			return nil, spanErr(err, LimitExceeded, p.src, start, p.end())
+		}
+		d.die = NewDie(makeFaces(int(c)))
+	}
+
+	var toks []token
+	for p.peek().kind == tokMod {
+		t := p.next()
+		m, err := parseModifier(t.text)
+		if err != nil {
+			return nil, p.errorf(InvalidModifier, t, "%v", err)
+		}
+		d.mods = append(d.mods, m)
+		toks = append(toks, t)
+	}
+
+	if err := d.check(); err != nil {
+		return nil, spanErr(err, InvalidDie, p.src, start, p.end())
+	}
+	for i, m := range d.mods {
+		if err := m.check(n, len(d.die.faces)); err != nil {
+			return nil, p.errorf(InvalidModifier, toks[i], "%v", err)
+		}
+	}
+
+	return d, nil
+}
+
+// primary := number | variable | '(' cond ')'
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch {
+	case t.kind == tokNum:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, p.errorf(InvalidNumber, t, "%s: number out of range", t.text)
+		}
+		return numNode(n), nil
+
+	case t.kind == tokVar:
+		return varNode(t.text[1:]), nil
+
+	case t.kind == tokOp && t.text == "(":
+		x, err := p.parseCond()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf(Unbalanced, p.peek(), "missing )")
+		}
+		p.next()
+		return &groupNode{x: x}, nil
+
+	case t.kind == tokEOF:
+		return nil, p.errorf(UnexpectedEnd, t, "unexpected end of dice string")
+
+	default:
+		return nil, p.errorf(SyntaxError, t, "unexpected %s", t.text)
+	}
+}
+
+// modifier kinds applied to the Result of a dice term
+type modKind int
+
+const (
+	modKeep modKind = iota
+	modKeepN
+	modDrop
+	modDropN
+	modExplode
+	modReroll
+	modRerollOnce
+)
+
+// modifier is a single parsed operation such as Kh2 or X6,10
+type modifier struct {
+	kind  modKind
+	n     int
+	hl    MatchType
+	match []int
+	src   string
+}
+
+func parseModifier(s string) (modifier, error) {
+	var (
+		m   = modifier{src: s}
+		err error
+	)
+
+	switch {
+	case lexKeep.MatchString(s):
+		m.kind = modKeep
+		if m.n, m.hl, err = parseSelect(s); err != nil {
+			return m, err
+		}
+		if m.n < 1 {
+			return m, fmt.Errorf("cannot keep a negative quantity of dice: %s", s)
+		}
+
+	case lexKeepN.MatchString(s):
+		m.kind = modKeepN
+		m.match, err = parseComSepN(s)
+
+	case lexDrop.MatchString(s):
+		m.kind = modDrop
+		m.n, m.hl, err = parseSelect(s)
+
+	case lexDropN.MatchString(s):
+		m.kind = modDropN
+		m.match, err = parseComSepN(s)
+
+	case lexExp.MatchString(s):
+		m.kind = modExplode
+		m.match, err = parseComSepN(s)
+
+	case lexReroll.MatchString(s):
+		m.kind = modReroll
+		if s[1] == 'o' {
+			m.kind = modRerollOnce
+		}
+		m.match, err = parseComSepN(s)
+
+	default:
+		return m, fmt.Errorf("invalid operation: %s", s)
+	}
+
+	return m, err
+}
+
+// apply the modifier to r
+func (m modifier) apply(r Result) Result {
+	switch m.kind {
+	case modKeep:
+		return r.Keep(m.n, m.hl)
+	case modKeepN:
+		return r.KeepN(m.match...)
+	case modDrop:
+		return r.Drop(m.n, m.hl)
+	case modDropN:
+		return r.DropN(m.match...)
+	case modExplode:
+		return r.Explode(m.match...)
+	case modReroll:
+		return r.RerollN(m.match...)
+	case modRerollOnce:
+		return r.RerollOnceN(m.match...)
+	}
+
+	return r
+}
+
+// check the modifier against the number of dice and faces of the term it belongs to, where
+// those are known before rolling. A value of 0 means unknown.
+func (m modifier) check(n, faces int) error {
+	switch m.kind {
+	case modKeepN, modDropN, modExplode, modReroll, modRerollOnce:
+		if faces > 0 && len(m.match) >= faces {
+			verb := map[modKind]string{modKeepN: "kept", modDropN: "dropped", modExplode: "exploded", modReroll: "rerolled", modRerollOnce: "rerolled"}[m.kind]
+			return fmt.Errorf("numbers %s equals or exceeds faces of die", verb)
+		}
+	case modDrop:
+		if n > 0 && m.n > n {
+			return fmt.Errorf("cannot drop more dice than rolled: %s", m.src)
+		}
+	}
+
+	return nil
+}
+
+// selectors maps the letter following K or D to the MatchType it selects by
+var selectors = map[byte]MatchType{
+	'h': HIGH,
+	'l': LOW,
+	'm': MIDDLE,
+	'f': FIRST,
+	'e': LAST,
+}
+
+// parseSelect reads the number and MatchType of a Keep or Drop such as Kh2 or Dl1
+func parseSelect(s string) (int, MatchType, error) {
+	n, err := strconv.Atoi(s[2:])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: invalid number of dice", s)
+	}
+
+	return n, selectors[s[1]], nil
+}
+
+func parseComSepN(s string) ([]int, error) {
 	m, check := []int{}, make(map[int]int)
 
 	for _, tok := range lexNum.FindAllString(s, -1) {
 		n, err := strconv.Atoi(tok)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("%s: invalid number %s", s, tok)
 		}
 
 		check[n]++ // Only count each number once
@@ -142,5 +495,5 @@ func parseComSepN(s string) []int {
 		}
 	}
 
-	return m
+	return m, nil
 }
@@ -0,0 +1,370 @@
+package roll
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limits bound the work done parsing and evaluating an expression, so that dice strings from
+// untrusted users such as chat commands can't exhaust memory or time. A zero field is
+// unlimited.
+type Limits struct {
+	MaxLength       int // characters in the dice string
+	MaxDepth        int // nesting of parentheses, negation and ternaries
+	MaxDice         int // dice rolled by a single term, including explosions
+	MaxSides        int // sides of a single die
+	MaxTotalDice    int // dice rolled by a whole evaluation, including explosions
+	MaxExplodeDepth int // explosions of explosions, or rerolls, of a single die
+}
+
+// DefaultLimits are applied by Parse. They're generous enough for any real game but stop
+// pathological strings such as 999999999d999999999.
+var DefaultLimits = Limits{
+	MaxLength:       10000,
+	MaxDepth:        100,
+	MaxDice:         10000,
+	MaxSides:        100000,
+	MaxTotalDice:    100000,
+	MaxExplodeDepth: 100,
+}
+
+// LimitError is returned when parsing or evaluating an expression would exceed one of its
+// Limits. Limit is the name of the Limits field.
+type LimitError struct {
+	Limit    string
+	Max, Got int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("dice string exceeds %s: %d > %d", e.Limit, e.Got, e.Max)
+}
+
+// exceeds returns a *LimitError if got is over a non-zero max
+func exceeds(limit string, max, got int) error {
+	if max > 0 && got > max {
+		return &LimitError{Limit: limit, Max: max, Got: got}
+	}
+
+	return nil
+}
+
+// Cost is an upper bound on the work of evaluating an expression, computed without rolling
+// any dice. Dice counts both branches of a ternary and excludes explosions, which are bounded
+// by MaxExplodeDepth and MaxDice as the dice are rolled. A count that can explode, such as
+// (1d6X6)d6, is bounded by MaxExplodeDepth, or unbounded without it.
+type Cost struct {
+	Length   int  // characters in the dice string
+	Depth    int  // deepest nesting
+	Terms    int  // dice terms
+	Dice     int  // most dice rolled, before explosions
+	MaxSides int  // sides of the largest die
+	Explodes bool // whether any term explodes
+}
+
+// Check returns a *LimitError if c exceeds any of l
+func (l Limits) Check(c Cost) error {
+	for _, err := range []error{
+		exceeds("MaxLength", l.MaxLength, c.Length),
+		exceeds("MaxDepth", l.MaxDepth, c.Depth),
+		exceeds("MaxSides", l.MaxSides, c.MaxSides),
+		exceeds("MaxTotalDice", l.MaxTotalDice, c.Dice),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseWithLimits is Parse with the given limits in place of DefaultLimits. Length, nesting and
+// literal dice counts and sides are checked while parsing; everything else when the expression
+// is evaluated. Limits{} parses and evaluates without limits.
+func ParseWithLimits(s string, l Limits) (*Expr, error) {
+	return parse(s, l)
+}
+
+// WithLimits returns a copy of the expression that is evaluated within l
+func (e *Expr) WithLimits(l Limits) *Expr {
+	out := *e
+	out.limits = l
+	return &out
+}
+
+// Limits returns the limits the expression is evaluated within
+func (e *Expr) Limits() Limits {
+	return e.limits
+}
+
+// EvalContext is Eval that stops with ctx's error if ctx is done before the expression has
+// been evaluated, i.e from context.WithTimeout. The expression's Cost is checked against its
+// Limits before any dice are rolled and a *LimitError is returned if a limit is exceeded. If
+// the expression's Source is a Manual whose entries failed its Err is returned.
+func (e *Expr) EvalContext(ctx context.Context, vars Vars) (Outcome, error) {
+	for _, name := range e.Vars() {
+		if _, ok := vars[name]; !ok {
+			return Outcome{}, &MissingVariableError{Name: name}
+		}
+	}
+
+	if err := e.limits.Check(e.Cost(vars)); err != nil {
+		return Outcome{}, err
+	}
+
+	ev := &evaluator{vars: vars, source: e.source, limits: e.limits, ctx: ctx}
+	o := e.eval(ev)
+	if ev.err == nil {
+		ev.err = sourceErr(e.source)
+	}
+	if ev.err != nil {
+		return Outcome{}, ev.err
+	}
+
+	return o, nil
+}
+
+// Cost returns an upper bound on the work of evaluating the expression with vars
+func (e *Expr) Cost(vars Vars) Cost {
+	c := Cost{Length: len(e.src)}
+
+	var depth func(n node) int
+	depth = func(n node) int {
+		max := 0
+		walkChildren(n, func(x node) {
+			if d := depth(x); d > max {
+				max = d
+			}
+		})
+
+		switch n.(type) {
+		case *groupNode, *negNode, *condNode:
+			return max + 1
+		}
+		return max
+	}
+	c.Depth = depth(e.root)
+
+	walk(e.root, func(n node) {
+		d, ok := n.(*diceNode)
+		if !ok {
+			return
+		}
+
+		c.Terms++
+		_, count := bounds(d.count, vars, e.limits.MaxExplodeDepth)
+		if count > 0 {
+			c.Dice = satAdd(c.Dice, count)
+		}
+
+		sides := len(d.die.faces)
+		if d.die.faces == nil {
+			_, sides = bounds(d.sides, vars, e.limits.MaxExplodeDepth)
+		}
+		if sides > c.MaxSides {
+			c.MaxSides = sides
+		}
+
+		for _, m := range d.mods {
+			if m.kind == modExplode {
+				c.Explodes = true
+			}
+		}
+	})
+
+	return c
+}
+
+// maxBound stands in for values too large to matter when bounding costs. It's small enough that
+// the sum of two bounds fits in an int on 32-bit platforms.
+const maxBound = 1<<30 - 1
+
+// satAdd adds a and b, which must be within ±maxBound, saturating at ±maxBound
+func satAdd(a, b int) int {
+	if a+b > maxBound {
+		return maxBound
+	}
+	if a+b < -maxBound {
+		return -maxBound
+	}
+
+	return a + b
+}
+
+func satMul(a, b int) int {
+	switch {
+	case a == 0 || b == 0:
+		return 0
+	case a > maxBound/abs(b) || a < -maxBound/abs(b):
+		if (a > 0) == (b > 0) {
+			return maxBound
+		}
+		return -maxBound
+	}
+
+	return a * b
+}
+
+func clamp(n int) int {
+	return satAdd(0, n)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// bounds returns the lowest and highest values n can evaluate to when a die can explode at most
+// depth times, or any number of times if depth is 0
+func bounds(n node, vars Vars, depth int) (int, int) {
+	switch n := n.(type) {
+	case numNode:
+		return clamp(int(n)), clamp(int(n))
+
+	case varNode:
+		return clamp(vars[string(n)]), clamp(vars[string(n)])
+
+	case *groupNode:
+		return bounds(n.x, vars, depth)
+
+	case *negNode:
+		lo, hi := bounds(n.x, vars, depth)
+		return -hi, -lo
+
+	case *binaryNode:
+		llo, lhi := bounds(n.l, vars, depth)
+		rlo, rhi := bounds(n.r, vars, depth)
+		switch n.op {
+		case "+":
+			return satAdd(llo, rlo), satAdd(lhi, rhi)
+		case "-":
+			return satAdd(llo, -rhi), satAdd(lhi, -rlo)
+		}
+
+		lo, hi := maxBound, -maxBound
+		for _, a := range []int{llo, lhi} {
+			for _, b := range []int{rlo, rhi} {
+				p := satMul(a, b)
+				if p < lo {
+					lo = p
+				}
+				if p > hi {
+					hi = p
+				}
+			}
+		}
+		return lo, hi
+
+	case *cmpNode:
+		return 0, 1
+
+	case *condNode:
+		tlo, thi := bounds(n.t, vars, depth)
+		flo, fhi := bounds(n.f, vars, depth)
+		if flo < tlo {
+			tlo = flo
+		}
+		if fhi > thi {
+			thi = fhi
+		}
+		return tlo, thi
+
+	case *diceNode:
+		// kLo and kHi are the fewest and most dice left after each modifier
+		kLo, kHi := bounds(n.count, vars, depth)
+		if kLo < 0 {
+			kLo = 0
+		}
+		if kHi < 0 {
+			kHi = 0
+		}
+
+		min, max := n.die.min.N, n.die.max.N
+		if n.die.faces == nil {
+			_, sides := bounds(n.sides, vars, depth)
+			min, max = 1, sides
+		}
+
+		for _, m := range n.mods {
+			switch m.kind {
+			case modExplode:
+				if depth > 0 {
+					kHi = satMul(kHi, depth+1)
+				} else {
+					kHi = maxBound
+				}
+			case modKeep:
+				kLo, kHi = minInt(kLo, m.n), minInt(kHi, m.n)
+			case modDrop:
+				kLo, kHi = maxInt(kLo-m.n, 0), maxInt(kHi-m.n, 0)
+			case modKeepN, modDropN:
+				kLo = 0
+			}
+		}
+
+		a, b := satMul(kLo, min), satMul(kHi, min)
+		c, d := satMul(kLo, max), satMul(kHi, max)
+		return minInt(a, b), maxInt(c, d)
+	}
+
+	return 0, 0
+}
+
+// start checks the limits and context before a dice term with n dice of sides sides is
+// rolled, recording any error in ev. It returns false if the term mustn't be rolled.
+func (ev *evaluator) start(n, sides int) bool {
+	if ev.err != nil {
+		return false
+	}
+
+	if ev.ctx != nil {
+		if err := ev.ctx.Err(); err != nil {
+			ev.err = err
+			return false
+		}
+	}
+
+	if err := exceeds("MaxDice", ev.limits.MaxDice, n); err != nil {
+		ev.err = err
+		return false
+	}
+	if err := exceeds("MaxSides", ev.limits.MaxSides, sides); err != nil {
+		ev.err = err
+		return false
+	}
+
+	return true
+}
+
+// rolled counts n dice rolled by a term against MaxTotalDice
+func (ev *evaluator) rolled(n int) {
+	ev.dice += n
+	if err := exceeds("MaxTotalDice", ev.limits.MaxTotalDice, ev.dice); err != nil && ev.err == nil {
+		ev.err = err
+	}
+}
+
+// limit records that a die couldn't be rolled without exceeding limit, whose value is max
+func (ev *evaluator) limit(limit string, max int) {
+	if ev.err == nil {
+		ev.err = &LimitError{Limit: limit, Max: max, Got: max + 1}
+	}
+}
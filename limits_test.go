@@ -0,0 +1,116 @@
+package roll
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestCost(t *testing.T) {
+	for s, want := range map[string]Cost{
+		"3d6+2":         {Length: 5, Terms: 1, Dice: 3, MaxSides: 6},
+		"(2d6+1d20)*2":  {Length: 12, Depth: 1, Terms: 2, Dice: 3, MaxSides: 20},
+		"1d20>10?2d8:1": {Length: 13, Depth: 1, Terms: 2, Dice: 3, MaxSides: 20},
+		"4d6X6Kh3":      {Length: 8, Terms: 1, Dice: 4, MaxSides: 6, Explodes: true},
+		"(1d4)d(2d10)":  {Length: 12, Depth: 1, Terms: 3, Dice: 7, MaxSides: 20},
+	} {
+		if got := MustParse(s).Cost(nil); got != want {
+			t.Errorf("%s: got %+v, want %+v", s, got, want)
+		}
+	}
+
+	if got := MustParse("$n d$s").Cost(Vars{"n": 3, "s": 8}); got.Dice != 3 || got.MaxSides != 8 {
+		t.Errorf("variables: got %+v", got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	l := Limits{MaxLength: 20, MaxDepth: 2, MaxDice: 10, MaxSides: 100, MaxTotalDice: 15, MaxExplodeDepth: 3}
+
+	for _, c := range []struct {
+		s, limit string
+		parse    bool // the limit is exceeded when parsing rather than evaluating
+	}{
+		{"1d6+1d6+1d6+1d6+1d6+1", "MaxLength", true},
+		{"(((1d6)))", "MaxDepth", true},
+		{"11d6", "MaxDice", true},
+		{"1d101", "MaxSides", true},
+		{"8d6+8d6", "MaxTotalDice", false},
+		{"1d(50*3)", "MaxSides", false},
+		{"(3d6)d6", "MaxTotalDice", false},
+	} {
+		e, err := ParseWithLimits(c.s, l)
+		if !c.parse && err == nil {
+			_, err = e.Eval(nil)
+		}
+
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != c.limit {
+			t.Errorf("%s: got %v, want a %s *LimitError", c.s, err, c.limit)
+		}
+	}
+
+	// Explosions are only bounded as they're rolled
+	e, err := ParseWithLimits("1d2X2", l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = e.WithSource(&seqSource{2, 2, 2, 2, 2})
+	var le *LimitError
+	if _, err := e.Eval(nil); !errors.As(err, &le) || le.Limit != "MaxExplodeDepth" || le.Max != 3 {
+		t.Errorf("explosions: got %v, want a MaxExplodeDepth *LimitError", err)
+	}
+
+	if _, err := MustParse("8d6+8d6").WithLimits(Limits{}).Eval(nil); err != nil {
+		t.Errorf("Limits{} should evaluate without limits: %v", err)
+	}
+}
+
+func TestLimitError(t *testing.T) {
+	err := &LimitError{Limit: "MaxSides", Max: 100, Got: 101}
+	if got, want := err.Error(), "dice string exceeds MaxSides: 101 > 100"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestRollCost checks that Roll and RollN refuse an expression whose Cost exceeds its Limits
+// before building any dice, as Eval does
+func TestRollCost(t *testing.T) {
+	e := MustParse("1d(1000*3000)+1")
+
+	var le *LimitError
+	if _, err := e.Eval(nil); !errors.As(err, &le) || le.Limit != "MaxSides" {
+		t.Errorf("Eval: got %v, want a MaxSides *LimitError", err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	o := e.Roll()
+	if o.Total != 1 || len(o.Results) != 1 || len(o.Results[0].Faces()) != 0 {
+		t.Errorf("Roll: got %+v, want no dice rolled", o)
+	}
+	e.RollN(10, func(total int) {
+		if total != 1 {
+			t.Errorf("RollN: got %d, want 1", total)
+		}
+	})
+
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("rolling allocated %d bytes", n)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := MustParse("3d6").EvalContext(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if _, err := MustParse("3d6").EvalContext(context.Background(), nil); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,414 @@
+package roll
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	macroName        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	macroPlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	// macroModList matches the end of an argument whose last modifier takes a list of numbers,
+	// such as 4d6Kn5,6 or 1d10X9,10
+	macroModList = regexp.MustCompile(`[\d)](?:Kn|Dn|X|Ro?)[\d,]*\d$`)
+)
+
+// Macro is a named dice expression that can be referenced from other dice strings as @Name or
+// @Name(args...). Parameters are referenced in the Body as {name} and are replaced with the
+// value passed by the caller, or the parameter's default. Arguments are separated by commas,
+// other than those inside parentheses or brackets and those of a modifier's numbers, such as
+// 4d6Kn5,6.
+/* For Example:
+
+    var m = roll.NewMacros()
+
+    func main() {
+	    m.Define("attack(str=0, prof=2)", "1d20+{str}+{prof}")
+	    m.Define("smite(lvl)", "({lvl}+1)d8")
+
+	    e, _ := m.Parse("@attack(str=4)")           // 1d20+(4)+(2)
+	    e, _ = m.Parse("@attack(3, 3) + @smite(2)") // 1d20+(3)+(3) + ((2)+1)d8
+    }
+*/
+type Macro struct {
+	Name   string
+	Params []MacroParam
+	Body   string
+}
+
+// MacroParam is a named Macro parameter. Parameters with an empty Default must be passed by the
+// caller.
+type MacroParam struct {
+	Name    string
+	Default string
+}
+
+// UndefinedMacroError is returned when a dice string references a macro that isn't defined
+type UndefinedMacroError struct {
+	Name string
+}
+
+func (e *UndefinedMacroError) Error() string {
+	return fmt.Sprintf("undefined macro @%s", e.Name)
+}
+
+// RecursiveMacroError is returned when a macro references itself, directly or through other
+// macros. Chain is the names of the macros from the first reference back to itself.
+type RecursiveMacroError struct {
+	Chain []string
+}
+
+func (e *RecursiveMacroError) Error() string {
+	return fmt.Sprintf("recursive macro: @%s", strings.Join(e.Chain, " -> @"))
+}
+
+// MacroArgError is returned when the arguments of a macro reference don't match its parameters
+type MacroArgError struct {
+	Name string
+	Msg  string
+}
+
+func (e *MacroArgError) Error() string {
+	return fmt.Sprintf("@%s: %s", e.Name, e.Msg)
+}
+
+// Macros is a registry of Macro definitions used to expand dice strings before they're parsed
+type Macros map[string]Macro
+
+// NewMacros returns a new, empty set of Macros
+func NewMacros() Macros {
+	return make(Macros)
+}
+
+// Add a macro to the registry
+func (m Macros) Add(mac Macro) error {
+	if mac.Name == "" || macroName.FindString(mac.Name) != mac.Name {
+		return fmt.Errorf("invalid macro name %q", mac.Name)
+	}
+
+	if _, ok := m[mac.Name]; ok {
+		return fmt.Errorf("macro @%s already defined", mac.Name)
+	}
+
+	for _, ph := range macroPlaceholder.FindAllStringSubmatch(mac.Body, -1) {
+		if mac.param(ph[1]) == nil {
+			return fmt.Errorf("macro @%s: body references unknown parameter {%s}", mac.Name, ph[1])
+		}
+	}
+
+	m[mac.Name] = mac
+	return nil
+}
+
+// Define parses a signature such as "attack(str=0, prof=2)" or "fireball" and adds it to the
+// registry with body
+func (m Macros) Define(signature, body string) error {
+	mac, err := parseSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	mac.Body = strings.TrimSpace(body)
+	return m.Add(mac)
+}
+
+// Remove a macro from the registry
+func (m Macros) Remove(name string) error {
+	if _, ok := m[name]; ok {
+		delete(m, name)
+		return nil
+	}
+
+	return &UndefinedMacroError{Name: name}
+}
+
+// Get a macro from the registry
+func (m Macros) Get(name string) (Macro, error) {
+	mac, ok := m[name]
+	if !ok {
+		return Macro{}, &UndefinedMacroError{Name: name}
+	}
+
+	return mac, nil
+}
+
+/*
+Load reads macro definitions from r, one per line in the form "signature = body". Blank lines
+and lines starting with # are ignored. For example:
+
+	# Weapon attacks
+	attack(str=0, prof=2) = 1d20+{str}+{prof}
+	longsword(str=0)      = 1d8+{str}
+	fireball              = 8d6
+*/
+func (m Macros) Load(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		i := strings.Index(s, "=")
+		if p := strings.Index(s, "("); p >= 0 && p < i {
+			// Parameter defaults also use =, so look for it after the parameter list
+			i = -1
+			if c := strings.Index(s, ")"); c >= 0 {
+				if eq := strings.Index(s[c:], "="); eq >= 0 {
+					i = c + eq
+				}
+			}
+		}
+		if i < 0 {
+			return fmt.Errorf("line %d: expected signature = body", line)
+		}
+
+		if err := m.Define(s[:i], s[i+1:]); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	return sc.Err()
+}
+
+// Parse expands any macros referenced in s and parses the result
+func (m Macros) Parse(s string) (*Expr, error) {
+	x, err := m.Expand(s)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := Parse(x)
+	if err != nil {
+		return nil, err
+	}
+
+	e.src = strings.TrimSpace(s)
+	return e, nil
+}
+
+// Expand replaces every macro reference in s with its body, recursively. Each expanded macro
+// and argument is wrapped in parentheses so that it's evaluated as a unit. A *LimitError is
+// returned if the expansion grows beyond DefaultLimits.MaxLength or nests macros deeper than
+// DefaultLimits.MaxDepth, so that macros which reference each other several times can't grow
+// exponentially.
+func (m Macros) Expand(s string) (string, error) {
+	return m.expand(s, nil)
+}
+
+func (m Macros) expand(s string, stack []string) (string, error) {
+	var b strings.Builder
+
+	for {
+		at := strings.Index(s, "@")
+		if at < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:at])
+		s = s[at+1:]
+
+		name := macroName.FindString(s)
+		if name == "" {
+			return "", fmt.Errorf("@ must be followed by a macro name: @%s", s)
+		}
+		s = s[len(name):]
+
+		var args []string
+		if strings.HasPrefix(s, "(") {
+			var (
+				rest string
+				err  error
+			)
+			args, rest, err = splitArgs(s)
+			if err != nil {
+				return "", &MacroArgError{Name: name, Msg: err.Error()}
+			}
+			s = rest
+		}
+
+		x, err := m.call(name, args, stack)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(x)
+
+		if err := exceeds("MaxLength", DefaultLimits.MaxLength, b.Len()+len(s)); err != nil {
+			return "", err
+		}
+	}
+}
+
+// call expands a single macro reference
+func (m Macros) call(name string, args []string, stack []string) (string, error) {
+	for i, caller := range stack {
+		if caller == name {
+			return "", &RecursiveMacroError{Chain: append(append([]string{}, stack[i:]...), name)}
+		}
+	}
+	if err := exceeds("MaxDepth", DefaultLimits.MaxDepth, len(stack)+1); err != nil {
+		return "", err
+	}
+
+	mac, ok := m[name]
+	if !ok {
+		return "", &UndefinedMacroError{Name: name}
+	}
+
+	values, err := mac.bind(args)
+	if err != nil {
+		return "", err
+	}
+
+	// Arguments are expanded in the caller's scope before they're substituted
+	for k, v := range values {
+		if values[k], err = m.expand(v, stack); err != nil {
+			return "", err
+		}
+	}
+
+	body := macroPlaceholder.ReplaceAllStringFunc(mac.Body, func(ph string) string {
+		return "(" + values[ph[1:len(ph)-1]] + ")"
+	})
+
+	x, err := m.expand(body, append(stack, name))
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + x + ")", nil
+}
+
+// bind matches the named and positional arguments of a call to the macro's parameters
+func (mac Macro) bind(args []string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	if len(args) > len(mac.Params) {
+		return nil, &MacroArgError{Name: mac.Name, Msg: fmt.Sprintf("takes %d arguments, %d given", len(mac.Params), len(args))}
+	}
+
+	for i, a := range args {
+		name, value := mac.Params[i].Name, a
+		if eq := strings.Index(a, "="); eq >= 0 && macroName.FindString(a) == strings.TrimSpace(a[:eq]) {
+			name, value = strings.TrimSpace(a[:eq]), a[eq+1:]
+			if mac.param(name) == nil {
+				return nil, &MacroArgError{Name: mac.Name, Msg: "no parameter " + name}
+			}
+		}
+
+		if _, ok := values[name]; ok {
+			return nil, &MacroArgError{Name: mac.Name, Msg: "parameter " + name + " given more than once"}
+		}
+		values[name] = strings.TrimSpace(value)
+	}
+
+	for _, p := range mac.Params {
+		if _, ok := values[p.Name]; ok {
+			continue
+		}
+		if p.Default == "" {
+			return nil, &MacroArgError{Name: mac.Name, Msg: "missing value for parameter " + p.Name}
+		}
+		values[p.Name] = p.Default
+	}
+
+	return values, nil
+}
+
+func (mac Macro) param(name string) *MacroParam {
+	for i, p := range mac.Params {
+		if p.Name == name {
+			return &mac.Params[i]
+		}
+	}
+
+	return nil
+}
+
+// parseSignature reads "name" or "name(a, b=1)"
+func parseSignature(s string) (Macro, error) {
+	s = strings.TrimSpace(s)
+
+	mac := Macro{Name: macroName.FindString(s)}
+	if mac.Name == "" {
+		return mac, fmt.Errorf("invalid macro signature %q", s)
+	}
+
+	rest := strings.TrimSpace(s[len(mac.Name):])
+	if rest == "" {
+		return mac, nil
+	}
+
+	args, tail, err := splitArgs(rest)
+	if err != nil || strings.TrimSpace(tail) != "" {
+		return mac, fmt.Errorf("invalid macro signature %q", s)
+	}
+
+	for _, a := range args {
+		p := MacroParam{Name: strings.TrimSpace(a)}
+		if eq := strings.Index(a, "="); eq >= 0 {
+			p.Name, p.Default = strings.TrimSpace(a[:eq]), strings.TrimSpace(a[eq+1:])
+			if p.Default == "" {
+				return mac, fmt.Errorf("macro %s: empty default for parameter %s", mac.Name, p.Name)
+			}
+		}
+
+		if macroName.FindString(p.Name) != p.Name {
+			return mac, fmt.Errorf("macro %s: invalid parameter name %q", mac.Name, p.Name)
+		}
+		if mac.param(p.Name) != nil {
+			return mac, fmt.Errorf("macro %s: duplicate parameter %s", mac.Name, p.Name)
+		}
+
+		mac.Params = append(mac.Params, p)
+	}
+
+	return mac, nil
+}
+
+// splitArgs reads a parenthesised, comma separated argument list from the start of s and
+// returns the arguments and the remainder of s. Commas inside nested parentheses or brackets,
+// such as those of a nested macro call or of bands, don't separate arguments, and nor do the
+// commas of a modifier's list of numbers, such as 4d6Kn5,6. Follow a modifier's numbers with a
+// space before the comma, i.e @f(1d6X6, 2), to pass them separately.
+func splitArgs(s string) ([]string, string, error) {
+	var (
+		args  []string
+		depth = 0
+		start = 1
+	)
+
+	for i, c := range s {
+		switch c {
+		case '(', '[':
+			depth++
+		case ']':
+			depth--
+		case ')':
+			depth--
+			if depth == 0 {
+				if a := strings.TrimSpace(s[start:i]); a != "" || len(args) > 0 {
+					args = append(args, a)
+				}
+				for _, a := range args {
+					if a == "" {
+						return nil, "", fmt.Errorf("empty argument")
+					}
+				}
+				return args, s[i+1:], nil
+			}
+		case ',':
+			inList := i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9' && macroModList.MatchString(s[start:i])
+			if depth == 1 && !inList {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return nil, "", fmt.Errorf("missing )")
+}
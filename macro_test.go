@@ -0,0 +1,124 @@
+package roll
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMacroErrors(t *testing.T) {
+	m := NewMacros()
+	for sig, body := range map[string]string{
+		"attack(str=0, prof=2)": "1d20+{str}+{prof}",
+		"loop":                  "@loop+1",
+		"ping":                  "@pong",
+		"pong":                  "@ping",
+	} {
+		if err := m.Define(sig, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var undefined *UndefinedMacroError
+	if _, err := m.Expand("@missing+1"); !errors.As(err, &undefined) || undefined.Name != "missing" {
+		t.Errorf("@missing: got %v, want an UndefinedMacroError", err)
+	}
+	if _, err := m.Get("missing"); !errors.As(err, &undefined) {
+		t.Errorf("Get: got %v, want an UndefinedMacroError", err)
+	}
+
+	var recursive *RecursiveMacroError
+	if _, err := m.Expand("@loop"); !errors.As(err, &recursive) {
+		t.Errorf("@loop: got %v, want a RecursiveMacroError", err)
+	}
+	if _, err := m.Expand("@ping"); !errors.As(err, &recursive) || strings.Join(recursive.Chain, ",") != "ping,pong,ping" {
+		t.Errorf("@ping: got %v, want a RecursiveMacroError through pong", err)
+	}
+
+	var arg *MacroArgError
+	for _, s := range []string{"@attack(1, 2, 3)", "@attack(dex=1)", "@attack(str=1, str=2)", "@attack(1"} {
+		if _, err := m.Expand(s); !errors.As(err, &arg) || arg.Name != "attack" {
+			t.Errorf("%s: got %v, want a MacroArgError", s, err)
+		}
+	}
+
+	if x, err := m.Expand("@attack(str=4)"); err != nil || x != "(1d20+(4)+(2))" {
+		t.Errorf("@attack(str=4) = %q, %v", x, err)
+	}
+}
+
+func TestMacroExpansionLimit(t *testing.T) {
+	m := NewMacros()
+	// Each macro references the next twice, doubling the expansion at every level
+	for i := 0; i < 40; i++ {
+		body := "1d6"
+		if i < 39 {
+			next := "@m" + strconv.Itoa(i+1)
+			body = next + "+" + next
+		}
+		if err := m.Define("m"+strconv.Itoa(i), body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var limit *LimitError
+	if _, err := m.Expand("@m0"); !errors.As(err, &limit) || limit.Limit != "MaxLength" {
+		t.Errorf("got %v, want a MaxLength LimitError", err)
+	}
+
+	deep := NewMacros()
+	for i := 0; i <= DefaultLimits.MaxDepth; i++ {
+		if err := deep.Define("d"+strings.Repeat("x", i), "@d"+strings.Repeat("x", i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := deep.Expand("@d"); !errors.As(err, &limit) || limit.Limit != "MaxDepth" {
+		t.Errorf("got %v, want a MaxDepth LimitError", err)
+	}
+}
+
+func TestMacroArgs(t *testing.T) {
+	m := NewMacros()
+	for sig, body := range map[string]string{
+		"pool(dice, bonus=0)": "{dice}+{bonus}",
+		"one(x)":              "{x}",
+	} {
+		if err := m.Define(sig, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		s, want string
+	}{
+		{"@pool(4d6, 2)", "((4d6)+(2))"},
+		{"@pool(4d6,2)", "((4d6)+(2))"},
+		// The commas of a modifier's numbers stay with the modifier
+		{"@pool(4d6Kn5,6, 2)", "((4d6Kn5,6)+(2))"},
+		{"@pool(4d6Kn5,6,2)", "((4d6Kn5,6,2)+(0))"},
+		{"@pool(4d6Dn1,2)", "((4d6Dn1,2)+(0))"},
+		{"@pool(1d10X9,10)", "((1d10X9,10)+(0))"},
+		{"@pool(3d6R1,2, 1)", "((3d6R1,2)+(1))"},
+		{"@pool(3d6Ro1,2)", "((3d6Ro1,2)+(0))"},
+		{"@pool((1d4)d6X5,6)", "(((1d4)d6X5,6)+(0))"},
+		{"@pool(2d8Kh1X7,8 , 3)", "((2d8Kh1X7,8)+(3))"},
+		{"@pool(1d6X6, 2)", "((1d6X6)+(2))"},
+		{"@pool(bonus=1, dice=2d6Kn6,1)", "((2d6Kn6,1)+(1))"},
+		// Commas inside parentheses and brackets don't separate arguments either
+		{"@pool(@one(2d6), @one(3))", "((((2d6)))+(((3))))"},
+		{"@one(2d6 [miss:..6, hit:7..])", "((2d6 [miss:..6, hit:7..]))"},
+		// A variable ending in a modifier's name isn't one
+		{"@pool($X1,2)", "(($X1)+(2))"},
+	} {
+		if x, err := m.Expand(c.s); err != nil || x != c.want {
+			t.Errorf("%s = %q, %v, want %q", c.s, x, err, c.want)
+		}
+	}
+
+	for _, s := range []string{"@one(4d6Kn5,6)", "@pool(4d6Kn5,6, 1)", "@pool(3d6R1,2)"} {
+		if _, err := m.Parse(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+}
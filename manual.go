@@ -0,0 +1,174 @@
+package roll
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DieSource is implemented by Sources that choose the Face of a Die themselves rather than an
+// index, such as Manual. Die.RollWith uses RollDie when its Source is a DieSource.
+type DieSource interface {
+	Source
+	RollDie(d Die) Face
+}
+
+// Manual is a DieSource for physical dice. Every die the library needs, including those
+// requested mid-evaluation by explosions and rerolls, is asked for in turn and the number
+// entered is checked against the faces of the Die, so a roll made at the table flows through
+// expressions, Keep, Explode and Table lookups exactly like a digital one. Eval returns the
+// error of an entry that failed:
+/* For Example:
+
+   m := roll.NewPrompt(os.Stdin, os.Stdout)
+   o, err := roll.MustParse("4d6Kh3").WithSource(m).Eval(nil)
+
+*/
+type Manual struct {
+	next  func(d Die) (Face, error)
+	err   error
+	count int
+}
+
+// NewManual returns a Manual that uses values, in order, as the numbers rolled. Err reports a
+// value that isn't on the Die it was used for or running out of values.
+func NewManual(values ...int) *Manual {
+	return &Manual{next: func(d Die) (Face, error) {
+		if len(values) == 0 {
+			return Face{}, fmt.Errorf("no value supplied for %s", describe(d))
+		}
+
+		n := values[0]
+		values = values[1:]
+
+		f, ok := d.face(n)
+		if !ok {
+			return Face{}, fmt.Errorf("%d is not a face of %s", n, describe(d))
+		}
+
+		return f, nil
+	}}
+}
+
+// NewPrompt returns a Manual that writes a prompt naming the Die to w and reads the face
+// rolled from a line of r, either its number or its value (i.e [+] for Fate dice). Invalid
+// entries are reported and prompted for again. Err reports a read error or the end of r.
+func NewPrompt(r io.Reader, w io.Writer) *Manual {
+	scanner := bufio.NewScanner(r)
+
+	return &Manual{next: func(d Die) (Face, error) {
+		for {
+			fmt.Fprintf(w, "%s: ", describe(d))
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return Face{}, err
+				}
+				return Face{}, fmt.Errorf("no face entered for %s", describe(d))
+			}
+
+			if f, ok := d.lookup(strings.TrimSpace(scanner.Text())); ok {
+				return f, nil
+			}
+			fmt.Fprintf(w, "%q is not a face of %s\n", strings.TrimSpace(scanner.Text()), describe(d))
+		}
+	}}
+}
+
+// RollDie asks for a face of d. Once an entry has failed Err returns the error and RollDie
+// returns the lowest face of every Die without asking again, so the roll is only meaningful
+// if Err is nil. Eval and EvalN check it for you.
+func (m *Manual) RollDie(d Die) Face {
+	if m.err != nil {
+		return d.Min()
+	}
+
+	f, err := m.next(d)
+	if err != nil {
+		m.err = err
+		return d.Min()
+	}
+	m.count++
+
+	return f
+}
+
+// Intn asks for a number from 1 to n, as a die with n sides, and returns it less 1. It's used
+// when Manual rolls Lists and other things that aren't a Die.
+func (m *Manual) Intn(n int) int {
+	return m.RollDie(NewDie(makeFaces(n))).N - 1
+}
+
+// Err returns the first entry that failed, if any
+func (m *Manual) Err() error {
+	return m.err
+}
+
+// Count returns the number of faces entered successfully
+func (m *Manual) Count() int {
+	return m.count
+}
+
+// sourceErr returns the error of a Source that can fail, such as Manual
+func sourceErr(src Source) error {
+	if s, ok := src.(interface{ Err() error }); ok {
+		return s.Err()
+	}
+
+	return nil
+}
+
+// face returns a face of d that shows n and can be rolled
+func (d Die) face(n int) (Face, bool) {
+	for i, f := range d.faces {
+		if f.N == n && d.weight(i) > 0 {
+			return f, true
+		}
+	}
+
+	return Face{}, false
+}
+
+// lookup returns the face of d whose value is s, or whose number is s
+func (d Die) lookup(s string) (Face, bool) {
+	for i, f := range d.faces {
+		if f.Value == s && d.weight(i) > 0 {
+			return f, true
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return Face{}, false
+	}
+
+	return d.face(n)
+}
+
+// describe names d in prompts and errors, i.e d6 or die [-] [ ] [+]
+func describe(d Die) string {
+	numbered := true
+	for i, f := range d.faces {
+		if f.N != i+1 || f.Value != strconv.Itoa(i+1) {
+			numbered = false
+			break
+		}
+	}
+	if numbered {
+		return "d" + strconv.Itoa(len(d.faces))
+	}
+
+	var (
+		values []string
+		seen   = make(map[string]bool)
+	)
+	for _, f := range d.faces {
+		if !seen[f.Value] {
+			seen[f.Value] = true
+			values = append(values, f.Value)
+		}
+	}
+
+	return "die " + strings.Join(values, " ")
+}
@@ -0,0 +1,77 @@
+package roll
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManual(t *testing.T) {
+	for _, c := range []struct {
+		s      string
+		values []int
+		total  int
+		count  int
+		err    string
+	}{
+		{s: "4d6Kh3", values: []int{4, 1, 6, 3}, total: 13, count: 4},
+		{s: "1d6X6+1", values: []int{6, 6, 2}, total: 15, count: 3},
+		{s: "1d20R1", values: []int{1, 1, 17}, total: 17, count: 3},
+		{s: "1d6", values: []int{7}, count: 0, err: "7 is not a face of d6"},
+		{s: "2d6", values: []int{3}, count: 1, err: "no value supplied for d6"},
+		{s: "1d6+1d8", values: []int{2, 0, 5}, count: 1, err: "0 is not a face of d8"},
+	} {
+		m := NewManual(c.values...)
+		o, err := MustParse(c.s).WithSource(m).Eval(nil)
+
+		switch {
+		case c.err != "":
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s with %v: got error %v, want %q", c.s, c.values, err, c.err)
+			}
+			if err != m.Err() {
+				t.Errorf("%s with %v: Eval returned %v, Err %v", c.s, c.values, err, m.Err())
+			}
+		case err != nil:
+			t.Errorf("%s with %v: %v", c.s, c.values, err)
+		case o.Total != c.total:
+			t.Errorf("%s with %v: total %d, want %d", c.s, c.values, o.Total, c.total)
+		}
+
+		if m.Count() != c.count {
+			t.Errorf("%s with %v: %d faces entered, want %d", c.s, c.values, m.Count(), c.count)
+		}
+	}
+}
+
+func TestManualEvalN(t *testing.T) {
+	var totals []int
+	err := MustParse("1d6").WithSource(NewManual(2, 5, 9, 1)).EvalN(4, nil, func(total int) {
+		totals = append(totals, total)
+	})
+
+	if err == nil || err.Error() != "9 is not a face of d6" {
+		t.Errorf("got error %v, want 9 is not a face of d6", err)
+	}
+	if !equalInts(totals, []int{2, 5}) {
+		t.Errorf("got totals %v before the failed entry, want [2 5]", totals)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	var w strings.Builder
+	m := NewPrompt(strings.NewReader("[+]\n7\n 0 \n-1\n"), &w)
+
+	r := RollWith(m, 3, Fate)
+	if !equalInts(r.Ints(), []int{1, 0, -1}) || m.Err() != nil {
+		t.Errorf("got %v, %v, want [1 0 -1]", r.Ints(), m.Err())
+	}
+
+	prompt := "die [-] [ ] [+]: "
+	if want := prompt + prompt + "\"7\" is not a face of die [-] [ ] [+]\n" + prompt + prompt; w.String() != want {
+		t.Errorf("prompted %q, want %q", w.String(), want)
+	}
+
+	if _, err := MustParse("1d6").WithSource(m).Eval(nil); err == nil || err.Error() != "no face entered for d6" {
+		t.Errorf("got error %v at the end of input, want no face entered for d6", err)
+	}
+}
@@ -0,0 +1,209 @@
+package roll
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Genesys (and FFG Star Wars) narrative dice. Each face lists its symbols by name, separated by
+// spaces, in Value and is numbered 1 to the number of sides in N.
+var (
+	GenesysBoost       = genesysDie("", "", "success", "success advantage", "advantage advantage", "advantage")
+	GenesysSetback     = genesysDie("", "", "failure", "failure", "threat", "threat")
+	GenesysAbility     = genesysDie("", "success", "success", "success success", "advantage", "advantage", "success advantage", "advantage advantage")
+	GenesysDifficulty  = genesysDie("", "failure", "failure failure", "threat", "threat", "threat", "threat threat", "failure threat")
+	GenesysProficiency = genesysDie("", "success", "success", "success success", "success success", "advantage", "success advantage", "success advantage", "success advantage", "advantage advantage", "advantage advantage", "triumph")
+	GenesysChallenge   = genesysDie("", "failure", "failure", "failure failure", "failure failure", "threat", "threat", "failure threat", "failure threat", "threat threat", "threat threat", "despair")
+)
+
+func genesysDie(values ...string) Die {
+	var f Faces
+	for i, v := range values {
+		f = append(f, Face{N: i + 1, Value: v})
+	}
+
+	return NewDie(f)
+}
+
+// jointMechanic builds the joint distribution of a mechanic from the argument of its spec
+type jointMechanic struct {
+	usage string
+	build func(arg string) (*Joint, error)
+}
+
+// jointMechanics are the mechanics understood by ParseJoint, keyed by name
+var jointMechanics = map[string]jointMechanic{
+	"genesys": {
+		usage: "genesys:POOL, a pool of narrative dice such as 2a1p2d (b boost, s setback, a ability, d difficulty, p proficiency, c challenge) read as net success and advantage, triumph and despair",
+		build: Genesys,
+	},
+	"dragonage": {
+		usage: "dragonage, 3d6 read as its total and the stunt points of the dragon die when any two dice match",
+		build: func(arg string) (*Joint, error) {
+			if arg != "" {
+				return nil, fmt.Errorf("dragonage takes no argument")
+			}
+			return DragonAge()
+		},
+	},
+	"ore": {
+		usage: "ore:N, N d10 of the One-Roll Engine read as the width and height of the widest set",
+		build: func(arg string) (*Joint, error) {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("ore needs a number of dice, i.e ore:6")
+			}
+			return ORE(n)
+		},
+	},
+}
+
+// ParseJoint returns the exact joint distribution of a mechanic given as name or name:arg, such
+// as genesys:2a1p2d, dragonage or ore:6. See JointMechanics for the mechanics available.
+func ParseJoint(spec string) (*Joint, error) {
+	name, arg := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+
+	m, ok := jointMechanics[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for n := range jointMechanics {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown mechanic %q: must be one of %s", name, strings.Join(names, ", "))
+	}
+
+	return m.build(arg)
+}
+
+// JointMechanics returns a description of the use of each mechanic understood by ParseJoint,
+// sorted by name
+func JointMechanics() []string {
+	var out []string
+	for _, m := range jointMechanics {
+		out = append(out, m.usage)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+var genesysPool = regexp.MustCompile(`^(?:\d*[bsadpc])+$`)
+
+// genesysDice maps the letters of a Genesys pool to their dice
+var genesysDice = map[byte]Die{
+	'b': GenesysBoost,
+	's': GenesysSetback,
+	'a': GenesysAbility,
+	'd': GenesysDifficulty,
+	'p': GenesysProficiency,
+	'c': GenesysChallenge,
+}
+
+// Genesys returns the joint distribution of a pool of Genesys narrative dice, written as a count
+// and letter for each kind of die such as 2a1p2d: b boost, s setback, a ability, d difficulty,
+// p proficiency and c challenge. The components are the net success (triumphs counting as
+// successes and despairs as failures), net advantage, triumph and despair. ErrInexact is
+// returned for pools too large to compute in reasonable time.
+func Genesys(pool string) (*Joint, error) {
+	pool = strings.ToLower(pool)
+	if !genesysPool.MatchString(pool) {
+		return nil, fmt.Errorf("invalid Genesys pool %q, i.e 2a1p2d", pool)
+	}
+
+	names := []string{"success", "advantage", "triumph", "despair"}
+	out := NewJoint(names...)
+	out.AddOutcome(1, 0, 0, 0, 0)
+
+	for i := 0; i < len(pool); {
+		j := i
+		for pool[j] >= '0' && pool[j] <= '9' {
+			j++
+		}
+		n := 1
+		if j > i {
+			n, _ = strconv.Atoi(pool[i:j])
+		}
+
+		face, err := DieJoint(genesysDice[pool[j]], names, genesysSymbols).repeat(n, maxMultisets)
+		if err != nil {
+			return nil, err
+		}
+		if out, err = out.addWithin(face, maxMultisets); err != nil {
+			return nil, err
+		}
+		i = j + 1
+	}
+
+	return out, nil
+}
+
+// genesysSymbols counts the symbols of a Genesys face
+func genesysSymbols(f Face) []int {
+	var s, a, t, d int
+
+	for _, sym := range strings.Fields(f.Value) {
+		switch sym {
+		case "success":
+			s++
+		case "failure":
+			s--
+		case "advantage":
+			a++
+		case "threat":
+			a--
+		case "triumph":
+			s++
+			t++
+		case "despair":
+			s--
+			d++
+		}
+	}
+
+	return []int{s, a, t, d}
+}
+
+// DragonAge returns the joint distribution of a Dragon Age test of 3d6: the total and the stunt
+// points, the value of the dragon die if any two of the dice match and 0 otherwise
+func DragonAge() (*Joint, error) {
+	return JointOf([]string{"total", "stunt"}, Set{{N: 2, Die: D6}, {N: 1, Die: D6}}, func(rolls []Faces) []int {
+		a, b, dragon := rolls[0][0].N, rolls[0][1].N, rolls[1][0].N
+
+		stunt := 0
+		if a == b || a == dragon || b == dragon {
+			stunt = dragon
+		}
+
+		return []int{a + b + dragon, stunt}
+	})
+}
+
+// ORE returns the joint distribution of n d10 read by the One-Roll Engine: the width (number of
+// matching dice) and height (their value) of the widest set, the highest of equally wide sets.
+// Width and height are both 0 if no two dice match.
+func ORE(n int) (*Joint, error) {
+	return JointOf([]string{"width", "height"}, Set{{N: n, Die: D10}}, func(rolls []Faces) []int {
+		width, height := 0, 0
+
+		// rolls are sorted, so sets are runs of equal faces
+		for i := 0; i < len(rolls[0]); {
+			j := i
+			for j < len(rolls[0]) && rolls[0][j].N == rolls[0][i].N {
+				j++
+			}
+			if w := j - i; w > 1 && w >= width {
+				width, height = w, rolls[0][i].N
+			}
+			i = j
+		}
+
+		return []int{width, height}
+	})
+}
@@ -0,0 +1,198 @@
+package roll
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ParseErrorKind classifies a ParseError
+type ParseErrorKind int
+
+// Kinds of ParseError
+const (
+	// SyntaxError is a character or token that doesn't belong where it is, i.e 4d6kh3
+	SyntaxError ParseErrorKind = iota
+	// UnexpectedEnd is a dice string that stops part way through, i.e 1d20+
+	UnexpectedEnd
+	// Unbalanced is a missing ) or the : of a ternary
+	Unbalanced
+	// InvalidNumber is a number that can't be read, usually because it's too large
+	InvalidNumber
+	// InvalidModifier is a keep, drop or explode that can't apply to its dice, i.e 3d6Dl4
+	InvalidModifier
+	// InvalidDie is a die that can't be rolled, i.e 0d6 or 3d1
+	InvalidDie
+	// InvalidBands is a [bands] suffix that can't be read
+	InvalidBands
+	// NotDiceTerm is a valid expression given to FromString, which only accepts a single term
+	NotDiceTerm
+	// LimitExceeded is a dice string beyond the Limits it's parsed with. Err is the *LimitError.
+	LimitExceeded
+)
+
+var kindNames = map[ParseErrorKind]string{
+	SyntaxError:     "syntax error",
+	UnexpectedEnd:   "unexpected end",
+	Unbalanced:      "unbalanced",
+	InvalidNumber:   "invalid number",
+	InvalidModifier: "invalid modifier",
+	InvalidDie:      "invalid die",
+	InvalidBands:    "invalid bands",
+	NotDiceTerm:     "not a dice term",
+	LimitExceeded:   "limit exceeded",
+}
+
+func (k ParseErrorKind) String() string {
+	return kindNames[k]
+}
+
+// ParseError is returned by Parse, FromString and the other parsers for a dice string that
+// can't be read. Pos and End are the byte offsets of the offending span of Input and
+// Suggestion, if set, is a corrected dice string for common mistakes such as lower case
+// modifiers. Use errors.As to get at it:
+/* For Example:
+
+    _, err := roll.Parse("4d6kh3")
+
+    var pe *roll.ParseError
+    if errors.As(err, &pe) {
+	    fmt.Println(pe.Render())
+    }
+
+    // 4d6kh3
+    //    ^^^
+    // unparsed characters: kh3
+    // did you mean 4d6Kh3?
+
+*/
+type ParseError struct {
+	Kind       ParseErrorKind
+	Input      string
+	Pos, End   int
+	Msg        string
+	Suggestion string
+	Err        error // underlying error, such as a *LimitError
+}
+
+func (e *ParseError) Error() string {
+	s := fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+	if e.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %s?)", e.Suggestion)
+	}
+
+	return s
+}
+
+// Unwrap returns the underlying error, if any
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Render returns the input with a caret line marking the offending span, followed by the
+// message and any suggestion, for printing to a terminal
+func (e *ParseError) Render() string {
+	var (
+		b        strings.Builder
+		pos, end = e.Pos, e.End
+	)
+
+	if pos > len(e.Input) {
+		pos = len(e.Input)
+	}
+	if end <= pos {
+		end = pos + 1
+	}
+
+	fmt.Fprintf(&b, "%s\n%s%s\n%s", e.Input, strings.Repeat(" ", pos), strings.Repeat("^", end-pos), e.Msg)
+	if e.Suggestion != "" {
+		fmt.Fprintf(&b, "\ndid you mean %s?", e.Suggestion)
+	}
+
+	return b.String()
+}
+
+// parseErr returns a *ParseError of kind for the span [pos, end) of input. Suggestions are added
+// by parse, which knows the Limits a correction must be parsed within.
+func parseErr(kind ParseErrorKind, input string, pos, end int, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Kind:  kind,
+		Input: input,
+		Pos:   pos,
+		End:   end,
+		Msg:   fmt.Sprintf(format, args...),
+	}
+}
+
+// spanErr places err, from a check that doesn't know where it is, at [pos, end) of input. A
+// *ParseError keeps its kind and a *LimitError becomes LimitExceeded; anything else is kind.
+func spanErr(err error, kind ParseErrorKind, input string, pos, end int) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *ParseError:
+		kind = e.Kind
+	case *LimitError:
+		kind = LimitExceeded
+	}
+
+	pe := parseErr(kind, input, pos, end, "%s", err)
+	if kind == LimitExceeded {
+		pe.Err = err
+	}
+
+	return pe
+}
+
+var (
+	// modifiers written in lower case, i.e kh3 or x6
+	suggestLower = regexp.MustCompile(`^[kx]`)
+	// a drop written with the dice operator, i.e the dl1 of 4d6dl1
+	suggestDrop = regexp.MustCompile(`^[hlmfe]\d`)
+	// a keep or drop without a number, i.e Kh
+	suggestCount = regexp.MustCompile(`^[KD][hlmfe](\D|$)`)
+	// a keep or drop without a selector, i.e K3
+	suggestSelect = regexp.MustCompile(`^[KD]\d`)
+)
+
+// suggest returns input corrected for a common mistake at pos, or an empty string if it doesn't
+// recognise the mistake or the correction doesn't parse within l
+func suggest(input string, pos int, l Limits) string {
+	if pos < 0 || pos > len(input) {
+		return ""
+	}
+
+	var (
+		rest = input[pos:]
+		fix  string
+	)
+
+	switch {
+	case suggestLower.MatchString(rest):
+		fix = input[:pos] + strings.ToUpper(rest[:1]) + rest[1:]
+	case suggestDrop.MatchString(rest) && pos > 1 && input[pos-1] == 'd':
+		fix = input[:pos-1] + "D" + rest
+	case suggestCount.MatchString(rest):
+		fix = input[:pos+2] + "1" + rest[2:]
+	case suggestSelect.MatchString(rest):
+		sel := "h"
+		if rest[0] == 'D' {
+			sel = "l"
+		}
+		fix = input[:pos+1] + sel + rest[1:]
+	case strings.HasPrefix(rest, "d") && (pos == 0 || strings.ContainsAny(input[pos-1:pos], "+-*(<>=?: ")):
+		fix = input[:pos] + "1" + rest
+	default:
+		return ""
+	}
+
+	// Corrections can reveal more mistakes, i.e 4d6kh becomes 4d6Kh then 4d6Kh1
+	if _, err := parse(fix, l); err != nil {
+		if pe, ok := err.(*ParseError); ok && pe.Suggestion != "" {
+			return pe.Suggestion
+		}
+		return ""
+	}
+
+	return fix
+}
@@ -0,0 +1,103 @@
+package roll
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct {
+		s          string
+		kind       ParseErrorKind
+		pos, end   int
+		suggestion string
+	}{
+		{"4d6kh3", SyntaxError, 3, 6, "4d6Kh3"},
+		{"4d6dl1", SyntaxError, 4, 6, "4d6Dl1"},
+		{"4d6Kh", SyntaxError, 3, 5, "4d6Kh1"},
+		{"4d6kh", SyntaxError, 3, 5, "4d6Kh1"},
+		{"4d6K3", SyntaxError, 3, 5, "4d6Kh3"},
+		{"1d6x6", SyntaxError, 3, 5, "1d6X6"},
+		{"d20", SyntaxError, 0, 1, "1d20"},
+		{"2d6 [nope", SyntaxError, 4, 9, ""},
+		{"1d20+", UnexpectedEnd, 5, 6, ""},
+		{"(1d6", Unbalanced, 4, 5, ""},
+		{"1d20 ? 1", Unbalanced, 8, 9, ""},
+		{"99999999999999999999d6", InvalidNumber, 0, 20, ""},
+		{"3d6Dl4", InvalidModifier, 3, 6, ""},
+		{"0d6", InvalidDie, 0, 3, ""},
+		{"3d1", InvalidDie, 0, 3, ""},
+		{"1d5000000Kh1", LimitExceeded, 0, 9, ""},
+	} {
+		_, err := Parse(c.s)
+
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: got %v, want a *ParseError", c.s, err)
+			continue
+		}
+		if pe.Kind != c.kind || pe.Pos != c.pos || pe.End != c.end || pe.Suggestion != c.suggestion {
+			t.Errorf("%s: got %v at [%d, %d) suggesting %q, want %v at [%d, %d) suggesting %q",
+				c.s, pe.Kind, pe.Pos, pe.End, pe.Suggestion, c.kind, c.pos, c.end, c.suggestion)
+		}
+	}
+}
+
+func TestParseErrorLimit(t *testing.T) {
+	_, err := Parse("1d5000000Kh1")
+
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxSides" {
+		t.Errorf("got %v, want a MaxSides *LimitError", err)
+	}
+}
+
+// TestSuggestionLimits checks that corrections are parsed within the caller's Limits, so that a
+// typo can't build a die the corrected string would be refused
+func TestSuggestionLimits(t *testing.T) {
+	start := time.Now()
+	_, err := Parse("1d5000000kh1")
+	if time.Since(start) > 100*time.Millisecond {
+		t.Errorf("suggesting a correction took %v", time.Since(start))
+	}
+
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Suggestion != "" {
+		t.Errorf("got %v, want no suggestion beyond MaxSides", err)
+	}
+
+	_, err = ParseWithLimits("1d200kh1", Limits{MaxSides: 100})
+	if !errors.As(err, &pe) || pe.Suggestion != "" {
+		t.Errorf("got %v, want no suggestion beyond MaxSides", err)
+	}
+
+	_, err = Parse("1d200kh1")
+	if !errors.As(err, &pe) || pe.Suggestion != "1d200Kh1" {
+		t.Errorf("got %v, want a suggestion of 1d200Kh1", err)
+	}
+}
+
+func TestParseErrorRender(t *testing.T) {
+	_, err := Parse("4d6kh3")
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v, want a *ParseError", err)
+	}
+
+	want := "4d6kh3\n   ^^^\nunparsed characters: kh3\ndid you mean 4d6Kh3?"
+	if got := pe.Render(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got, want := pe.Error(), "unparsed characters: kh3 at position 3 (did you mean 4d6Kh3?)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A position at the end of the input is marked just past it
+	_, err = Parse("1d20+")
+	errors.As(err, &pe)
+	if got, want := pe.Render(), "1d20+\n     ^\nunexpected end of dice string"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
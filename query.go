@@ -0,0 +1,603 @@
+package roll
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a question about the total of an expression, parsed by ParseQuery, such as the
+// chance of rolling 15 or more, the average total given that a die shows a 6 or the chance of
+// beating another expression
+type Query struct {
+	src     string
+	kind    queryKind
+	total   *totalTest // the totals asked about by a probability query
+	dice    *diceTest  // the dice asked about by a dice query
+	op      string     // the comparison of a contest
+	against *Expr      // the opposing expression of a contest
+	given   *condition
+}
+
+type queryKind int
+
+const (
+	queryP queryKind = iota
+	queryDice
+	queryMean
+	queryContest
+)
+
+// operand is a number in a query, either written out or a variable bound when it's answered
+type operand struct {
+	n    int
+	name string
+}
+
+func parseOperand(s string) (operand, bool) {
+	if scanVar.MatchString(s) && len(scanVar.FindString(s)) == len(s) {
+		return operand{name: s[1:]}, true
+	}
+
+	n, err := strconv.Atoi(s)
+	return operand{n: n}, err == nil
+}
+
+func (o operand) value(vars Vars) (int, error) {
+	if o.name == "" {
+		return o.n, nil
+	}
+
+	v, ok := vars[o.name]
+	if !ok {
+		return 0, &MissingVariableError{Name: o.name}
+	}
+
+	return v, nil
+}
+
+// totalTest compares a total with lo using op, or checks it's between lo and hi inclusive if op
+// is "between"
+type totalTest struct {
+	op     string
+	lo, hi operand
+}
+
+// bind returns the test with its variables bound from vars
+func (t *totalTest) bind(vars Vars) (func(v int) bool, error) {
+	lo, err := t.lo.value(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.op != "between" {
+		return func(v int) bool { return compareInts(t.op, v, lo) }, nil
+	}
+
+	hi, err := t.hi.value(vars)
+	if err != nil {
+		return nil, err
+	}
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+
+	return func(v int) bool { return v >= lo && v <= hi }, nil
+}
+
+// diceTest compares the number of kept dice whose face compares with face using faceOp with count
+// using op, i.e at least (>=) 3 dice showing 8 or more (>=)
+type diceTest struct {
+	op, faceOp  string
+	count, face operand
+}
+
+// bind returns the tests of the number of dice and of each face with variables bound from vars
+func (t *diceTest) bind(vars Vars) (func(n int) bool, func(f Face) bool, error) {
+	count, err := t.count.value(vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	face, err := t.face.value(vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return func(n int) bool { return compareInts(t.op, n, count) },
+		func(f Face) bool { return compareInts(t.faceOp, f.N, face) },
+		nil
+}
+
+// holds reports whether the kept dice of results pass the test
+func (t *diceTest) holds(results Results, vars Vars) (bool, error) {
+	count, face, err := t.bind(vars)
+	if err != nil {
+		return false, err
+	}
+
+	return count(results.CountFunc(face)), nil
+}
+
+// condition restricts a query to the rolls whose total passes total or whose dice pass dice
+type condition struct {
+	total *totalTest
+	dice  *diceTest
+}
+
+// Answer is the answer to a Query about an expression
+type Answer struct {
+	Query string `json:"query"`
+	// P is the probability asked about, the expected total for a mean or the probability of the
+	// comparison holding for a contest
+	P float64 `json:"p"`
+	// Win, Tie and Lose are the probabilities of a contest's total being higher than, equal to
+	// and lower than the opposing total
+	Win  float64 `json:"win,omitempty"`
+	Tie  float64 `json:"tie,omitempty"`
+	Lose float64 `json:"lose,omitempty"`
+	// Given is the probability of the query's condition, 1 if it has none
+	Given float64 `json:"given"`
+	// Exact is set when the answer was computed exactly. Otherwise it was estimated from Rolls
+	// rolls meeting the condition and StdErr is the standard error of P.
+	Exact  bool    `json:"exact"`
+	StdErr float64 `json:"stderr"`
+	Rolls  int     `json:"rolls,omitempty"`
+}
+
+const queryNum = `(-?\d+|\$[A-Za-z_][A-Za-z0-9_]*)`
+
+var (
+	queryCmp     = regexp.MustCompile(`^(>=|<=|!=|==|=|>|<)\s*(.+)$`)
+	queryBetween = regexp.MustCompile(`(?i)^between\s+` + queryNum + `\s+and\s+` + queryNum + `$`)
+	queryVs      = regexp.MustCompile(`(?i)^(?:vs|versus)\s+(.+)$`)
+	queryMeans   = regexp.MustCompile(`(?i)^(?:mean|average|expected)$`)
+	queryGiven   = regexp.MustCompile(`(?i)(?:^|\s+)given\s+`)
+	queryDiceRe  = regexp.MustCompile(`(?i)^(at least|at most|exactly|any|no)\s+(?:(\w+|\$\w+)\s+)?(?:(?:die|dice)\s+shows?\s+)?` + queryNum + `s?(\+|\s+or\s+(?:more|higher|less|lower))?$`)
+)
+
+// ParseQuery parses a query about the total of an expression. Queries are one of
+//
+//	>= 15, < 8, = 12, != 1          the chance of a total compared with a number
+//	between 7 and 9                 the chance of a total in a range, inclusive
+//	at least 3 dice show 8 or more  the chance of a number of the kept dice showing a face
+//	mean                            the expected total
+//	> 1d20+3, vs 1d20+3             a contest against another expression, ties counted separately
+//
+// optionally followed by a condition, "given >= 10", "given between 3 and 5" or a condition on
+// the kept dice such as "given at least one die shows 6", "given any 1", "given no die shows 1"
+// or "given at least 2 dice show 5+". Numbers may be variables, such as >= $dc, bound when the
+// query is answered.
+func ParseQuery(s string) (*Query, error) {
+	q := &Query{src: strings.TrimSpace(s)}
+
+	subject := q.src
+	if loc := queryGiven.FindStringIndex(subject); loc != nil {
+		if loc[0] == 0 {
+			return nil, fmt.Errorf("query %q has a condition but nothing to ask, i.e >= 15 %s", q.src, q.src)
+		}
+
+		c := &condition{}
+		if c.total = parseTotalTest(subject[loc[1]:]); c.total == nil {
+			if c.dice = parseDiceTest(subject[loc[1]:]); c.dice == nil {
+				return nil, fmt.Errorf("can't understand condition %q, try >= 10, at least one die shows 6 or no die shows 1", subject[loc[1]:])
+			}
+		}
+		q.given, subject = c, subject[:loc[0]]
+	}
+
+	if q.total = parseTotalTest(subject); q.total != nil {
+		return q, nil
+	}
+	if q.dice = parseDiceTest(subject); q.dice != nil {
+		q.kind = queryDice
+		return q, nil
+	}
+
+	switch {
+	case queryMeans.MatchString(subject):
+		q.kind = queryMean
+		return q, nil
+
+	case queryVs.MatchString(subject):
+		return q, q.contest(">", queryVs.FindStringSubmatch(subject)[1])
+
+	case queryCmp.MatchString(subject):
+		m := queryCmp.FindStringSubmatch(subject)
+		return q, q.contest(m[1], m[2])
+	}
+
+	return nil, fmt.Errorf("can't understand query %q, try >= 15, between 7 and 9, mean or vs 1d20+3", q.src)
+}
+
+// contest makes q a contest against the dice string s
+func (q *Query) contest(op, s string) error {
+	e, err := Parse(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("query %q: %v", q.src, err)
+	}
+	if op == "==" {
+		op = "="
+	}
+
+	q.kind, q.op, q.against = queryContest, op, e
+	return nil
+}
+
+// parseTotalTest parses a comparison with a number or a between range, nil if s is neither
+func parseTotalTest(s string) *totalTest {
+	if m := queryBetween.FindStringSubmatch(s); m != nil {
+		lo, _ := parseOperand(m[1])
+		hi, _ := parseOperand(m[2])
+		return &totalTest{op: "between", lo: lo, hi: hi}
+	}
+
+	m := queryCmp.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+	n, ok := parseOperand(strings.TrimSpace(m[2]))
+	if !ok {
+		return nil
+	}
+	if m[1] == "==" {
+		m[1] = "="
+	}
+
+	return &totalTest{op: m[1], lo: n}
+}
+
+// parseDiceTest parses a test of the kept dice, nil if s isn't one
+func parseDiceTest(s string) *diceTest {
+	m := queryDiceRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+
+	t := &diceTest{op: ">=", faceOp: "=", count: operand{n: 1}}
+	switch q := strings.ToLower(m[1]); {
+	case q == "any":
+	case q == "no":
+		t.op, t.count = "=", operand{}
+	default:
+		t.op = map[string]string{"at least": ">=", "at most": "<=", "exactly": "="}[q]
+		if m[2] != "" {
+			n, ok := countWord(strings.ToLower(m[2]))
+			if o, isVar := parseOperand(m[2]); isVar && o.name != "" {
+				t.count = o
+			} else if !ok {
+				return nil
+			} else {
+				t.count = operand{n: n}
+			}
+		}
+	}
+
+	t.face, _ = parseOperand(m[3])
+	switch more := strings.ToLower(m[4]); {
+	case more == "+" || strings.HasSuffix(more, "more") || strings.HasSuffix(more, "higher"):
+		t.faceOp = ">="
+	case more != "":
+		t.faceOp = "<="
+	}
+
+	return t
+}
+
+func compareInts(op string, a, b int) bool {
+	switch op {
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	case "!=":
+		return a != b
+	}
+
+	return a == b
+}
+
+// countWord reads a count written as digits or spelled out as Explain does, i.e 2, two or a
+func countWord(s string) (int, bool) {
+	if s == "a" || s == "an" {
+		return 1, true
+	}
+	for n, w := range numberWords {
+		if s == w {
+			return n, true
+		}
+	}
+	n, err := strconv.Atoi(s)
+
+	return n, err == nil
+}
+
+// IsMean reports whether the query asks for an expected total rather than a probability
+func (q *Query) IsMean() bool {
+	return q.kind == queryMean
+}
+
+// Vars returns the names of the variables referenced by the query, including those of the
+// opposing expression of a contest, in the order they first appear
+func (q *Query) Vars() []string {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+		add   = func(ops ...operand) {
+			for _, o := range ops {
+				if o.name != "" && !seen[o.name] {
+					seen[o.name] = true
+					names = append(names, o.name)
+				}
+			}
+		}
+	)
+
+	if q.total != nil {
+		add(q.total.lo, q.total.hi)
+	}
+	if q.dice != nil {
+		add(q.dice.count, q.dice.face)
+	}
+	if q.against != nil {
+		for _, name := range q.against.Vars() {
+			add(operand{name: name})
+		}
+	}
+	if c := q.given; c != nil && c.total != nil {
+		add(c.total.lo, c.total.hi)
+	} else if c != nil {
+		add(c.dice.count, c.dice.face)
+	}
+
+	return names
+}
+
+// String returns the query as it was parsed
+func (q *Query) String() string {
+	return q.src
+}
+
+// Exact answers the query about e exactly from the distributions of e and, for a contest, the
+// opposing expression. ErrInexact is returned if either distribution can't be computed exactly
+// or the query needs the dice of every roll, as a condition on the dice does; use sim.Answer to
+// estimate the answer instead. Queries about the dice alone are answered exactly for a single
+// dice term without modifiers, such as $n d10.
+func (q *Query) Exact(e *Expr, vars Vars) (Answer, error) {
+	a := Answer{Query: q.src, Exact: true, Given: 1}
+
+	if q.kind == queryDice {
+		if q.given != nil {
+			return Answer{Query: q.src}, ErrInexact
+		}
+		d, err := q.diceDistribution(e, vars)
+		if err != nil {
+			return Answer{Query: q.src}, err
+		}
+		count, _, _ := q.dice.bind(vars)
+		a.P = d.PFunc(count)
+		return a, nil
+	}
+	if q.given != nil && q.given.dice != nil {
+		return Answer{Query: q.src}, ErrInexact
+	}
+
+	d, err := e.Distribution(vars)
+	if err != nil {
+		return Answer{Query: q.src}, err
+	}
+
+	if q.given != nil {
+		test, err := q.given.total.bind(vars)
+		if err != nil {
+			return Answer{Query: q.src}, err
+		}
+
+		kept := make(Distribution)
+		for v, p := range d {
+			if test(v) {
+				kept[v] = p
+			}
+		}
+		if a.Given = kept.Total(); a.Given == 0 {
+			return a, fmt.Errorf("the condition of %q can never be met", q.src)
+		}
+		d = kept.Normalize()
+	}
+
+	switch q.kind {
+	case queryMean:
+		a.P = d.Mean()
+
+	case queryContest:
+		o, err := q.against.Distribution(vars)
+		if err != nil {
+			return Answer{Query: q.src}, err
+		}
+		for v, p := range d {
+			for w, r := range o {
+				switch {
+				case v > w:
+					a.Win += p * r
+				case v < w:
+					a.Lose += p * r
+				default:
+					a.Tie += p * r
+				}
+				if compareInts(q.op, v, w) {
+					a.P += p * r
+				}
+			}
+		}
+
+	default:
+		test, err := q.total.bind(vars)
+		if err != nil {
+			return Answer{Query: q.src}, err
+		}
+		a.P = d.PFunc(test)
+	}
+
+	return a, nil
+}
+
+// diceDistribution returns the distribution of the number of dice of e passing the query's face
+// test, when e is a single dice term without modifiers
+func (q *Query) diceDistribution(e *Expr, vars Vars) (Distribution, error) {
+	for _, name := range e.Vars() {
+		if _, ok := vars[name]; !ok {
+			return nil, &MissingVariableError{Name: name}
+		}
+	}
+
+	d, ok := unwrap(e.root).(*diceNode)
+	if !ok || len(d.mods) > 0 {
+		return nil, ErrInexact
+	}
+	_, face, err := q.dice.bind(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := distribution(d.count, vars)
+	if err != nil {
+		return nil, err
+	}
+	sides := Point(0)
+	if d.die.faces == nil {
+		if sides, err = distribution(d.sides, vars); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each die passes on its own, so the number passing is binomial
+	out := make(Distribution)
+	for s, ps := range sides {
+		die := d.die
+		if die.faces == nil {
+			die = NewDie(makeFaces(s))
+		}
+
+		p := die.Distribution().PFunc(func(v int) bool { return face(Face{N: v}) })
+		one := Distribution{0: 1 - p, 1: p}
+
+		for n, pn := range counts {
+			if n < 0 || die.faces.Len() == 0 {
+				n = 0
+			}
+			out.mix(one.Repeat(n), ps*pn)
+		}
+	}
+
+	return out, nil
+}
+
+// Sample rolls e, and the opposing expression of a contest, once using src and returns the
+// query's value for the roll: 1 or 0 for whether a probability query holds, the total for a
+// mean, or 1, 0 or -1 for a contest won, tied or lost. ok is false if the roll doesn't meet the
+// query's condition and should be ignored.
+func (q *Query) Sample(e *Expr, vars Vars, src Source) (v int, ok bool, err error) {
+	o, err := e.WithSource(src).Eval(vars)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if c := q.given; c != nil {
+		pass := false
+		if c.total != nil {
+			var test func(int) bool
+			if test, err = c.total.bind(vars); err == nil {
+				pass = test(o.Total)
+			}
+		} else {
+			pass, err = c.dice.holds(o.Results, vars)
+		}
+		if err != nil || !pass {
+			return 0, false, err
+		}
+	}
+
+	switch q.kind {
+	case queryMean:
+		return o.Total, true, nil
+
+	case queryContest:
+		other, err := q.against.WithSource(src).Eval(vars)
+		if err != nil {
+			return 0, false, err
+		}
+		switch {
+		case o.Total > other.Total:
+			return 1, true, nil
+		case o.Total < other.Total:
+			return -1, true, nil
+		}
+		return 0, true, nil
+
+	case queryDice:
+		pass, err := q.dice.holds(o.Results, vars)
+		if err != nil || !pass {
+			return 0, true, err
+		}
+		return 1, true, nil
+	}
+
+	test, err := q.total.bind(vars)
+	if err != nil {
+		return 0, false, err
+	}
+	if !test(o.Total) {
+		return 0, true, nil
+	}
+
+	return 1, true, nil
+}
+
+// Estimate builds the Answer to the query from the values returned by Sample for the rolls that
+// met its condition, counted by value, and the total number of rolls made
+func (q *Query) Estimate(counts map[int]int, rolls int) Answer {
+	a := Answer{Query: q.src}
+
+	for _, c := range counts {
+		a.Rolls += c
+	}
+	if rolls > 0 {
+		a.Given = float64(a.Rolls) / float64(rolls)
+	}
+	if a.Rolls == 0 {
+		a.StdErr = math.Inf(1)
+		return a
+	}
+	n := float64(a.Rolls)
+
+	switch q.kind {
+	case queryMean:
+		sum, sq := 0., 0.
+		for v, c := range counts {
+			sum += float64(v) * float64(c)
+			sq += float64(v) * float64(v) * float64(c)
+		}
+		a.P = sum / n
+		a.StdErr = math.Sqrt(math.Max(sq/n-a.P*a.P, 0) / n)
+		return a
+
+	case queryContest:
+		a.Win, a.Tie, a.Lose = float64(counts[1])/n, float64(counts[0])/n, float64(counts[-1])/n
+		for v, p := range map[int]float64{1: a.Win, 0: a.Tie, -1: a.Lose} {
+			if compareInts(q.op, v, 0) {
+				a.P += p
+			}
+		}
+
+	default:
+		a.P = float64(counts[1]) / n
+	}
+	a.StdErr = math.Sqrt(a.P * (1 - a.P) / n)
+
+	return a
+}
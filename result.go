@@ -7,8 +7,14 @@ import (
 
 // Result represents a set of dice rolls of a single die type
 type Result struct {
-	die   Die
-	rolls Faces
+	die     Die
+	rolls   Faces
+	dropped Faces
+	src     Source // used for explosions and rerolls, nil for the default source
+
+	// rollPos and dropPos are the positions in roll order of each face of rolls and dropped.
+	// They're nil when the order isn't known, and the kept faces are taken to come first.
+	rollPos, dropPos []int
 }
 
 // Die returns the Die of the result set.
@@ -16,16 +22,109 @@ func (r Result) Die() Die {
 	return r.die
 }
 
-// Satisfy the Sort interface
+// Faces returns the faces that remain in the result set, in the order they were rolled
+func (r Result) Faces() Faces {
+	return joinFaces(r.rolls, nil)
+}
+
+// Dropped returns the faces that were rolled but removed from the result set by Keep, KeepN,
+// Drop, DropN or replaced by a reroll, in the order they were removed
+func (r Result) Dropped() Faces {
+	return joinFaces(r.dropped, nil)
+}
+
+// RolledFace is a face of a Result and whether it was kept, see Rolled
+type RolledFace struct {
+	Face
+	Kept bool
+}
+
+// Rolled returns every face of the result, kept and dropped, in the order they were rolled. The
+// extra dice of explosions and rerolls follow every die rolled before them.
+func (r Result) Rolled() []RolledFace {
+	var (
+		rp, dp = r.positions()
+		out    = make([]RolledFace, 0, len(r.rolls)+len(r.dropped))
+		pos    = make([]int, 0, cap(out))
+	)
+	for i, f := range r.rolls {
+		out, pos = append(out, RolledFace{Face: f, Kept: true}), append(pos, rp[i])
+	}
+	for i, f := range r.dropped {
+		out, pos = append(out, RolledFace{Face: f}), append(pos, dp[i])
+	}
+
+	sort.Sort(byPos{out, pos})
+	return out
+}
+
+type byPos struct {
+	faces []RolledFace
+	pos   []int
+}
+
+func (b byPos) Len() int           { return len(b.faces) }
+func (b byPos) Less(i, j int) bool { return b.pos[i] < b.pos[j] }
+func (b byPos) Swap(i, j int) {
+	b.faces[i], b.faces[j] = b.faces[j], b.faces[i]
+	b.pos[i], b.pos[j] = b.pos[j], b.pos[i]
+}
+
+// positions returns rollPos and dropPos, or the kept faces followed by the dropped faces if the
+// roll order isn't known
+func (r Result) positions() ([]int, []int) {
+	if len(r.rollPos) == len(r.rolls) && len(r.dropPos) == len(r.dropped) {
+		return r.rollPos, r.dropPos
+	}
+
+	rp, dp := make([]int, len(r.rolls)), make([]int, len(r.dropped))
+	for i := range rp {
+		rp[i] = i
+	}
+	for i := range dp {
+		dp[i] = len(rp) + i
+	}
+
+	return rp, dp
+}
+
+// derive returns a Result with the die, source and dropped faces of r and no kept faces, for
+// the methods that select from r to fill. It also returns the roll positions of r's faces.
+func (r Result) derive() (out Result, rollPos []int) {
+	rp, dp := r.positions()
+	out = Result{die: r.die, dropped: joinFaces(r.dropped, nil), src: r.src}
+	if len(dp) > 0 {
+		out.dropPos = append([]int(nil), dp...)
+	}
+
+	return out, rp
+}
+
+// keep adds f, rolled at pos, to the kept faces
+func (r *Result) keep(f Face, pos int) {
+	r.rolls, r.rollPos = append(r.rolls, f), append(r.rollPos, pos)
+}
+
+// drop adds f, rolled at pos, to the dropped faces
+func (r *Result) drop(f Face, pos int) {
+	r.dropped, r.dropPos = append(r.dropped, f), append(r.dropPos, pos)
+}
+
+// Satisfy the Sort interface. Note that sorting a Result reorders the faces it shares with any
+// Result it was copied from; none of the Result methods sort in place.
 func (r Result) Len() int           { return len(r.rolls) }
 func (r Result) Less(i, j int) bool { return r.rolls[i].N < r.rolls[j].N }
-func (r Result) Swap(i, j int)      { r.rolls[i], r.rolls[j] = r.rolls[j], r.rolls[i] }
+func (r Result) Swap(i, j int) {
+	r.rolls[i], r.rolls[j] = r.rolls[j], r.rolls[i]
+	if len(r.rollPos) == len(r.rolls) {
+		r.rollPos[i], r.rollPos[j] = r.rollPos[j], r.rollPos[i]
+	}
+}
 
-// Satisfy the String interface
+// Satisfy the String interface. Faces are listed in the order they were rolled.
 func (r Result) String() string {
 	var out []string
 
-	sort.Sort(r)
 	for _, f := range r.rolls {
 		out = append(out, f.Value)
 	}
@@ -36,28 +135,33 @@ func (r Result) String() string {
 // MatchType provides readable identifiers for selecting HIGH/LOW values as keep or drop
 type MatchType int
 
-// MatchTypes for Result.Keep/Drop
+// MatchTypes for Result.Keep/Drop. HIGH, LOW and MIDDLE select by value, FIRST and LAST by the
+// order the dice were rolled in.
 const (
 	HIGH MatchType = iota
 	LOW
+	MIDDLE
+	FIRST
+	LAST
 )
 
-// Keep returns a new result struct containing the highest or lowest n results
+// Keep returns a new result struct containing the highest, lowest, middle, first or last n results.
+// Kept results stay in the order they were rolled. When n doesn't split the middle evenly
+// the extra die is dropped from the low end.
 func (r Result) Keep(n int, hl MatchType) Result {
-	out := Result{die: r.die}
-
 	// Erik, you sod.
 	if n < 1 || n > len(r.rolls) {
-		out.rolls = r.rolls
-		return out
+		return r.copy()
 	}
 
-	sort.Sort(r)
-	switch hl {
-	case HIGH:
-		out.rolls = r.rolls[len(r.rolls)-n:]
-	case LOW:
-		out.rolls = r.rolls[:n]
+	out, pos := r.derive()
+	picked := pick(r.Ints(), n, hl)
+	for i, f := range r.rolls {
+		if picked[i] {
+			out.keep(f, pos[i])
+		} else {
+			out.drop(f, pos[i])
+		}
 	}
 
 	return out
@@ -65,15 +169,22 @@ func (r Result) Keep(n int, hl MatchType) Result {
 
 // KeepN keeps all results included in match
 func (r Result) KeepN(match ...int) Result {
-	out := Result{die: r.die}
+	out, pos := r.derive()
 
-	for _, d := range r.rolls {
+	for i, d := range r.rolls {
+		isMatch := false
 		for _, m := range match {
 			if d.N == m {
-				out.rolls = append(out.rolls, d)
+				isMatch = true
 				break
 			}
 		}
+
+		if isMatch {
+			out.keep(d, pos[i])
+		} else {
+			out.drop(d, pos[i])
+		}
 	}
 
 	return out
@@ -81,30 +192,64 @@ func (r Result) KeepN(match ...int) Result {
 
 // Drop is provided for semantic completeness as it may be easier to think in terms of dropping HIGH/LOW rather than keeping
 func (r Result) Drop(n int, hl MatchType) Result {
-	out := Result{die: r.die}
-
 	// And here.
 	if n < 1 || n > len(r.rolls) {
-		out.rolls = r.rolls
-		return out
+		return r.copy()
+	}
+
+	out, pos := r.derive()
+	picked := pick(r.Ints(), n, hl)
+	for i, f := range r.rolls {
+		if picked[i] {
+			out.drop(f, pos[i])
+		} else {
+			out.keep(f, pos[i])
+		}
+	}
+
+	return out
+}
+
+// pick selects n of values by hl and reports which positions were selected. Ties between equal
+// values are broken by position.
+func pick(values []int, n int, hl MatchType) []bool {
+	var (
+		picked = make([]bool, len(values))
+		order  = make([]int, len(values))
+	)
+
+	for i := range order {
+		order[i] = i
+	}
+	if hl == HIGH || hl == LOW || hl == MIDDLE {
+		sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
 	}
 
-	sort.Sort(r)
+	var sel []int
 	switch hl {
 	case HIGH:
-		out.rolls = r.rolls[:len(r.rolls)-n]
-	case LOW:
-		out.rolls = r.rolls[n:]
+		sel = order[len(order)-n:]
+	case LOW, FIRST:
+		sel = order[:n]
+	case LAST:
+		sel = order[len(order)-n:]
+	case MIDDLE:
+		lo := (len(order) - n + 1) / 2
+		sel = order[lo : lo+n]
 	}
 
-	return out
+	for _, i := range sel {
+		picked[i] = true
+	}
+
+	return picked
 }
 
 // DropN removes all results included in match
 func (r Result) DropN(match ...int) Result {
-	out := Result{die: r.die}
+	out, pos := r.derive()
 
-	for _, d := range r.rolls {
+	for i, d := range r.rolls {
 		isMatch := false
 		for _, m := range match {
 			if d.N == m {
@@ -114,41 +259,128 @@ func (r Result) DropN(match ...int) Result {
 		}
 
 		if !isMatch {
-			out.rolls = append(out.rolls, d)
+			out.keep(d, pos[i])
+		} else {
+			out.drop(d, pos[i])
 		}
 	}
 
 	return out
 }
 
-// Explode recursively rerolls d Die for any results included in match and returns a completed
-// Result set with all exploded items
+// Explode rolls an extra die for every result included in match and returns the completed
+// Result set. Dice are checked in the order they were rolled and each extra die is added to the
+// end of the set, where it's checked in turn, so a die can explode any number of times. Use
+// ExplodeDepth to cap how far a single die can chain.
 func (r Result) Explode(match ...int) Result {
-	var x func(store, results Faces) (Faces, Faces)
-
-	x = func(store, results Faces) (Faces, Faces) {
-		for i, result := range results {
-			for _, m := range match {
-				if result.N == m {
-					// Roll the exploded die
-					results = append(results, r.die.Roll())
-					// Store the current match
-					store = append(store, result)
-					// Remove it from results so it doesn't get exploded again
-					results = append(results[:i], results[i+1:]...)
-					// Explode the resultant set
-					store, results = x(store, results)
-				}
+	return r.ExplodeDepth(0, match...)
+}
+
+// ExplodeDepth is Explode with each original die allowed to add at most depth extra dice
+// through its chain of explosions. A depth of 0 or less is unlimited.
+func (r Result) ExplodeDepth(depth int, match ...int) Result {
+	out, _ := r.explode(depth, 0, match)
+	return out
+}
+
+// explode is ExplodeDepth that stops once the set holds maxDice dice, if maxDice > 0. It also
+// returns the name of the limit, MaxExplodeDepth or MaxDice, that stopped a die from exploding.
+func (r Result) explode(depth, maxDice int, match []int) (Result, string) {
+	out := r.copy()
+
+	// gen records how many explosions deep each die is, only needed when depth is capped
+	var (
+		gen     []int
+		stopped string
+	)
+	if depth > 0 {
+		gen = make([]int, len(out.rolls), cap(out.rolls))
+	}
+
+	for i := 0; i < len(out.rolls); i++ {
+		if !matches(out.rolls[i].N, match) {
+			continue
+		}
+
+		if depth > 0 {
+			if gen[i] >= depth {
+				stopped = "MaxExplodeDepth"
+				continue
+			}
+			gen = append(gen, gen[i]+1)
+		}
+
+		if maxDice > 0 && len(out.rolls) >= maxDice {
+			return out, "MaxDice"
+		}
+
+		out.keep(r.die.RollWith(r.src), len(out.rolls)+len(out.dropped))
+	}
+
+	return out, stopped
+}
+
+// RerollN rerolls every result included in match until it isn't. The faces replaced are added
+// to Dropped. Results are returned unchanged if every face of the die is in match.
+func (r Result) RerollN(match ...int) Result {
+	out, _ := r.reroll(false, 0, match)
+	return out
+}
+
+// RerollOnceN rerolls every result included in match once, keeping the new roll whatever it is.
+// The faces replaced are added to Dropped.
+func (r Result) RerollOnceN(match ...int) Result {
+	out, _ := r.reroll(true, 0, match)
+	return out
+}
+
+// reroll is RerollN, or RerollOnceN if once is set, that rerolls a single die at most depth
+// times if depth > 0. It also reports whether a die still matched when it was stopped.
+func (r Result) reroll(once bool, depth int, match []int) (Result, bool) {
+	out := r.copy()
+	if once {
+		depth = 1
+	} else if !r.die.escapes(match) {
+		return out, false
+	}
+
+	stopped := false
+	for i := range out.rolls {
+		for n := 0; matches(out.rolls[i].N, match); n++ {
+			if depth > 0 && n >= depth {
+				stopped = !once
+				break
 			}
+			next := len(out.rolls) + len(out.dropped)
+			out.drop(out.rolls[i], out.rollPos[i])
+			out.rolls[i], out.rollPos[i] = r.die.RollWith(r.src), next
 		}
+	}
+
+	return out, stopped
+}
 
-		return store, results
+// escapes reports whether the die has a face that can be rolled that isn't in match, so that
+// rerolling matches until they don't will stop
+func (d Die) escapes(match []int) bool {
+	for i, f := range d.faces {
+		if !matches(f.N, match) && d.weight(i) > 0 {
+			return true
+		}
 	}
 
-	var store Faces
-	store, out := x(store, r.rolls)
+	return false
+}
+
+// matches reports whether n is included in match
+func matches(n int, match []int) bool {
+	for _, m := range match {
+		if n == m {
+			return true
+		}
+	}
 
-	return Result{die: r.die, rolls: append(out, store...)}
+	return false
 }
 
 // Ints returns just the number values (useful for running totals)
@@ -196,5 +428,22 @@ func (r Result) Sum() int {
 
 // Reroll rerolls the current Result set
 func (r Result) Reroll() Result {
-	return Roll(len(r.Ints()), r.die)
+	return RollWith(r.src, len(r.Ints()), r.die)
+}
+
+// copy returns a copy of r that can be modified without changing r, with its roll order
+func (r Result) copy() Result {
+	out, pos := r.derive()
+	out.rolls = joinFaces(r.rolls, nil)
+	out.rollPos = append(make([]int, 0, cap(out.rolls)), pos...)
+
+	return out
+}
+
+// joinFaces returns a new Faces containing a followed by b so that appending to the
+// output never writes into the backing array of either input
+func joinFaces(a, b Faces) Faces {
+	out := make(Faces, 0, len(a)+len(b))
+	out = append(out, a...)
+	return append(out, b...)
 }
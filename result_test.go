@@ -0,0 +1,404 @@
+package roll
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// seqSource rolls the faces numbered by its values in turn, for dice numbered from 1
+type seqSource []int
+
+func (s *seqSource) Intn(n int) int {
+	v := (*s)[0]
+	*s = (*s)[1:]
+	return v - 1
+}
+
+func rollSeq(n int, d Die, seq ...int) Result {
+	src := seqSource(seq)
+	return RollWith(&src, n, d)
+}
+
+func TestExplodeOrder(t *testing.T) {
+	// The original dice are checked first, then each extra die in the order it was rolled
+	r := rollSeq(3, D6, 6, 2, 6, 6, 3, 6, 1).Explode(6)
+	if got, want := r.Ints(), []int{6, 2, 6, 6, 3, 6, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Every matching die triggers exactly one extra die
+	r = rollSeq(4, D6, 5, 6, 5, 1, 6, 5, 2, 3, 1).Explode(5, 6)
+	if got, want := r.Ints(), []int{5, 6, 5, 1, 6, 5, 2, 3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(r.Dropped()) != 0 {
+		t.Errorf("explosion dropped %v", r.Dropped())
+	}
+
+	// Nothing matching rolls nothing more
+	r = rollSeq(3, D6, 1, 2, 3).Explode(6)
+	if got, want := r.Ints(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExplodeDepth(t *testing.T) {
+	sixes := func(n int) []int {
+		s := make([]int, n)
+		for i := range s {
+			s[i] = 6
+		}
+		return s
+	}
+
+	for _, c := range []struct {
+		depth, dice int
+	}{
+		{1, 4},
+		{2, 6},
+		{5, 12},
+	} {
+		r := rollSeq(2, D6, sixes(20)...).ExplodeDepth(c.depth, 6)
+		if r.Len() != c.dice {
+			t.Errorf("depth %d: rolled %d dice, want %d", c.depth, r.Len(), c.dice)
+		}
+	}
+
+	// The depth is per original die, so a die that stops exploding doesn't use up the others'
+	r := rollSeq(2, D6, 6, 6, 1, 6, 6).ExplodeDepth(2, 6)
+	if got, want := r.Ints(), []int{6, 6, 1, 6, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A die that always matches stops at the cap
+	one := NewDie(Faces{{N: 1, Value: "1"}})
+	if r := Roll(1, one).ExplodeDepth(10, 1); r.Len() != 11 {
+		t.Errorf("d1 exploding to depth 10 rolled %d dice, want 11", r.Len())
+	}
+}
+
+func TestExplodeKeepDrop(t *testing.T) {
+	// Exploding first makes the extra dice candidates for the keep
+	r := rollSeq(3, D6, 6, 1, 4, 5).Explode(6).Keep(2, HIGH)
+	if r.Sum() != 11 || len(r.Dropped()) != 2 {
+		t.Errorf("explode then keep: %v dropping %v, want 6+5 dropping 2", r.Ints(), r.Dropped())
+	}
+
+	// Keeping first only explodes the dice kept, and the dropped dice stay dropped
+	r = rollSeq(3, D6, 6, 1, 4, 2).Keep(1, HIGH).Explode(6)
+	if got, want := r.Ints(), []int{6, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keep then explode: got %v, want %v", got, want)
+	}
+	if len(r.Dropped()) != 2 {
+		t.Errorf("keep then explode dropped %v, want 2 dice", r.Dropped())
+	}
+
+	// Dropping a die that matched doesn't take back its explosion
+	r = rollSeq(2, D6, 6, 3, 2).Explode(6).Drop(1, HIGH)
+	if r.Sum() != 5 || len(r.Dropped()) != 1 || r.Dropped()[0].N != 6 {
+		t.Errorf("explode then drop: %v dropping %v, want 3+2 dropping 6", r.Ints(), r.Dropped())
+	}
+}
+
+// explodeRecursive is the recursive Explode this package used to have, kept to benchmark against
+func explodeRecursive(r Result, match ...int) Result {
+	var x func(store, results Faces) (Faces, Faces)
+
+	x = func(store, results Faces) (Faces, Faces) {
+		for i, result := range results {
+			for _, m := range match {
+				if result.N == m {
+					results = append(results, r.die.RollWith(r.src))
+					store = append(store, result)
+					results = append(results[:i], results[i+1:]...)
+					store, results = x(store, results)
+				}
+			}
+		}
+
+		return store, results
+	}
+
+	var store Faces
+	store, out := x(store, joinFaces(r.rolls, nil))
+
+	return Result{die: r.die, rolls: append(out, store...), dropped: r.dropped}
+}
+
+func benchmarkExplode(b *testing.B, n int, explode func(r Result) Result) {
+	src := rand.New(rand.NewSource(1))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		explode(RollWith(src, n, D6))
+	}
+}
+
+func BenchmarkExplode100(b *testing.B) {
+	benchmarkExplode(b, 100, func(r Result) Result { return r.Explode(6) })
+}
+
+func BenchmarkExplodeRecursive100(b *testing.B) {
+	benchmarkExplode(b, 100, func(r Result) Result { return explodeRecursive(r, 6) })
+}
+
+func BenchmarkExplode1000(b *testing.B) {
+	benchmarkExplode(b, 1000, func(r Result) Result { return r.Explode(6) })
+}
+
+func BenchmarkExplodeRecursive1000(b *testing.B) {
+	benchmarkExplode(b, 1000, func(r Result) Result { return explodeRecursive(r, 6) })
+}
+
+// testPool is a pool of 1d6, 1d8 and 1d10 rolled as [6 1] [8 3] [6]
+func testPool() Results {
+	return Results{rollSeq(2, D6, 6, 1), rollSeq(2, D8, 8, 3), rollSeq(1, D10, 6)}
+}
+
+// poolInts returns the kept and dropped numbers of each result of a pool
+func poolInts(r Results) (kept, dropped [][]int) {
+	for _, result := range r {
+		var d []int
+		for _, f := range result.Dropped() {
+			d = append(d, f.N)
+		}
+		kept, dropped = append(kept, result.Ints()), append(dropped, d)
+	}
+
+	return kept, dropped
+}
+
+func TestResultsCount(t *testing.T) {
+	r := testPool()
+
+	for _, c := range []struct {
+		name      string
+		got, want int
+	}{
+		{"Sum", r.Sum(), 24},
+		{"Min", r.Min(), 5},
+		{"Max", r.Max(), 38},
+		{"Count(6)", r.Count(6), 2},
+		{"Count(1, 8)", r.Count(1, 8), 2},
+		{"Count(2)", r.Count(2), 0},
+		{"Count()", r.Count(), 0},
+		{"CountFunc(>= 6)", r.CountFunc(func(f Face) bool { return f.N >= 6 }), 3},
+		{"CountFunc(even)", r.CountFunc(func(f Face) bool { return f.N%2 == 0 }), 3},
+		{"Sum of kept", r.Keep(2, HIGH).Sum(), 14},
+		{"Count of kept", r.Keep(2, LOW).Count(6), 0},
+		{"Sum of nothing", Results{}.Sum(), 0},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestResultsKeepDrop(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		got           Results
+		kept, dropped [][]int
+	}{
+		// Equal values are selected in pool order, so the d10's 6 ranks above the d6's
+		{"Keep(2, HIGH)", testPool().Keep(2, HIGH), [][]int{nil, {8}, {6}}, [][]int{{6, 1}, {3}, nil}},
+		{"Keep(2, LOW)", testPool().Keep(2, LOW), [][]int{{1}, {3}, nil}, [][]int{{6}, {8}, {6}}},
+		{"Keep(1, MIDDLE)", testPool().Keep(1, MIDDLE), [][]int{{6}, nil, nil}, [][]int{{1}, {8, 3}, {6}}},
+		{"Keep(2, FIRST)", testPool().Keep(2, FIRST), [][]int{{6, 1}, nil, nil}, [][]int{nil, {8, 3}, {6}}},
+		{"Keep(2, LAST)", testPool().Keep(2, LAST), [][]int{nil, {3}, {6}}, [][]int{{6, 1}, {8}, nil}},
+		{"Drop(2, HIGH)", testPool().Drop(2, HIGH), [][]int{{6, 1}, {3}, nil}, [][]int{nil, {8}, {6}}},
+		{"Drop(1, LOW)", testPool().Drop(1, LOW), [][]int{{6}, {8, 3}, {6}}, [][]int{{1}, nil, nil}},
+		{"Drop(3, FIRST)", testPool().Drop(3, FIRST), [][]int{nil, {3}, {6}}, [][]int{{6, 1}, {8}, nil}},
+		{"Keep(0, HIGH)", testPool().Keep(0, HIGH), [][]int{{6, 1}, {8, 3}, {6}}, [][]int{nil, nil, nil}},
+		{"Keep(6, HIGH)", testPool().Keep(6, HIGH), [][]int{{6, 1}, {8, 3}, {6}}, [][]int{nil, nil, nil}},
+		{"Keep then Drop", testPool().Keep(4, HIGH).Drop(1, LOW), [][]int{{6}, {8}, {6}}, [][]int{{1}, {3}, nil}},
+	} {
+		kept, dropped := poolInts(c.got)
+		if !reflect.DeepEqual(kept, c.kept) || !reflect.DeepEqual(dropped, c.dropped) {
+			t.Errorf("%s: kept %v dropping %v, want %v dropping %v", c.name, kept, dropped, c.kept, c.dropped)
+		}
+		for i, d := range []Die{D6, D8, D10} {
+			if c.got[i].Die().Sides() != d.Sides() {
+				t.Errorf("%s: result %d is a d%d, want a d%d", c.name, i, c.got[i].Die().Sides(), d.Sides())
+			}
+		}
+	}
+
+	// The pool kept from is left as it was
+	r := testPool()
+	r.Keep(1, HIGH)
+	r.Drop(2, LOW)
+	if kept, dropped := poolInts(r); !reflect.DeepEqual(kept, [][]int{{6, 1}, {8, 3}, {6}}) || !reflect.DeepEqual(dropped, [][]int{nil, nil, nil}) {
+		t.Errorf("keeping and dropping changed the pool to %v dropping %v", kept, dropped)
+	}
+}
+
+func TestResultsCortex(t *testing.T) {
+	all := func(Face) bool { return true }
+	pool := func(faces ...int) Results {
+		var r Results
+		for i, d := range []Die{D4, D6, D8, D10, D12} {
+			r = append(r, rollSeq(1, d, faces[i]))
+		}
+		return r
+	}
+
+	for _, c := range []struct {
+		name        string
+		pool        Results
+		n           int
+		total       int
+		effect      Die
+		hitches     int
+		totalKept   [][]int // dice of each result used for the total
+		effectIndex int     // index in the pool of the effect die, -1 if there is none
+	}{
+		// 7 and one of the 4s make the total, the d6's 4 rather than the d8's, leaving the d12
+		{"two dice", pool(1, 4, 4, 7, 2), 2, 11, D12, 1, [][]int{nil, {4}, nil, {7}, nil}, 4},
+		{"the rest", pool(1, 4, 4, 7, 2), 4, 17, D4, 1, [][]int{nil, {4}, {4}, {7}, {2}}, -1},
+		{"more than the pool", pool(1, 4, 4, 7, 2), 9, 17, D4, 1, [][]int{nil, {4}, {4}, {7}, {2}}, -1},
+		{"no total", pool(3, 6, 5, 9, 12), 0, 0, D12, 0, [][]int{nil, nil, nil, nil, nil}, 4},
+		{"all hitches", pool(1, 1, 1, 1, 1), 2, 0, D4, 5, [][]int{nil, nil, nil, nil, nil}, -1},
+		{"ties left to the larger die", pool(4, 4, 4, 4, 4), 1, 4, D12, 0, [][]int{{4}, nil, nil, nil, nil}, 4},
+	} {
+		o := c.pool.Cortex(c.n)
+
+		if o.Total != c.total || o.Effect.Sides() != c.effect.Sides() || o.Hitches != c.hitches {
+			t.Errorf("%s: got total %d, effect d%d, %d hitches, want %d, d%d, %d",
+				c.name, o.Total, o.Effect.Sides(), o.Hitches, c.total, c.effect.Sides(), c.hitches)
+		}
+		if kept, _ := poolInts(o.TotalDice); !reflect.DeepEqual(kept, c.totalKept) || o.TotalDice.Sum() != c.total {
+			t.Errorf("%s: used %v for the total, want %v", c.name, kept, c.totalKept)
+		}
+
+		if c.effectIndex < 0 {
+			if o.EffectDice != nil {
+				t.Errorf("%s: got effect dice %v, want none", c.name, o.EffectDice)
+			}
+			continue
+		}
+		if kept, _ := poolInts(o.EffectDice); len(kept[c.effectIndex]) != 1 || o.EffectDice.CountFunc(all) != 1 {
+			t.Errorf("%s: got effect dice %v, want the die of result %d", c.name, kept, c.effectIndex)
+		}
+	}
+}
+
+// rolled returns the numbers of r in roll order, with the dropped numbers negated
+func rolled(r Result) []int {
+	var out []int
+	for _, f := range r.Rolled() {
+		if f.Kept {
+			out = append(out, f.N)
+		} else {
+			out = append(out, -f.N)
+		}
+	}
+
+	return out
+}
+
+func TestRolledOrder(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		got  Result
+		want []int
+	}{
+		{"Keep", rollSeq(4, D6, 3, 6, 1, 5).Keep(2, HIGH), []int{-3, 6, -1, 5}},
+		{"Drop", rollSeq(4, D6, 3, 6, 1, 5).Drop(1, LOW), []int{3, 6, -1, 5}},
+		{"KeepN", rollSeq(4, D6, 3, 6, 1, 5).KeepN(1, 3), []int{3, -6, 1, -5}},
+		{"DropN", rollSeq(4, D6, 3, 6, 1, 5).DropN(6), []int{3, -6, 1, 5}},
+		{"Keep then Drop", rollSeq(4, D6, 3, 6, 1, 5).Drop(1, HIGH).Keep(1, LOW), []int{-3, -6, 1, -5}},
+		{"Explode then Keep", rollSeq(3, D6, 6, 2, 4, 5).Explode(6).Keep(2, LOW), []int{-6, 2, 4, -5}},
+		{"Keep then Explode", rollSeq(3, D6, 2, 6, 1, 4).Keep(2, HIGH).Explode(6), []int{2, 6, -1, 4}},
+		// The rerolled die is replaced by a die rolled after all the others
+		{"RerollN", rollSeq(3, D6, 1, 4, 1, 2, 3).RerollN(1), []int{-1, 4, -1, 2, 3}},
+		{"RerollN twice", rollSeq(2, D6, 1, 4, 1, 5).RerollN(1), []int{-1, 4, -1, 5}},
+		{"RerollOnceN then Keep", rollSeq(2, D6, 1, 4, 2).RerollOnceN(1).Keep(1, HIGH), []int{-1, 4, -2}},
+		{"Results.Keep", testPool().Keep(2, LOW)[1], []int{-8, 3}},
+	} {
+		if got := rolled(c.got); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: rolled %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// Sorting a Result's kept faces doesn't change the order they were rolled in
+	r := rollSeq(4, D6, 3, 6, 1, 5).Keep(3, HIGH)
+	sort.Sort(r)
+	if got, want := rolled(r), []int{3, 6, -1, 5}; !reflect.DeepEqual(got, want) || !reflect.DeepEqual(r.Ints(), []int{3, 5, 6}) {
+		t.Errorf("sorted %v, rolled %v, want %v", r.Ints(), got, want)
+	}
+}
+
+func TestKeepDropUnchanged(t *testing.T) {
+	for name, fn := range map[string]func(Result) Result{
+		"Keep":        func(r Result) Result { return r.Keep(2, HIGH) },
+		"Keep all":    func(r Result) Result { return r.Keep(5, HIGH) },
+		"Drop":        func(r Result) Result { return r.Drop(2, LOW) },
+		"KeepN":       func(r Result) Result { return r.KeepN(6) },
+		"DropN":       func(r Result) Result { return r.DropN(6) },
+		"Explode":     func(r Result) Result { return r.Explode(6) },
+		"RerollN":     func(r Result) Result { return r.RerollN(1) },
+		"RerollOnceN": func(r Result) Result { return r.RerollOnceN(1) },
+		"then Keep": func(r Result) Result {
+			r.Keep(1, LOW)
+			return r.Keep(2, HIGH).Keep(1, LOW)
+		},
+	} {
+		// src feeds explosions and rerolls
+		src := seqSource{3, 3, 3, 3, 3, 3, 3, 3}
+		r := rollSeq(5, D6, 6, 1, 4, 6, 2).Drop(1, LOW)
+		r.src = &src
+		before := rolled(r)
+
+		out := fn(r)
+		out.keep(Face{N: 9, Value: "9"}, 99)
+		out.drop(Face{N: 9, Value: "9"}, 99)
+
+		if got := rolled(r); !reflect.DeepEqual(got, before) || !reflect.DeepEqual(r.Ints(), []int{6, 4, 6, 2}) {
+			t.Errorf("%s: changed %v to %v", name, before, got)
+		}
+	}
+}
+
+func TestKeepSyntax(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		want []int
+	}{
+		{"5d6Kh2", []int{-3, 6, -1, 5, -2}},
+		{"5d6Kl2", []int{-3, -6, 1, -5, 2}},
+		{"5d6Km3", []int{3, -6, -1, 5, 2}},
+		{"5d6Km2", []int{3, -6, -1, 5, -2}},
+		{"5d6Kf2", []int{3, 6, -1, -5, -2}},
+		{"5d6Ke2", []int{-3, -6, -1, 5, 2}},
+		{"5d6Dh2", []int{3, -6, 1, -5, 2}},
+		{"5d6Dl2", []int{3, 6, -1, 5, -2}},
+		{"5d6Dm1", []int{-3, 6, 1, 5, 2}},
+		{"5d6Df2", []int{-3, -6, 1, 5, 2}},
+		{"5d6De2", []int{3, 6, 1, -5, -2}},
+		{"5d6Kn3,6", []int{3, 6, -1, -5, -2}},
+		{"5d6Dn3,6", []int{-3, -6, 1, 5, 2}},
+		{"5d6Kh4Kl2", []int{3, -6, -1, -5, 2}},
+	} {
+		e := MustParse(c.s)
+
+		src := seqSource{3, 6, 1, 5, 2}
+		o, err := e.WithSource(&src).Eval(nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.s, err)
+			continue
+		}
+		if got := rolled(o.Results[0]); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: rolled %v, want %v", c.s, got, c.want)
+		}
+
+		// RollN selects the same dice without building Results
+		src = seqSource{3, 6, 1, 5, 2}
+		e.WithSource(&src).RollN(1, func(total int) {
+			if total != o.Total {
+				t.Errorf("%s: RollN total %d, Eval %d", c.s, total, o.Total)
+			}
+		})
+	}
+}
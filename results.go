@@ -1,6 +1,9 @@
 package roll
 
-// Results is used for a collection of Result structs representing difference Die types
+import "sort"
+
+// Results is used for a collection of Result structs representing difference Die types. Taken
+// together they form a dice pool, i.e 1d6+1d8+1d10.
 type Results []Result
 
 // Dice returns the number and die types of all results in the current set
@@ -14,32 +17,227 @@ func (r Results) Dice() Set {
 	return s
 }
 
-// Min returns the minimum possible roll of any result in the set
+// Min returns the minimum possible total of the pool
 func (r Results) Min() int {
-	min := r.Max()
+	t := 0
 
 	for _, result := range r {
-		m := result.Min()
-		if m < min {
-			min = m
-		}
+		t += result.Min()
 	}
 
-	return min
+	return t
 }
 
-// Max returns the maximum possible roll of any result in the set
+// Max returns the maximum possible total of the pool
 func (r Results) Max() int {
-	max := 0
+	t := 0
+
+	for _, result := range r {
+		t += result.Max()
+	}
+
+	return t
+}
+
+// Sum returns the total numerical value of every result in the pool
+func (r Results) Sum() int {
+	t := 0
+
+	for _, result := range r {
+		t += result.Sum()
+	}
+
+	return t
+}
+
+// Count returns the number of dice in the pool that match any of match
+func (r Results) Count(match ...int) int {
+	return r.CountFunc(func(f Face) bool {
+		for _, m := range match {
+			if f.N == m {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// CountFunc returns the number of dice in the pool for which fn returns true, i.e counting
+// successes on 8 or more:
+//
+//	r.CountFunc(func(f roll.Face) bool { return f.N >= 8 })
+func (r Results) CountFunc(fn func(Face) bool) int {
+	c := 0
 
 	for _, result := range r {
-		m := result.Max()
-		if m > max {
-			max = m
+		for _, f := range result.rolls {
+			if fn(f) {
+				c++
+			}
 		}
 	}
 
-	return max
+	return c
+}
+
+// Keep returns a new pool containing the highest, lowest, middle, first or last n dice across
+// every result, regardless of die type. Each Result keeps its own Die and the dice that weren't
+// kept are added to its Dropped faces. FIRST and LAST count through the results in order.
+func (r Results) Keep(n int, hl MatchType) Results {
+	return r.pickDice(n, hl, true)
+}
+
+// Drop returns a new pool with the highest, lowest, middle, first or last n dice across every
+// result removed
+func (r Results) Drop(n int, hl MatchType) Results {
+	return r.pickDice(n, hl, false)
+}
+
+// pickDice selects n dice from the pool by hl and returns a new pool that contains either the
+// selected dice or the rest
+func (r Results) pickDice(n int, hl MatchType, keep bool) Results {
+	// Nothing to select
+	if n < 1 || n > r.len() {
+		return r.copy()
+	}
+
+	var (
+		pool   = r.pool()
+		values = make([]int, len(pool))
+		kept   []poolDie
+	)
+	for i, d := range pool {
+		values[i] = d.face.N
+	}
+
+	for i, picked := range pick(values, n, hl) {
+		if picked == keep {
+			kept = append(kept, pool[i])
+		}
+	}
+
+	return r.selectDice(kept)
+}
+
+// CortexOutcome is the result of reading a pool with Cortex Prime rules
+type CortexOutcome struct {
+	// Total is the sum of the dice picked for the total
+	Total int
+	// Effect is the largest die left once the total has been picked, D4 if there is none
+	Effect Die
+	// Hitches is the number of dice that rolled a 1
+	Hitches int
+	// TotalDice and EffectDice are the dice that were used for the total and effect, as pools
+	TotalDice, EffectDice Results
+}
+
+// Cortex picks the n highest dice of the pool for the total and then the largest remaining die
+// for the effect, as in Cortex Prime. Dice that rolled a 1 (hitches) can't be used for either.
+// Where dice of equal value could be picked for the total the smaller die is used, leaving the
+// larger for the effect.
+func (r Results) Cortex(n int) CortexOutcome {
+	var (
+		out   = CortexOutcome{Effect: D4}
+		avail []poolDie
+	)
+
+	for _, d := range r.pool() {
+		if d.face.N == 1 {
+			out.Hitches++
+			continue
+		}
+		avail = append(avail, d)
+	}
+
+	// Highest values first, smaller dice first among equal values
+	sort.SliceStable(avail, func(i, j int) bool {
+		if avail[i].face.N != avail[j].face.N {
+			return avail[i].face.N > avail[j].face.N
+		}
+		return avail[i].die.Sides() < avail[j].die.Sides()
+	})
+
+	if n > len(avail) {
+		n = len(avail)
+	}
+	total, rest := avail[:n], avail[n:]
+	for _, d := range total {
+		out.Total += d.face.N
+	}
+	out.TotalDice = r.selectDice(total)
+
+	if len(rest) > 0 {
+		effect := rest[0]
+		for _, d := range rest[1:] {
+			if d.die.Sides() > effect.die.Sides() {
+				effect = d
+			}
+		}
+		out.Effect = effect.die
+		out.EffectDice = r.selectDice([]poolDie{effect})
+	}
+
+	return out
+}
+
+// poolDie is a single die of a pool, identified by the result it belongs to and its position
+type poolDie struct {
+	die    Die
+	face   Face
+	result int
+	index  int
+}
+
+func (r Results) pool() []poolDie {
+	var pool []poolDie
+
+	for i, result := range r {
+		for j, f := range result.rolls {
+			pool = append(pool, poolDie{die: result.die, face: f, result: i, index: j})
+		}
+	}
+
+	return pool
+}
+
+// len returns the number of dice in the pool
+func (r Results) len() int {
+	n := 0
+
+	for _, result := range r {
+		n += len(result.rolls)
+	}
+
+	return n
+}
+
+// selectDice returns a new pool in which each result keeps only the dice in keep
+func (r Results) selectDice(keep []poolDie) Results {
+	selected := make(map[[2]int]bool)
+	for _, d := range keep {
+		selected[[2]int{d.result, d.index}] = true
+	}
+
+	out := make(Results, len(r))
+	for i, result := range r {
+		var pos []int
+		out[i], pos = result.derive()
+		for j, f := range result.rolls {
+			if selected[[2]int{i, j}] {
+				out[i].keep(f, pos[j])
+			} else {
+				out[i].drop(f, pos[j])
+			}
+		}
+	}
+
+	return out
+}
+
+func (r Results) copy() Results {
+	out := make(Results, len(r))
+	copy(out, r)
+	return out
 }
 
 // Reroll rerolls the current Results set
@@ -12,10 +12,16 @@ type Roller interface {
 
 // Roll rolls n Die and returns a result set
 func Roll(n int, d Die) Result {
-	r := Result{die: d}
+	return RollWith(nil, n, d)
+}
+
+// RollWith rolls n Die using src and returns a result set. The Result continues to use src for
+// explosions and rerolls. A nil src uses the default source.
+func RollWith(src Source, n int, d Die) Result {
+	r := Result{die: d, src: src}
 
 	for i := 0; i < n; i++ {
-		r.rolls = append(r.rolls, d.Roll())
+		r.keep(d.RollWith(src), i)
 	}
 
 	return r
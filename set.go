@@ -5,10 +5,16 @@ type Set []Dice
 
 // Roll all items in a set and return the Results
 func (s Set) Roll() Results {
+	return s.RollWith(nil)
+}
+
+// RollWith rolls all items in a set using src and returns the Results. A nil src uses the
+// default source.
+func (s Set) RollWith(src Source) Results {
 	var r Results
 
 	for _, d := range s {
-		r = append(r, d.Roll())
+		r = append(r, d.RollWith(src))
 	}
 
 	return r
@@ -35,3 +41,14 @@ func (s Set) Max() int {
 
 	return t
 }
+
+// Distribution returns the probability of every possible sum of a roll of Set s
+func (s Set) Distribution() Distribution {
+	out := Point(0)
+
+	for _, d := range s {
+		out = out.Add(d.Distribution())
+	}
+
+	return out
+}
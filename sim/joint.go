@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/nboughton/go-roll"
+)
+
+// JointFunc returns one value for each component of an outcome using rng for all randomness
+type JointFunc func(rng *rand.Rand) []int
+
+// JointHistogram is the result of RunJoint: the estimated probability of every outcome that was
+// rolled and the number of rolls made
+type JointHistogram struct {
+	*roll.Joint
+	Rolls int
+}
+
+// StdErr returns the standard error of the estimated probability of values
+func (h *JointHistogram) StdErr(values ...int) float64 {
+	return StdErr(h.P(values...), h.Rolls)
+}
+
+// RunJoint calls fn repeatedly across opts.Workers goroutines, as RunFunc does, and returns the
+// joint distribution of its outcomes over the components names. Precision applies to the
+// probability of every outcome.
+func RunJoint(ctx context.Context, names []string, fn JointFunc, opts Options) (*JointHistogram, error) {
+	// Outcomes are numbered as they're first seen so that they can be counted like totals
+	var (
+		mu       sync.Mutex
+		ids      = make(map[string]int)
+		outcomes [][]int
+	)
+
+	h, err := run(ctx, opts, func(rng *rand.Rand, n int, b *batch) error {
+		local := make(map[string]int)
+		seen := make(map[string][]int)
+		for i := 0; i < n; i++ {
+			values := fn(rng)
+			k := fmt.Sprint(values)
+			if _, ok := seen[k]; !ok {
+				seen[k] = values
+			}
+			local[k]++
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for k, c := range local {
+			if len(seen[k]) != len(names) {
+				return fmt.Errorf("joint function returned %d values for %d components", len(seen[k]), len(names))
+			}
+
+			id, ok := ids[k]
+			if !ok {
+				id = len(outcomes)
+				ids[k] = id
+				outcomes = append(outcomes, seen[k])
+			}
+			b.counts[id] += c
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	out := &JointHistogram{Joint: roll.NewJoint(names...), Rolls: h.Rolls}
+	for id, c := range h.Counts {
+		out.AddOutcome(float64(c)/float64(h.Rolls), outcomes[id]...)
+	}
+
+	return out, err
+}
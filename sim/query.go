@@ -0,0 +1,45 @@
+package sim
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/nboughton/go-roll"
+)
+
+// RunQuery estimates the answer to q about e by simulation, as Run does. Precision applies to
+// the values recorded for each roll rather than to P itself, see roll.Query.Sample. An error
+// from Sample stops the simulation and is returned.
+func RunQuery(ctx context.Context, e *roll.Expr, q *roll.Query, opts Options) (roll.Answer, error) {
+	// Check that the expressions' variables are bound before starting any workers
+	if _, _, err := q.Sample(e, opts.Vars, nil); err != nil {
+		return roll.Answer{Query: q.String()}, err
+	}
+
+	h, err := run(ctx, opts, func(rng *rand.Rand, n int, b *batch) error {
+		for i := 0; i < n; i++ {
+			v, ok, err := q.Sample(e, opts.Vars, rng)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				b.excluded++
+				continue
+			}
+			b.counts[v]++
+		}
+		return nil
+	})
+
+	return q.Estimate(h.Counts, h.Rolls+h.excluded), err
+}
+
+// Answer answers q about e exactly if it can and otherwise estimates it by simulation
+func Answer(ctx context.Context, e *roll.Expr, q *roll.Query, opts Options) (roll.Answer, error) {
+	a, err := q.Exact(e, opts.Vars)
+	if err != roll.ErrInexact {
+		return a, err
+	}
+
+	return RunQuery(ctx, e, q, opts)
+}
@@ -0,0 +1,353 @@
+// Package sim estimates the distribution of dice expressions by Monte Carlo simulation. Rolls
+// are spread across worker goroutines, each with its own random stream, and the totals are
+// aggregated into a Histogram that reports standard errors and confidence intervals for the
+// probability of every value. A simulation can be cancelled with a context and can stop as
+// soon as a target precision is reached.
+package sim
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nboughton/go-roll"
+)
+
+// Options configure a simulation
+type Options struct {
+	// Workers is the number of goroutines rolling dice, runtime.NumCPU() if 0
+	Workers int
+	// Rolls is the maximum number of rolls. If Precision is 0 exactly Rolls rolls are made.
+	// If both are 0, DefaultRolls are made.
+	Rolls int
+	// Precision stops the simulation once the standard error of the probability of every
+	// value is at or below it, i.e 0.001 for ±0.1%. 0 disables the check.
+	Precision float64
+	// MinRolls is the number of rolls made before Precision is checked, DefaultMinRolls if 0
+	MinRolls int
+	// BatchSize is the number of rolls a worker makes between reports, DefaultBatchSize if 0
+	BatchSize int
+	// Seed is used to derive each worker's random stream. A time based seed is used if 0.
+	// Batches are shared between workers as they become free, so a seed doesn't make the
+	// simulation as a whole reproducible.
+	Seed int64
+	// Vars binds the variables of the expression
+	Vars roll.Vars
+}
+
+// Defaults used when Options are left unset
+const (
+	DefaultRolls     = 100000
+	DefaultMinRolls  = 10000
+	DefaultBatchSize = 10000
+)
+
+// Func returns a single total using rng for all randomness. It's used by RunFunc to simulate
+// anything that can't be written as an expression.
+type Func func(rng *rand.Rand) int
+
+// Run simulates e, see RunFunc. An error rolling e, such as a *roll.LimitError from a die that
+// explodes too often, stops the simulation and is returned with the rolls made so far.
+func Run(ctx context.Context, e *roll.Expr, opts Options) (*Histogram, error) {
+	// Check that the expression's variables are bound before starting any workers
+	if err := e.EvalN(0, opts.Vars, nil); err != nil {
+		return nil, err
+	}
+
+	return run(ctx, opts, func(rng *rand.Rand, n int, b *batch) error {
+		return e.WithSource(rng).EvalN(n, opts.Vars, func(total int) { b.counts[total]++ })
+	})
+}
+
+// Distribution returns the exact distribution of e where it can be computed and otherwise one
+// estimated by Run, reporting which it is
+func Distribution(ctx context.Context, e *roll.Expr, opts Options) (roll.Distribution, bool, error) {
+	d, err := e.Distribution(opts.Vars)
+	if err != roll.ErrInexact {
+		return d, err == nil, err
+	}
+
+	h, err := Run(ctx, e, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return h.Distribution(), false, nil
+}
+
+// RunFunc calls fn repeatedly across opts.Workers goroutines and returns a Histogram of its
+// results. If ctx is cancelled the rolls made so far are returned with ctx's error.
+func RunFunc(ctx context.Context, fn Func, opts Options) (*Histogram, error) {
+	return run(ctx, opts, func(rng *rand.Rand, n int, b *batch) error {
+		for i := 0; i < n; i++ {
+			b.counts[fn(rng)]++
+		}
+		return nil
+	})
+}
+
+// batch is the rolls made by a worker between reports
+type batch struct {
+	counts map[int]int
+	// excluded counts rolls that were made but not recorded, such as those that don't meet the
+	// condition of a query
+	excluded int
+}
+
+// batchFunc makes n rolls using rng and records them in b. An error stops the simulation and is
+// returned by run.
+type batchFunc func(rng *rand.Rand, n int, b *batch) error
+
+func run(parent context.Context, opts Options, fn batchFunc) (*Histogram, error) {
+	opts = opts.withDefaults()
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		results = make(chan *batch, opts.Workers)
+		// claims hands out batches so that no more than opts.Rolls are made
+		claims = make(chan int)
+		// errs holds the first error from a batch
+		errs = make(chan error, 1)
+	)
+
+	go func() {
+		defer close(claims)
+		for left := opts.Rolls; left > 0; left -= opts.BatchSize {
+			n := opts.BatchSize
+			if left < n {
+				n = left
+			}
+
+			select {
+			case claims <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+
+			for n := range claims {
+				b := &batch{counts: make(map[int]int)}
+				if err := fn(rng, n, b); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					cancel()
+					return
+				}
+
+				select {
+				case results <- b:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(rand.New(rand.NewSource(streamSeed(opts.Seed, w))))
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	hist := &Histogram{Counts: make(map[int]int)}
+	for b := range results {
+		hist.add(b.counts)
+		hist.excluded += b.excluded
+
+		if opts.Precision > 0 && hist.Rolls >= opts.MinRolls && hist.MaxStdErr() <= opts.Precision {
+			cancel()
+			break
+		}
+	}
+
+	// Drain any results still in flight so the workers can exit
+	go func() {
+		for range results {
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return hist, err
+	default:
+		return hist, parent.Err()
+	}
+}
+
+func (o Options) withDefaults() Options {
+	if o.Workers < 1 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.Rolls < 1 {
+		o.Rolls = DefaultRolls
+		if o.Precision > 0 {
+			o.Rolls = math.MaxInt32
+		}
+	}
+	if o.MinRolls < 1 {
+		o.MinRolls = DefaultMinRolls
+	}
+	if o.BatchSize < 1 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+
+	return o
+}
+
+// streamSeed derives the seed of worker w's random stream from seed using the splitmix64
+// finaliser, so that neighbouring workers get unrelated streams
+func streamSeed(seed int64, w int) int64 {
+	z := uint64(seed) + uint64(w+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// Histogram counts how often each total was rolled
+type Histogram struct {
+	Counts map[int]int
+	Rolls  int
+
+	// excluded counts rolls made but left out of Counts, see batch
+	excluded int
+}
+
+func (h *Histogram) add(counts map[int]int) {
+	for v, c := range counts {
+		h.Counts[v] += c
+		h.Rolls += c
+	}
+}
+
+// Values returns the totals that were rolled in ascending order
+func (h *Histogram) Values() []int {
+	var out []int
+
+	for v := range h.Counts {
+		out = append(out, v)
+	}
+	sort.Ints(out)
+
+	return out
+}
+
+// P returns the estimated probability of rolling v
+func (h *Histogram) P(v int) float64 {
+	if h.Rolls == 0 {
+		return 0
+	}
+
+	return float64(h.Counts[v]) / float64(h.Rolls)
+}
+
+// PFunc returns the estimated probability of rolling any value for which fn returns true
+func (h *Histogram) PFunc(fn func(v int) bool) float64 {
+	if h.Rolls == 0 {
+		return 0
+	}
+
+	c := 0
+	for v, n := range h.Counts {
+		if fn(v) {
+			c += n
+		}
+	}
+
+	return float64(c) / float64(h.Rolls)
+}
+
+// StdErr returns the standard error of the estimated probability of rolling v
+func (h *Histogram) StdErr(v int) float64 {
+	return StdErr(h.P(v), h.Rolls)
+}
+
+// CI returns the 95% confidence interval of the probability of rolling v
+func (h *Histogram) CI(v int) (float64, float64) {
+	return CI(h.P(v), h.Rolls)
+}
+
+// MaxStdErr returns the largest standard error of any value's probability
+func (h *Histogram) MaxStdErr() float64 {
+	if h.Rolls == 0 {
+		return math.Inf(1)
+	}
+
+	max := 0.
+	for v := range h.Counts {
+		if e := h.StdErr(v); e > max {
+			max = e
+		}
+	}
+
+	return max
+}
+
+// Mean returns the average total
+func (h *Histogram) Mean() float64 {
+	if h.Rolls == 0 {
+		return 0
+	}
+
+	t := 0.
+	for v, c := range h.Counts {
+		t += float64(v) * float64(c)
+	}
+
+	return t / float64(h.Rolls)
+}
+
+// Distribution returns the estimated probability of every total that was rolled, which can be
+// sampled with roll.NewSampler or turned into a Die with roll.DistributionDie
+func (h *Histogram) Distribution() roll.Distribution {
+	out := make(roll.Distribution, len(h.Counts))
+
+	for v := range h.Counts {
+		out[v] = h.P(v)
+	}
+
+	return out
+}
+
+// StdErr returns the standard error of a probability p estimated from n rolls
+func StdErr(p float64, n int) float64 {
+	if n == 0 {
+		return math.Inf(1)
+	}
+
+	return math.Sqrt(p * (1 - p) / float64(n))
+}
+
+// CI returns the 95% Wilson score interval of a probability p estimated from n rolls. Unlike
+// p ± 1.96 standard errors it stays within [0, 1] and is reliable for rare values.
+func CI(p float64, n int) (float64, float64) {
+	if n == 0 {
+		return 0, 1
+	}
+
+	const z = 1.959964
+	var (
+		nf     = float64(n)
+		denom  = 1 + z*z/nf
+		centre = (p + z*z/(2*nf)) / denom
+		half   = z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	)
+
+	return math.Max(0, centre-half), math.Min(1, centre+half)
+}
@@ -0,0 +1,91 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nboughton/go-roll"
+)
+
+func TestRunError(t *testing.T) {
+	// A quarter of rolls explode more than once
+	e := roll.MustParse("1d2X2").WithLimits(roll.Limits{MaxExplodeDepth: 1})
+
+	var le *roll.LimitError
+	if _, err := Run(context.Background(), e, Options{Rolls: 1000, Seed: 1}); !errors.As(err, &le) {
+		t.Errorf("Run: got %v, want a *roll.LimitError", err)
+	}
+
+	q, err := roll.ParseQuery(">= 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunQuery(context.Background(), e, q, Options{Rolls: 1000, Seed: 1}); !errors.As(err, &le) {
+		t.Errorf("RunQuery: got %v, want a *roll.LimitError", err)
+	}
+}
+
+func TestRunJointError(t *testing.T) {
+	_, err := RunJoint(context.Background(), []string{"a", "b"}, func(rng *rand.Rand) []int {
+		return []int{rng.Intn(6)}
+	}, Options{Rolls: 1000, Seed: 1})
+	if err == nil {
+		t.Errorf("RunJoint: got no error for a function returning too few values")
+	}
+}
+
+func TestRunQueryGiven(t *testing.T) {
+	q, err := roll.ParseQuery("= 6 given >= 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const rolls = 100000
+	a, err := RunQuery(context.Background(), roll.MustParse("1d6"), q, Options{Rolls: rolls, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolls of 1 to 3 are excluded but still count towards the chance of the condition
+	if math.Abs(a.Given-0.5) > 5*StdErr(0.5, rolls) {
+		t.Errorf("Given = %v, want 0.5", a.Given)
+	}
+	if math.Abs(a.P-1./3) > 5*a.StdErr {
+		t.Errorf("P = %v ± %v, want 1/3", a.P, a.StdErr)
+	}
+	if a.Rolls < rolls/3 || a.Rolls > rolls*2/3 {
+		t.Errorf("%d of %d rolls met the condition", a.Rolls, rolls)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	q, err := roll.ParseQuery(">= 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A d1 is skipped; P(1d$s >= 4) is exact for the rest and (s-3)/s
+	s := roll.Search{Var: "s", Min: 1, Max: 10, Goal: roll.AtLeast, Target: 0.6}
+	sol, err := Solve(context.Background(), roll.MustParse("1d$s"), q, s, Options{Rolls: 1000, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Value != 8 || !sol.Answer.Exact || math.Abs(sol.Answer.P-0.625) > 1e-9 {
+		t.Errorf("got $s = %d with %+v, want 8 with P 0.625", sol.Value, sol.Answer)
+	}
+	if len(sol.Tried) != 9 || sol.Tried[0].Value != 2 {
+		t.Errorf("tried %d values from %d, want 9 from 2", len(sol.Tried), sol.Tried[0].Value)
+	}
+
+	// Explosions don't change P(1d($s)X6 >= 4), as a 6 is already 4 or more
+	s = roll.Search{Var: "s", Min: 1, Max: 10, Goal: roll.Closest, Target: 0.5}
+	if sol, err = Solve(context.Background(), roll.MustParse("1d($s)X6"), q, s, Options{Rolls: 20000, Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if sol.Value != 6 || math.Abs(sol.Answer.P-0.5) > 1e-9 {
+		t.Errorf("got $s = %d with %+v, want 6 with P 0.5", sol.Value, sol.Answer)
+	}
+}
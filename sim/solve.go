@@ -0,0 +1,16 @@
+package sim
+
+import (
+	"context"
+
+	"github.com/nboughton/go-roll"
+)
+
+// Solve is roll.Solve with each value answered by Answer, exactly where it can be and by
+// simulation otherwise. opts.Vars is ignored in favour of s.Vars.
+func Solve(ctx context.Context, e *roll.Expr, q *roll.Query, s roll.Search, opts Options) (roll.Solution, error) {
+	return roll.SolveFunc(s, func(vars roll.Vars) (roll.Answer, error) {
+		opts.Vars = vars
+		return Answer(ctx, e, q, opts)
+	})
+}
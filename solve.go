@@ -0,0 +1,112 @@
+package roll
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SolveGoal is what Solve looks for among the answers for each value of a variable
+type SolveGoal int
+
+const (
+	// Closest finds the value whose answer is closest to the target
+	Closest SolveGoal = iota
+	// AtLeast finds the value whose answer is the lowest that is at or above the target, such as
+	// the fewest dice for an 80% chance or the highest DC that still succeeds 65% of the time
+	AtLeast
+	// AtMost finds the value whose answer is the highest that is at or below the target
+	AtMost
+)
+
+// maxSolveValues is the most values of a variable Solve will try
+const maxSolveValues = 10000
+
+// Search describes the variable Solve varies and the answer it looks for
+type Search struct {
+	// Var is the variable to vary, without the leading $. It may appear in the expression, for a
+	// modifier, number of dice or die size, or in the query, for a target such as >= $dc.
+	Var string
+	// Min and Max are the range of values tried, inclusive
+	Min, Max int
+	Goal     SolveGoal
+	// Target is the probability looked for, or the expected total for a mean query
+	Target float64
+	// Vars binds any other variables of the expression and query
+	Vars Vars
+}
+
+// Trial is the answer to a query for one value of the variable searched
+type Trial struct {
+	Value  int    `json:"value"`
+	Answer Answer `json:"answer"`
+}
+
+// Solution is the best value found by Solve and every value tried, in order
+type Solution struct {
+	Trial
+	Tried []Trial `json:"tried"`
+}
+
+// Solve finds the value of s.Var between s.Min and s.Max for which the answer to q about e best
+// meets s.Goal, answering exactly. ErrInexact is returned if any value can't be answered exactly;
+// use sim.Solve to estimate the answers instead. An error is returned if no value meets the goal.
+func Solve(e *Expr, q *Query, s Search) (Solution, error) {
+	return SolveFunc(s, func(vars Vars) (Answer, error) { return q.Exact(e, vars) })
+}
+
+// SolveFunc is Solve with the answer for each value of s.Var given by answer, which is passed
+// s.Vars with s.Var bound. Values that make invalid dice, such as a d1 for 1d$s, are skipped
+// and left out of Tried; the *InvalidDieError is returned if every value is invalid.
+func SolveFunc(s Search, answer func(vars Vars) (Answer, error)) (Solution, error) {
+	var out Solution
+
+	if s.Var == "" {
+		return out, fmt.Errorf("no variable to solve for")
+	}
+	if s.Max < s.Min {
+		return out, fmt.Errorf("can't solve for $%s between %d and %d", s.Var, s.Min, s.Max)
+	}
+	if s.Max-s.Min >= maxSolveValues {
+		return out, fmt.Errorf("can't solve for $%s over more than %d values", s.Var, maxSolveValues)
+	}
+
+	vars := make(Vars, len(s.Vars)+1)
+	for k, v := range s.Vars {
+		vars[k] = v
+	}
+
+	var (
+		best    = math.Inf(1)
+		found   bool
+		invalid *InvalidDieError
+	)
+	for v := s.Min; v <= s.Max; v++ {
+		vars[s.Var] = v
+		a, err := answer(vars)
+		if errors.As(err, &invalid) {
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		t := Trial{Value: v, Answer: a}
+		out.Tried = append(out.Tried, t)
+
+		if s.Goal == AtLeast && a.P < s.Target || s.Goal == AtMost && a.P > s.Target {
+			continue
+		}
+		if miss := math.Abs(a.P - s.Target); miss < best {
+			best, found, out.Trial = miss, true, t
+		}
+	}
+
+	if len(out.Tried) == 0 && invalid != nil {
+		return out, invalid
+	}
+	if !found {
+		return out, fmt.Errorf("no value of $%s between %d and %d meets the target of %g", s.Var, s.Min, s.Max, s.Target)
+	}
+
+	return out, nil
+}
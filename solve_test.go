@@ -0,0 +1,72 @@
+package roll
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	for _, c := range []struct {
+		dice, query string
+		s           Search
+		value       int
+		p           float64
+		tried       []int // first and last values tried
+	}{
+		// P(1d20+7 >= dc) is (28-dc)/20
+		{"1d20+7", ">= $dc", Search{Var: "dc", Min: 10, Max: 20, Goal: Closest, Target: 0.65}, 15, 0.65, []int{10, 20}},
+		{"1d20+7", ">= $dc", Search{Var: "dc", Min: 10, Max: 20, Goal: AtLeast, Target: 0.62}, 15, 0.65, []int{10, 20}},
+		{"1d20+7", ">= $dc", Search{Var: "dc", Min: 10, Max: 20, Goal: AtMost, Target: 0.62}, 16, 0.6, []int{10, 20}},
+		// P(1d$s >= 4) is (s-3)/s, and a d1 is skipped
+		{"1d$s", ">= 4", Search{Var: "s", Min: 1, Max: 10, Goal: AtLeast, Target: 0.6}, 8, 0.625, []int{2, 10}},
+		{"1d$s", ">= 4", Search{Var: "s", Min: -5, Max: 10, Goal: AtMost, Target: 0.6}, 7, 4. / 7, []int{2, 10}},
+		{"1d$s+$m", ">= 4", Search{Var: "s", Min: 1, Max: 6, Goal: Closest, Target: 1, Vars: Vars{"m": 1}}, 6, 4. / 6, []int{2, 6}},
+	} {
+		q, err := ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sol, err := Solve(MustParse(c.dice), q, c.s)
+		if err != nil {
+			t.Errorf("%s %s: %v", c.dice, c.query, err)
+			continue
+		}
+		if sol.Value != c.value || math.Abs(sol.Answer.P-c.p) > 1e-9 {
+			t.Errorf("%s %s %+v: got $%s = %d with %v, want %d with %v", c.dice, c.query, c.s, c.s.Var, sol.Value, sol.Answer.P, c.value, c.p)
+		}
+		if first, last := sol.Tried[0].Value, sol.Tried[len(sol.Tried)-1].Value; first != c.tried[0] || last != c.tried[1] || len(sol.Tried) != last-first+1 {
+			t.Errorf("%s %s: tried %d values from %d to %d, want %d to %d", c.dice, c.query, len(sol.Tried), first, last, c.tried[0], c.tried[1])
+		}
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	q, err := ParseQuery(">= 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		dice string
+		s    Search
+		err  string
+	}{
+		{"1d$s", Search{Min: 1, Max: 10}, "no variable to solve for"},
+		{"1d$s", Search{Var: "s", Min: 10, Max: 1}, "can't solve for $s between 10 and 1"},
+		{"1d$s", Search{Var: "s", Min: 1, Max: 20000}, "can't solve for $s over more than 10000 values"},
+		{"1d$s", Search{Var: "s", Min: 1, Max: 10, Goal: AtLeast, Target: 0.9}, "no value of $s between 1 and 10 meets the target of 0.9"},
+		{"1d$s", Search{Var: "s", Min: -1, Max: 1}, "non-euclidean die: 1d$s rolls a d1"},
+		{"1d$s+$m", Search{Var: "s", Min: 1, Max: 6}, "variable $m is not bound"},
+	} {
+		if _, err := Solve(MustParse(c.dice), q, c.s); err == nil || err.Error() != c.err {
+			t.Errorf("%s %+v: got error %v, want %q", c.dice, c.s, err, c.err)
+		}
+	}
+
+	var invalid *InvalidDieError
+	if _, err := Solve(MustParse("($n)d6"), q, Search{Var: "n", Min: -3, Max: 0}); !errors.As(err, &invalid) || invalid.Count != 0 {
+		t.Errorf("got %v, want an *InvalidDieError for the last value tried", err)
+	}
+}
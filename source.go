@@ -0,0 +1,41 @@
+package roll
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+// Source is a source of random numbers used to roll dice. Intn returns a number in [0, n).
+// *rand.Rand satisfies Source so dice can be rolled from a seeded generator, or one generator
+// per goroutine. Unless stated otherwise a nil Source means the default, shared source.
+type Source interface {
+	Intn(n int) int
+}
+
+// intn returns a number in [0, n) from src or the default source if src is nil
+func intn(src Source, n int) int {
+	if src == nil {
+		return rand.Intn(n)
+	}
+
+	return src.Intn(n)
+}
+
+// uniform returns a number in [0, n) from the random bits returned by next without modulo
+// bias, using Lemire's multiply and reject method. It panics if n <= 0, like rand.Intn.
+func uniform(next func() uint64, n int) int {
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+
+	bound := uint64(n)
+	hi, lo := bits.Mul64(next(), bound)
+	if lo < bound {
+		threshold := -bound % bound
+		for lo < threshold {
+			hi, lo = bits.Mul64(next(), bound)
+		}
+	}
+
+	return int(hi)
+}
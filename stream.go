@@ -0,0 +1,82 @@
+package roll
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Stream is a named, reproducible Source for procedural generation. A Stream's numbers depend
+// only on its key, so the same key always rolls the same results, and Child derives an
+// independent Stream from a parent and a name without drawing from the parent. This makes
+// generation reproducible per key regardless of the order in which things are generated.
+// Keys are derived with SHA-256 and numbers generated with SplitMix64, both implemented here
+// rather than borrowed from math/rand, so the results of a key are stable across Go and
+// library versions. A Stream is not safe for concurrent use; derive a Child per goroutine.
+/* For Example:
+
+   world := roll.NewStream("world")
+
+   // Hex 12.07 always has the same contents, whether it's generated first or last
+   hex := world.Child("hex:12.07")
+   terrain := terrainTable.RollWith(hex)
+   encounter, err := registry.Roll("encounters", hex)
+
+*/
+type Stream struct {
+	key   string
+	seed  uint64
+	state uint64
+}
+
+// NewStream returns the root Stream for key
+func NewStream(key string) *Stream {
+	return newStream(key, deriveSeed(0, key))
+}
+
+func newStream(key string, seed uint64) *Stream {
+	return &Stream{key: key, seed: seed, state: seed}
+}
+
+// Child returns the Stream for name beneath s. Children depend only on the key of s and name,
+// not on how many numbers have been drawn from s, so NewStream("world").Child("hex:12.07")
+// always produces the same numbers.
+func (s *Stream) Child(name string) *Stream {
+	return newStream(s.key+"/"+name, deriveSeed(s.seed, name))
+}
+
+// Key returns the path of names from the root Stream to s, separated by /
+func (s *Stream) Key() string {
+	return s.key
+}
+
+// Reset rewinds s to the start of its numbers
+func (s *Stream) Reset() {
+	s.state = s.seed
+}
+
+// Uint64 returns the next 64 random bits of the stream
+func (s *Stream) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Intn returns a number in [0, n). It panics if n <= 0, like rand.Intn.
+func (s *Stream) Intn(n int) int {
+	return uniform(s.Uint64, n)
+}
+
+// deriveSeed hashes a parent seed and a name into the seed of a child stream
+func deriveSeed(parent uint64, name string) uint64 {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], parent)
+
+	h := sha256.New()
+	h.Write(buf[:])
+	h.Write([]byte(name))
+
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
@@ -0,0 +1,77 @@
+package roll
+
+import "testing"
+
+// Golden values were computed independently of this package from SHA-256 and SplitMix64, so
+// that a change to either, which would change the results of every key, fails the test
+func TestStreamGolden(t *testing.T) {
+	world := NewStream("world")
+	for i, want := range []uint64{0x7df310d12106a890, 0xcb0c528bf79037b2, 0x0ebfa79b7f86584c} {
+		if got := world.Uint64(); got != want {
+			t.Errorf("world: Uint64 %d = %#x, want %#x", i, got, want)
+		}
+	}
+
+	for _, c := range []struct {
+		s    *Stream
+		key  string
+		n    int
+		want []int
+	}{
+		{NewStream("world"), "world", 6, []int{2, 4, 0, 1, 0, 5, 1, 1}},
+		{NewStream("world").Child("hex:12.07"), "world/hex:12.07", 20, []int{11, 11, 1, 8, 12, 9, 18, 18}},
+		{NewStream("world").Child("hex:12.07").Child("cave"), "world/hex:12.07/cave", 100, []int{94, 92, 13, 17, 60}},
+	} {
+		if c.s.Key() != c.key {
+			t.Errorf("got key %q, want %q", c.s.Key(), c.key)
+		}
+
+		got := make([]int, len(c.want))
+		for i := range got {
+			got[i] = c.s.Intn(c.n)
+		}
+		if !equalInts(got, c.want) {
+			t.Errorf("%s: Intn(%d) gave %v, want %v", c.key, c.n, got, c.want)
+		}
+
+		c.s.Reset()
+		if v := c.s.Intn(c.n); v != c.want[0] {
+			t.Errorf("%s: Intn(%d) after Reset = %d, want %d", c.key, c.n, v, c.want[0])
+		}
+	}
+}
+
+func TestStreamChildOrder(t *testing.T) {
+	draw := func(s *Stream) []int {
+		out := make([]int, 10)
+		for i := range out {
+			out[i] = s.Intn(1000)
+		}
+		return out
+	}
+
+	// a and b are created first and last, with numbers drawn from the parent in between
+	p := NewStream("world")
+	a := draw(p.Child("a"))
+	draw(p)
+	p.Child("c")
+	b := draw(p.Child("b"))
+
+	q := NewStream("world")
+	qb := q.Child("b")
+	draw(q)
+	qa := q.Child("a")
+
+	if got := draw(qa); !equalInts(got, a) {
+		t.Errorf("child a gave %v, then %v", a, got)
+	}
+	if got := draw(qb); !equalInts(got, b) {
+		t.Errorf("child b gave %v, then %v", b, got)
+	}
+	if equalInts(a, b) {
+		t.Errorf("children a and b gave the same numbers %v", a)
+	}
+	if equalInts(a, draw(NewStream("world/a"))) {
+		t.Errorf("child a and root world/a gave the same numbers")
+	}
+}
@@ -81,16 +81,32 @@ func NewTableRegistry() TableRegistry {
 	return make(TableRegistry)
 }
 
-// Add a table to the TableRegistry
+// Add a table to the TableRegistry. Items with a Subtable and no action are bound to roll that
+// table from the registry.
 func (r TableRegistry) Add(t Table) error {
 	if _, ok := r[t.ID]; !ok {
-		r[t.ID] = t
+		r[t.ID] = r.bind(t)
 		return nil
 	}
 
 	return fmt.Errorf("table %s already registered", t.ID)
 }
 
+// bind sets the ActionWith of items that refer to a Subtable, copying the items so that the
+// caller's table is unchanged
+func (r TableRegistry) bind(t Table) Table {
+	items := make([]TableItem, len(t.Items))
+	for i, item := range t.Items {
+		if item.Subtable != "" && item.Action == nil && item.ActionWith == nil {
+			item.ActionWith = r.Action(item.Subtable)
+		}
+		items[i] = item
+	}
+	t.Items = items
+
+	return t
+}
+
 // Remove a table from the TableRegistry
 func (r TableRegistry) Remove(id string) error {
 	if _, ok := r[id]; ok {
@@ -111,6 +127,27 @@ func (r TableRegistry) Get(id string) (Table, error) {
 	return t, nil
 }
 
+// Roll rolls the table registered as id using src and returns the option drawn. A nil src uses
+// the default source.
+func (r TableRegistry) Roll(id string, src Source) (string, error) {
+	t, err := r.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	return t.RollWith(src), nil
+}
+
+// Action returns a TableItem.ActionWith that rolls the table registered as id with the Source
+// of the table that called it, so that subtables of a Stream are reproducible too. The table
+// is looked up when the action runs and an unregistered id rolls an empty string.
+func (r TableRegistry) Action(id string) func(src Source) string {
+	return func(src Source) string {
+		out, _ := r.Roll(id, src)
+		return out
+	}
+}
+
 // Table represents a table of text options that can be rolled on. Name is
 // optional. Tables are preferable to Lists when using multiple dice to achieve
 // a result (i.e 2d6) because their results fall on a bell curve whereas single-die
@@ -130,11 +167,17 @@ type TableReroll struct {
 	Dice  Dice
 }
 
-// TableItem represents the text and matching numbers from the table
+// TableItem represents the text and matching numbers from the table. ActionWith is used in
+// place of Action when the table is rolled with a Source, so that nested rolls such as
+// TableRegistry.Action draw from the same Source. Subtable is a declarative alternative to
+// both: the ID of a table in the same TableRegistry to roll when the item is drawn, bound by
+// TableRegistry.Add. Unlike actions, Subtable survives encoding.
 type TableItem struct {
-	Match  TableMatchSet
-	Text   string
-	Action func() string
+	Match      TableMatchSet
+	Text       string
+	Action     func() string
+	ActionWith func(src Source) string
+	Subtable   string
 }
 
 // TableMatchSet wraps ranges of numbers to match
@@ -163,9 +206,15 @@ func (m TableMatchSet) String() string {
 
 // Roll on the table and return the option drawn.
 func (t Table) Roll() string {
+	return t.RollWith(nil)
+}
+
+// RollWith rolls on the table using src, including any rerolls and item actions, and returns
+// the option drawn. A nil src uses the default source.
+func (t Table) RollWith(src Source) string {
 	out := ""
 
-	r := t.Dice.Roll()
+	r := t.Dice.RollWith(src)
 	n := r.Sum() + t.Mod
 	if n < t.Dice.Min() {
 		n = t.Dice.Min()
@@ -183,7 +232,7 @@ func (t Table) Roll() string {
 
 	// Check for a reroll
 	if t.Reroll.Match.Contains(n) {
-		r = t.Reroll.Dice.Roll()
+		r = t.Reroll.Dice.RollWith(src)
 		n = r.Sum()
 		for _, i := range t.Items {
 			if i.Match.Contains(n) {
@@ -198,12 +247,16 @@ func (t Table) Roll() string {
 	// Append text for final roll result
 	for _, i := range t.Items {
 		if i.Match.Contains(n) {
-			if i.Action != nil {
+			if i.ActionWith != nil || i.Action != nil {
 				if out != "" {
 					out += "; "
 				}
 
-				out += i.Action()
+				if i.ActionWith != nil {
+					out += i.ActionWith(src)
+				} else {
+					out += i.Action()
+				}
 			}
 			return out
 		}
@@ -212,6 +265,21 @@ func (t Table) Roll() string {
 	return ""
 }
 
+// Distribution returns the probability of every number the table's first roll can land on, its
+// Dice plus Mod kept within the range of the Dice as RollWith does
+func (t Table) Distribution() Distribution {
+	min, max := t.Dice.Min(), t.Dice.Max()
+
+	return t.Dice.Distribution().Map(func(v int) int {
+		if v += t.Mod; v < min {
+			return min
+		} else if v > max {
+			return max
+		}
+		return v
+	})
+}
+
 func (t Table) String() string {
 	var (
 		buf = new(bytes.Buffer)
@@ -240,8 +308,13 @@ type List struct {
 
 // Roll returns a random string from List
 func (l List) Roll() string {
+	return l.RollWith(nil)
+}
+
+// RollWith returns a random string from List using src. A nil src uses the default source.
+func (l List) RollWith(src Source) string {
 	if len(l.Items) > 0 {
-		return l.Items[rand.Intn(len(l.Items))]
+		return l.Items[intn(src, len(l.Items))]
 	}
 
 	return ""